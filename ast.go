@@ -0,0 +1,511 @@
+package decoder
+
+// StmtKind represents kind of the statement in AST.
+type StmtKind int
+
+const (
+	// StmtAssign is an assignment, eg: "dst.Name = src.name|default("N/D")" or "dst.Hash = crc32(src.id)".
+	StmtAssign StmtKind = iota
+	// StmtCall is a callback call, eg: "testns::foo(src.id)".
+	StmtCall
+	// StmtIf is a condition, if-else block, condition-OK block or ternary operator.
+	StmtIf
+	// StmtSwitch is a switch block, its body consists of StmtCase and StmtDefault statements.
+	StmtSwitch
+	// StmtCase is a case of switch block.
+	StmtCase
+	// StmtDefault is a default case of switch block.
+	StmtDefault
+	// StmtLoopRange is a range loop, eg: "for k, v := range src.items {".
+	StmtLoopRange
+	// StmtLoopCount is a counter loop, eg: "for i := 0; i < 10; i++ {".
+	StmtLoopCount
+	// StmtBreak is a break statement.
+	StmtBreak
+	// StmtLazyBreak is a lazybreak statement.
+	StmtLazyBreak
+	// StmtContinue is a continue statement.
+	StmtContinue
+	// StmtReturn is a return statement, with value inside of user-defined functions.
+	StmtReturn
+	// StmtWith is a with block, eg: "with dst.Items[+] as it {".
+	StmtWith
+	// StmtAutomap is an automap statement.
+	StmtAutomap
+	// StmtDecode is a sub-decoder call, eg: "decode("address", dst.Address, src.shipping)".
+	StmtDecode
+	// StmtUse is a sub-decoder include, eg: "use "common_headers"".
+	StmtUse
+	// StmtFlag is a preprocessor block, eg: "#if flag("new_pricing")".
+	StmtFlag
+)
+
+func (k StmtKind) String() string {
+	switch k {
+	case StmtAssign:
+		return "assign"
+	case StmtCall:
+		return "call"
+	case StmtIf:
+		return "if"
+	case StmtSwitch:
+		return "switch"
+	case StmtCase:
+		return "case"
+	case StmtDefault:
+		return "default"
+	case StmtLoopRange:
+		return "range"
+	case StmtLoopCount:
+		return "loop"
+	case StmtBreak:
+		return "break"
+	case StmtLazyBreak:
+		return "lazybreak"
+	case StmtContinue:
+		return "continue"
+	case StmtReturn:
+		return "return"
+	case StmtWith:
+		return "with"
+	case StmtAutomap:
+		return "automap"
+	case StmtDecode:
+		return "decode"
+	case StmtUse:
+		return "use"
+	case StmtFlag:
+		return "flag"
+	default:
+		return "unk"
+	}
+}
+
+// AST represents read-only view of the tree.
+//
+// AST is a copy of the tree, thus any changes in it don't affect the tree.
+type AST struct {
+	// Key of parent decoder (see extends statement).
+	Extends string `json:"extends,omitempty"`
+	// Keys of imported libraries.
+	Imports []string `json:"imports,omitempty"`
+	// Declared constants, values keep as written (strings are quoted).
+	Consts map[string]string `json:"consts,omitempty"`
+	// User-defined functions and macros.
+	Funcs []Func `json:"funcs,omitempty"`
+	// Root statements.
+	Stmts []Stmt `json:"stmts,omitempty"`
+}
+
+// Func describes user-defined function or macro.
+type Func struct {
+	Name   string   `json:"name"`
+	Macro  bool     `json:"macro,omitempty"`
+	Params []string `json:"params,omitempty"`
+	Body   []Stmt   `json:"body,omitempty"`
+}
+
+// Stmt describes one statement of the tree.
+//
+// Set of filled fields depends on the kind of statement.
+type Stmt struct {
+	Kind StmtKind `json:"kind"`
+	// Position of the statement in the source, starting from 1.
+	// Constants are substituted before parsing, so columns may shift in lines where constants were used.
+	Line int `json:"line,omitempty"`
+	Col  int `json:"col,omitempty"`
+	// Destination of assignment, with, automap and decode statements.
+	Dst VarPath `json:"dst"`
+	// Push shows that value appends to destination slice, eg: "dst.Tags[] = src.tag".
+	Push bool `json:"push,omitempty"`
+	// Source of assignment (unless getter uses), returning value, argument of switch, source of range loop, automap and
+	// decode statements.
+	Src Expr `json:"src"`
+	// Callback of call statement or getter of assignment.
+	Call *Call `json:"call,omitempty"`
+	// Condition of if statement or case.
+	Cond *Cond `json:"cond,omitempty"`
+	// Ternary shows that condition written as ternary operator, eg: "dst.Status = src.ok == true ? 1 : 0".
+	Ternary bool `json:"ternary,omitempty"`
+	// Loop properties of loop statements.
+	Loop *Loop `json:"loop,omitempty"`
+	// Label of loop for break/lazybreak/continue statement.
+	Label string `json:"label,omitempty"`
+	// Depth of loop for break/lazybreak/continue statement, 0 means the nearest loop.
+	Depth int `json:"depth,omitempty"`
+	// Variable of with block.
+	Var string `json:"var,omitempty"`
+	// Key of sub-decoder of decode and use statements.
+	Decoder string `json:"decoder,omitempty"`
+	// Name of flag of preprocessor block.
+	Flag string `json:"flag,omitempty"`
+	// Options of automap statement.
+	Automap *Automap `json:"automap,omitempty"`
+	// Branches of if statement and preprocessor block.
+	Then []Stmt `json:"then,omitempty"`
+	Else []Stmt `json:"else,omitempty"`
+	// Body of loop, switch, case and with blocks.
+	Body []Stmt `json:"body,omitempty"`
+}
+
+// VarPath describes variable path, eg: "dst.Items[0].Name".
+type VarPath struct {
+	// Path as written in the source.
+	Raw string `json:"raw"`
+	// Tokens of the path, eg: ["dst", "Items", "0", "Name"]. Nil for dynamic paths.
+	Tokens []string `json:"tokens,omitempty"`
+	// Dynamic shows that path contains indexes or keys evaluating in runtime, eg: "dst.Items[i]".
+	Dynamic bool `json:"dynamic,omitempty"`
+}
+
+// Expr describes value expression, eg: "src.{id|title}|default("N/D")".
+type Expr struct {
+	// Path of variable. Empty for static values and modifiers called without variable (eg: "new(TestObject)").
+	Path VarPath `json:"path"`
+	// Static shows that expression is static value.
+	Static bool `json:"static,omitempty"`
+	// Static value without quotes.
+	Value string `json:"value,omitempty"`
+	// Keys that checks sequentially in the variable, eg: "src.{id|title}".
+	Subset []string `json:"subset,omitempty"`
+	// Chain of modifiers.
+	Mods []Call `json:"mods,omitempty"`
+	// Type of context variable, eg: "ctx.x = src.y as Finance".
+	Type string `json:"type,omitempty"`
+}
+
+// Call describes function call: callback, getter, modifier or condition helper.
+type Call struct {
+	Name string `json:"name"`
+	Args []Arg  `json:"args,omitempty"`
+}
+
+// Arg describes argument of the function or side of comparison.
+type Arg struct {
+	// Value of argument, static values without quotes.
+	Value string  `json:"value"`
+	Kind  ArgKind `json:"kind"`
+	// Static shows that argument is a static value.
+	Static bool `json:"static,omitempty"`
+	// Global shows that argument is a global variable.
+	Global bool `json:"global,omitempty"`
+	// Keys that checks sequentially in the variable, eg: "src.{id|title}".
+	Subset []string `json:"subset,omitempty"`
+}
+
+// Cond describes condition of if statement or case.
+type Cond struct {
+	// Sides of comparison and operator, eg: "src.status == 1".
+	Left  Arg    `json:"left"`
+	Right Arg    `json:"right"`
+	Op    string `json:"op,omitempty"`
+	// Condition helper, eg: "testns::check(src.id)" or "len(src.items)".
+	Helper *Call `json:"helper,omitempty"`
+	// Variables of condition-OK statement, eg: "if v, ok := helper(x); ok {", sides of comparison describe check
+	// of ok variable in that case.
+	Var   string `json:"var,omitempty"`
+	VarOK string `json:"varOK,omitempty"`
+	// Type of variable of condition-OK statement, eg: "if v, ok := helper(x) as Finance; ok {".
+	Type string `json:"type,omitempty"`
+}
+
+// Loop describes properties of loop statement.
+type Loop struct {
+	Label string `json:"label,omitempty"`
+	// Key and value variables of range loop.
+	Key string `json:"key,omitempty"`
+	Val string `json:"val,omitempty"`
+	// Counter of counter loop, its initial value and limit.
+	Counter string `json:"counter,omitempty"`
+	Init    string `json:"init,omitempty"`
+	Limit   string `json:"limit,omitempty"`
+	// Operator of comparison of counter and limit, eg: "<".
+	CondOp string `json:"condOp,omitempty"`
+	// Operator of counter changing, "++" or "--".
+	CountOp string `json:"countOp,omitempty"`
+}
+
+// Automap describes options of automap statement.
+type Automap struct {
+	Except []string `json:"except,omitempty"`
+	// Pairs of renamed fields, destination field first.
+	Rename   [][2]string `json:"rename,omitempty"`
+	Strategy string      `json:"strategy,omitempty"`
+}
+
+// Visitor visits statements during Walk.
+//
+// Visit calls for each statement. If returned visitor w isn't nil, Walk visits nested statements with w.
+type Visitor interface {
+	Visit(stmt *Stmt) (w Visitor)
+}
+
+// Walk traverses statements of the tree in depth-first order: bodies of functions first, then root statements.
+func Walk(tree *Tree, v Visitor) {
+	ast := tree.AST()
+	for i := 0; i < len(ast.Funcs); i++ {
+		walkStmts(ast.Funcs[i].Body, v)
+	}
+	walkStmts(ast.Stmts, v)
+}
+
+func walkStmts(list []Stmt, v Visitor) {
+	for i := 0; i < len(list); i++ {
+		s := &list[i]
+		w := v.Visit(s)
+		if w == nil {
+			continue
+		}
+		walkStmts(s.Then, w)
+		walkStmts(s.Else, w)
+		walkStmts(s.Body, w)
+	}
+}
+
+// AST builds read-only view of the tree.
+func (t *Tree) AST() *AST {
+	a := &AST{
+		Extends: t.parent,
+		Imports: append([]string(nil), t.imports...),
+		Stmts:   astStmts(t.nodes, 0, 0),
+	}
+	if len(t.consts) > 0 {
+		a.Consts = make(map[string]string, len(t.consts))
+		for k, v := range t.consts {
+			a.Consts[k] = string(v)
+		}
+	}
+	for _, f := range t.udfs {
+		fn := Func{Name: string(f.name), Macro: f.macro, Body: astStmts(f.body, 0, 0)}
+		for _, p := range f.params {
+			fn.Params = append(fn.Params, string(p))
+		}
+		a.Funcs = append(a.Funcs, fn)
+	}
+	return a
+}
+
+// Convert list of nodes to statements.
+//
+// Nodes that don't keep position (eg: branches of ternary operator) inherit position of the parent.
+func astStmts(nodes []node, line, col int) []Stmt {
+	if len(nodes) == 0 {
+		return nil
+	}
+	r := make([]Stmt, 0, len(nodes))
+	for i := 0; i < len(nodes); i++ {
+		n := &nodes[i]
+		s := Stmt{Line: n.line, Col: n.col}
+		if s.Line == 0 {
+			s.Line, s.Col = line, col
+		}
+		switch n.typ {
+		case typeOperator:
+			switch {
+			case n.callback != nil:
+				s.Kind = StmtCall
+				s.Call = astCall(n.src, n.arg)
+			case n.getter != nil:
+				s.Dst = astPath(n.dst)
+				s.Call = astCall(n.src, n.arg)
+			default:
+				s.Dst, s.Push = astPath(n.dst), n.push
+				s.Src = astExpr(n)
+			}
+		case typeCond, typeCondOK:
+			s.Kind = StmtIf
+			s.Cond = astCond(n)
+			_, _, s.Ternary = ternary(n)
+			for j := 0; j < len(n.child); j++ {
+				c := &n.child[j]
+				if c.typ == typeCondFalse {
+					s.Else = astStmts(c.child, s.Line, s.Col)
+				} else {
+					s.Then = astStmts(c.child, s.Line, s.Col)
+				}
+			}
+		case typeSwitch:
+			s.Kind = StmtSwitch
+			if len(n.switchArg) > 0 {
+				s.Src.Path = astPath(n.switchArg)
+			}
+			s.Body = astStmts(n.child, s.Line, s.Col)
+		case typeCase:
+			s.Kind = StmtCase
+			s.Cond = &Cond{}
+			if len(n.caseHlp) > 0 {
+				s.Cond.Helper = astCall(n.caseHlp, n.caseHlpArg)
+			} else {
+				s.Cond.Left = astSide(n.caseL, n.caseStaticL)
+				if n.caseOp != 0 {
+					s.Cond.Op = n.caseOp.String()
+					s.Cond.Right = astSide(n.caseR, n.caseStaticR)
+				}
+			}
+			s.Body = astStmts(n.child, s.Line, s.Col)
+		case typeDefault:
+			s.Kind = StmtDefault
+			s.Body = astStmts(n.child, s.Line, s.Col)
+		case typeLoopRange:
+			s.Kind = StmtLoopRange
+			s.Src.Path = astPath(n.loopSrc)
+			s.Loop = &Loop{Label: string(n.loopLbl), Key: string(n.loopKey), Val: string(n.loopVal)}
+			s.Body = astStmts(n.child, s.Line, s.Col)
+		case typeLoopCount:
+			s.Kind = StmtLoopCount
+			s.Loop = &Loop{
+				Label:   string(n.loopLbl),
+				Counter: string(n.loopCnt),
+				Init:    string(n.loopCntInit),
+				Limit:   string(n.loopLim),
+				CondOp:  n.loopCondOp.String(),
+				CountOp: n.loopCntOp.String(),
+			}
+			s.Body = astStmts(n.child, s.Line, s.Col)
+		case typeBreak, typeLBreak, typeContinue:
+			switch n.typ {
+			case typeBreak:
+				s.Kind = StmtBreak
+			case typeLBreak:
+				s.Kind = StmtLazyBreak
+			default:
+				s.Kind = StmtContinue
+			}
+			s.Label, s.Depth = string(n.loopLbl), n.loopBrkD
+		case typeReturn:
+			s.Kind = StmtReturn
+			s.Src = astExpr(n)
+		case typeWith:
+			s.Kind = StmtWith
+			s.Dst, s.Var = astPath(n.dst), string(n.withVar)
+			s.Body = astStmts(n.child, s.Line, s.Col)
+		case typeAutomap:
+			s.Kind = StmtAutomap
+			s.Dst, s.Src.Path = astPath(n.dst), astPath(n.src)
+			if n.am != nil {
+				s.Automap = &Automap{
+					Except:   append([]string(nil), n.am.except...),
+					Rename:   append([][2]string(nil), n.am.rename...),
+					Strategy: n.am.strategy.String(),
+				}
+			}
+		case typeDecode:
+			s.Kind = StmtDecode
+			s.Decoder = string(n.subKey)
+			s.Dst, s.Src.Path = astPath(n.dst), astPath(n.src)
+		case typeUse:
+			s.Kind = StmtUse
+			s.Decoder = string(n.subKey)
+		case typeFlag:
+			s.Kind = StmtFlag
+			s.Flag = string(n.flag)
+			for j := 0; j < len(n.child); j++ {
+				c := &n.child[j]
+				if c.typ == typeCondFalse {
+					s.Else = astStmts(c.child, s.Line, s.Col)
+				} else {
+					s.Then = astStmts(c.child, s.Line, s.Col)
+				}
+			}
+		default:
+			continue
+		}
+		r = append(r, s)
+	}
+	return r
+}
+
+func astPath(p []byte) VarPath {
+	if len(p) == 0 {
+		return VarPath{}
+	}
+	r := VarPath{Raw: string(p)}
+	if isDynPath(p) {
+		r.Dynamic = true
+		return r
+	}
+	r.Tokens = tokenize(nil, r.Raw)
+	return r
+}
+
+func astExpr(n *node) Expr {
+	var e Expr
+	if n.static {
+		e.Static, e.Value = true, string(n.src)
+		return e
+	}
+	e.Path = astPath(n.src)
+	e.Subset = astSubset(n.subset)
+	for i := 0; i < len(n.mod); i++ {
+		e.Mods = append(e.Mods, *astCall(n.mod[i].id, n.mod[i].arg))
+	}
+	e.Type = string(n.ins)
+	return e
+}
+
+func astCall(name []byte, args []*arg) *Call {
+	c := &Call{Name: string(name)}
+	for _, a := range args {
+		c.Args = append(c.Args, Arg{
+			Value:  string(a.val),
+			Kind:   a.kind,
+			Static: a.static,
+			Global: a.global,
+			Subset: astSubset(a.subset),
+		})
+	}
+	return c
+}
+
+func astCond(n *node) *Cond {
+	c := &Cond{
+		Var:   string(n.condOKL),
+		VarOK: string(n.condOKR),
+		Type:  string(n.condIns),
+	}
+	if len(n.condHlp) > 0 {
+		c.Helper = astCall(n.condHlp, n.condHlpArg)
+		if n.typ == typeCond {
+			return c
+		}
+	}
+	c.Left = astSide(n.condL, n.condStaticL)
+	c.Right = astSide(n.condR, n.condStaticR)
+	if n.condOp != 0 {
+		c.Op = n.condOp.String()
+	}
+	return c
+}
+
+// Convert side of comparison to argument, static sides of switch cases keep quotes.
+func astSide(v []byte, static bool) Arg {
+	if len(v) == 0 {
+		if static {
+			// Empty string, eg: `src.currency == ""`.
+			return Arg{Static: true, Kind: ArgString}
+		}
+		return Arg{}
+	}
+	a := Arg{Static: static, Kind: argKindOf(v)}
+	if static {
+		if a.Kind == ArgPath {
+			// Value was quoted, but quotes are trimmed already.
+			a.Kind = ArgString
+		}
+		v = unquote(v)
+	}
+	a.Value = string(v)
+	a.Global = !static && GetGlobal(a.Value) != nil
+	return a
+}
+
+func astSubset(set [][]byte) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	r := make([]string, 0, len(set))
+	for _, s := range set {
+		r = append(r, string(s))
+	}
+	return r
+}
@@ -0,0 +1,117 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Visitor that dumps statements with indentation by depth.
+type astDumper struct {
+	buf   *bytes.Buffer
+	depth int
+}
+
+func (d astDumper) Visit(s *Stmt) Visitor {
+	_, _ = fmt.Fprintf(d.buf, "%s%d:%d %s", strings.Repeat("  ", d.depth), s.Line, s.Col, s.Kind)
+	if len(s.Dst.Raw) > 0 {
+		_, _ = fmt.Fprintf(d.buf, " dst=%s%v", s.Dst.Raw, s.Dst.Tokens)
+	}
+	switch {
+	case s.Src.Static:
+		_, _ = fmt.Fprintf(d.buf, " static=%s", s.Src.Value)
+	case len(s.Src.Path.Raw) > 0:
+		_, _ = fmt.Fprintf(d.buf, " src=%s", s.Src.Path.Raw)
+	}
+	if len(s.Src.Subset) > 0 {
+		_, _ = fmt.Fprintf(d.buf, " subset=%v", s.Src.Subset)
+	}
+	for _, m := range s.Src.Mods {
+		_, _ = fmt.Fprintf(d.buf, " mod=%s", d.call(&m))
+	}
+	if s.Call != nil {
+		_, _ = fmt.Fprintf(d.buf, " call=%s", d.call(s.Call))
+	}
+	if c := s.Cond; c != nil {
+		if c.Helper != nil {
+			_, _ = fmt.Fprintf(d.buf, " helper=%s", d.call(c.Helper))
+		}
+		if len(c.Var) > 0 {
+			_, _ = fmt.Fprintf(d.buf, " var=%s,%s type=%s", c.Var, c.VarOK, c.Type)
+		}
+		if len(c.Op) > 0 || len(c.Left.Value) > 0 {
+			_, _ = fmt.Fprintf(d.buf, " cond=%s%s%s", d.arg(&c.Left), c.Op, d.arg(&c.Right))
+		}
+	}
+	if s.Ternary {
+		d.buf.WriteString(" ternary")
+	}
+	if s.Loop != nil {
+		_, _ = fmt.Fprintf(d.buf, " key=%s val=%s", s.Loop.Key, s.Loop.Val)
+	}
+	if len(s.Flag) > 0 {
+		_, _ = fmt.Fprintf(d.buf, " flag=%s", s.Flag)
+	}
+	d.buf.WriteByte('\n')
+	d.depth++
+	return d
+}
+
+func (d astDumper) call(c *Call) string {
+	args := make([]string, 0, len(c.Args))
+	for i := range c.Args {
+		args = append(args, d.arg(&c.Args[i]))
+	}
+	return c.Name + "(" + strings.Join(args, ",") + ")"
+}
+
+func (d astDumper) arg(a *Arg) string {
+	if a.Static {
+		return a.Kind.String() + ":" + a.Value
+	}
+	return a.Value
+}
+
+func TestAST(t *testing.T) {
+	t.Run("sample", func(t *testing.T) {
+		src, _ := os.ReadFile("testdata/ast/sample.dec")
+		expect, _ := os.ReadFile("testdata/ast/sample.txt")
+		tree, err := Parse(src, ModeLax)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		Walk(tree, astDumper{buf: &buf})
+		if !bytes.Equal(buf.Bytes(), expect) {
+			t.Errorf("AST mismatch, need:\n%s\ngot:\n%s", expect, buf.String())
+		}
+
+		ast := tree.AST()
+		if len(ast.Funcs) != 1 || ast.Funcs[0].Name != "norm" || ast.Consts["Fallback"] != `"N/D"` {
+			t.Errorf("unexpected declarations: %+v %+v", ast.Funcs, ast.Consts)
+		}
+	})
+	t.Run("skip", func(t *testing.T) {
+		// Nil visitor stops walking of nested statements.
+		tree, _ := Parse([]byte("for i := 0; i < 10; i++ {\n  obj.Id = i\n}\nobj.Status = 1\n"))
+		var n int
+		Walk(tree, visitorFunc(func(s *Stmt) bool {
+			n++
+			return false
+		}))
+		if n != 2 {
+			t.Errorf("expected 2 visits, got %d", n)
+		}
+	})
+}
+
+type visitorFunc func(s *Stmt) bool
+
+func (f visitorFunc) Visit(s *Stmt) Visitor {
+	if f(s) {
+		return f
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/koykov/byteconv"
+	"github.com/koykov/inspector"
+	"github.com/koykov/vector"
+)
+
+// Name matching strategy of automap.
+type amStrategy int
+
+const (
+	// Case-insensitive matching, eg: field "FullName" matches keys "fullname", "FULLNAME", ...
+	amFold amStrategy = iota
+	// Source keys are in snake_case, eg: field "FullName" matches key "full_name".
+	amSnake
+	// Source keys are in camelCase, eg: field "FullName" matches key "fullName".
+	amCamel
+)
+
+func (s amStrategy) String() string {
+	switch s {
+	case amSnake:
+		return "snake"
+	case amCamel:
+		return "camel"
+	default:
+		return "fold"
+	}
+}
+
+// Automap options of the node and cache of mapping plans.
+//
+// Plans builds once per pair of destination/source types and reuses in further calls. Keys of vector sources can't be
+// resolved in advance, so fields of plans cache keys matched case-insensitively.
+type automap struct {
+	strategy amStrategy
+	except   []string
+	// Pairs of destination field and source key.
+	rename [][2]string
+
+	mux   sync.RWMutex
+	plans map[amPair]*amPlan
+}
+
+// Pair of destination and source types.
+type amPair struct {
+	dst, src reflect.Type
+}
+
+// Mapping plan.
+type amPlan struct {
+	fields []amField
+}
+
+// Mapping rule of the single destination field.
+type amField struct {
+	// Destination field name.
+	dst string
+	// Source key (field name in case of struct source).
+	src string
+	// Flag indicates that key should be compared case-insensitively.
+	fold bool
+	// Key of vector source matched case-insensitively last time (see amField.lookFold()).
+	key atomic.Value
+}
+
+// Parse automap options, eg: `except(Id, Status) rename(Name: full_name) strategy(snake)`.
+func parseAutomapOpts(p []byte) (*automap, error) {
+	am := &automap{}
+	p = bytes.TrimSpace(p)
+	for len(p) > 0 {
+		m := reAutomapOpt.FindSubmatchIndex(p)
+		if m == nil || m[0] != 0 {
+			return nil, fmt.Errorf("%w '%s'", ErrAutomapOpt, p)
+		}
+		name, args := p[m[2]:m[3]], p[m[4]:m[5]]
+		switch string(name) {
+		case "except":
+			for _, a := range bytes.Split(args, comma) {
+				if a = bytes.TrimSpace(a); len(a) > 0 {
+					am.except = append(am.except, string(a))
+				}
+			}
+		case "rename":
+			for _, a := range bytes.Split(args, comma) {
+				if a = bytes.TrimSpace(a); len(a) == 0 {
+					continue
+				}
+				kv := bytes.SplitN(a, colon, 2)
+				if len(kv) != 2 {
+					return nil, fmt.Errorf("%w: rename '%s'", ErrAutomapOpt, a)
+				}
+				am.rename = append(am.rename, [2]string{string(bytes.TrimSpace(kv[0])), string(bytes.TrimSpace(kv[1]))})
+			}
+		case "strategy":
+			switch string(bytes.TrimSpace(args)) {
+			case "fold":
+				am.strategy = amFold
+			case "snake":
+				am.strategy = amSnake
+			case "camel":
+				am.strategy = amCamel
+			default:
+				return nil, fmt.Errorf("%w: strategy '%s'", ErrAutomapOpt, args)
+			}
+		}
+		p = bytes.TrimSpace(p[m[1]:])
+	}
+	return am, nil
+}
+
+// Get mapping plan for given destination and source values.
+func (am *automap) plan(dst, src any, srcIns inspector.Inspector) *amPlan {
+	pair := amPair{dst: reflect.TypeOf(dst)}
+	if _, ok := src.(*vector.Node); !ok {
+		pair.src = reflect.TypeOf(src)
+	}
+	am.mux.RLock()
+	plan, ok := am.plans[pair]
+	am.mux.RUnlock()
+	if ok {
+		return plan
+	}
+
+	plan = am.buildPlan(pair, src, srcIns)
+	am.mux.Lock()
+	if am.plans == nil {
+		am.plans = make(map[amPair]*amPlan)
+	}
+	am.plans[pair] = plan
+	am.mux.Unlock()
+	return plan
+}
+
+// Build mapping plan.
+//
+// Inspectors can't enumerate fields, so names of candidate fields are taken from types. Fields of struct source are
+// checked then through inspector of source, thus plan contains only fields that can be read during mapping. Destination
+// fields aren't checked since nested objects may be not allocated yet, they are written through inspector anyway.
+func (am *automap) buildPlan(pair amPair, src any, srcIns inspector.Inspector) *amPlan {
+	plan := &amPlan{}
+	dt := indirectType(pair.dst)
+	if dt == nil || dt.Kind() != reflect.Struct {
+		return plan
+	}
+	var st reflect.Type
+	if pair.src != nil {
+		if st = indirectType(pair.src); st == nil || st.Kind() != reflect.Struct || srcIns == nil {
+			return plan
+		}
+	}
+	var buf any
+	for i := 0; i < dt.NumField(); i++ {
+		f := dt.Field(i)
+		if !f.IsExported() || !isAutomapKind(f.Type) || am.excepted(f.Name) {
+			continue
+		}
+		// Explicitly renamed keys compares as is.
+		fld := amField{dst: f.Name, src: am.srcKey(f.Name), fold: am.strategy == amFold && !am.renamed(f.Name)}
+		if st != nil {
+			// Struct source, so resolve source field right now.
+			var ok bool
+			for j := 0; j < st.NumField() && !ok; j++ {
+				sf := st.Field(j)
+				if !sf.IsExported() || (sf.Name != fld.src && !(fld.fold && strings.EqualFold(sf.Name, fld.src))) {
+					continue
+				}
+				if buf = nil; srcIns.GetTo(src, &buf, sf.Name) == nil && buf != nil {
+					fld.src, fld.fold, ok = sf.Name, false, true
+				}
+			}
+			if !ok {
+				continue
+			}
+		}
+		plan.fields = append(plan.fields, amField{dst: fld.dst, src: fld.src, fold: fld.fold})
+	}
+	return plan
+}
+
+// Check if field is excluded from mapping.
+func (am *automap) excepted(field string) bool {
+	for i := 0; i < len(am.except); i++ {
+		if am.except[i] == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if field has explicit source key.
+func (am *automap) renamed(field string) bool {
+	for i := 0; i < len(am.rename); i++ {
+		if am.rename[i][0] == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Get source key of the field according rename options and strategy.
+func (am *automap) srcKey(field string) string {
+	for i := 0; i < len(am.rename); i++ {
+		if am.rename[i][0] == field {
+			return am.rename[i][1]
+		}
+	}
+	switch am.strategy {
+	case amSnake:
+		return snakeCase(field)
+	case amCamel:
+		return camelCase(field)
+	default:
+		return field
+	}
+}
+
+// Apply automap node.
+func (ctx *Ctx) automap(r *node) error {
+	// Copy paths since they will be extended by field names.
+	ctx.bufAD = append(ctx.bufAD[:0], ctx.dstPath(r)...)
+	ctx.bufAS = append(ctx.bufAS[:0], ctx.srcPath(r)...)
+	if len(ctx.bufAD) == 0 || len(ctx.bufAS) == 0 {
+		return nil
+	}
+
+	var dv *ctxVar
+	for i := 0; i < ctx.ln; i++ {
+		if ctx.vars[i].key == ctx.bufAD[0] {
+			dv = &ctx.vars[i]
+			break
+		}
+	}
+	if dv == nil || dv.ins == nil {
+		return nil
+	}
+	ctx.bufX = nil
+	if ctx.Err = dv.ins.GetTo(dv.val, &ctx.bufX, ctx.bufAD[1:]...); ctx.Err != nil {
+		return ctx.Err
+	}
+	dst := ctx.bufX
+
+	src, srcIns := ctx.get2(ctx.bufAS, nil)
+	if ctx.Err != nil {
+		return ctx.Err
+	}
+	if src == nil {
+		return nil
+	}
+	node, isNode := src.(*vector.Node)
+	if isNode && node.Type() != vector.TypeObject {
+		return nil
+	}
+
+	plan := r.am.plan(dst, src, srcIns)
+	nd, ns := len(ctx.bufAD), len(ctx.bufAS)
+	for i := 0; i < len(plan.fields); i++ {
+		f := &plan.fields[i]
+		var val any
+		if isNode {
+			var cn *vector.Node
+			if f.fold {
+				cn = f.lookFold(node)
+			} else if cn = node.Get(f.src); cn != nil && cn.Type() == vector.TypeNull {
+				cn = nil
+			}
+			if cn != nil {
+				val = cn
+			}
+		} else {
+			ctx.bufAS = append(ctx.bufAS[:ns], f.src)
+			if val, _ = ctx.get2(ctx.bufAS, nil); ctx.Err != nil {
+				return ctx.Err
+			}
+		}
+		if val == nil {
+			continue
+		}
+		ctx.bufAD = append(ctx.bufAD[:nd], f.dst)
+		if ctx.Err = dv.ins.SetWithBuffer(dv.val, val, ctx, ctx.bufAD[1:]...); ctx.Err != nil {
+			return ctx.Err
+		}
+	}
+	return nil
+}
+
+// Find child node by source key using case-insensitive comparison.
+//
+// Key matched last time is checked first, so sources of the same shape are matched once and further lookups are
+// direct. Full scan is performed only if the key is missing in the source.
+func (f *amField) lookFold(node *vector.Node) (r *vector.Node) {
+	if key, ok := f.key.Load().(string); ok {
+		if r = node.Get(key); r != nil && r.Type() != vector.TypeNull {
+			return
+		}
+		r = nil
+	}
+	node.Each(func(_ int, cn *vector.Node) {
+		if r == nil && cn.Type() != vector.TypeNull && bytes.EqualFold(cn.KeyBytes(), byteconv.S2B(f.src)) {
+			r = cn
+		}
+	})
+	if r != nil {
+		f.key.Store(string(r.KeyBytes()))
+	}
+	return
+}
+
+// Get type after all pointer dereferences.
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Check if field type is suitable for automap.
+//
+// Only scalar fields (numbers, bools, strings and bytes) are mapped.
+func isAutomapKind(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	default:
+		return false
+	}
+}
+
+// Convert Go field name to snake_case, eg: "FullName" -> "full_name", "UserID" -> "user_id".
+func snakeCase(s string) string {
+	r := []rune(s)
+	var buf strings.Builder
+	for i := 0; i < len(r); i++ {
+		if unicode.IsUpper(r[i]) {
+			if i > 0 && (unicode.IsLower(r[i-1]) || unicode.IsDigit(r[i-1]) ||
+				(i+1 < len(r) && unicode.IsLower(r[i+1]) && unicode.IsUpper(r[i-1]))) {
+				buf.WriteByte('_')
+			}
+			buf.WriteRune(unicode.ToLower(r[i]))
+			continue
+		}
+		buf.WriteRune(r[i])
+	}
+	return buf.String()
+}
+
+// Convert Go field name to camelCase, eg: "FullName" -> "fullName", "ID" -> "id", "HTTPCode" -> "httpCode".
+func camelCase(s string) string {
+	r := []rune(s)
+	for i := 0; i < len(r) && unicode.IsUpper(r[i]); i++ {
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			// Keep first letter of the next word.
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
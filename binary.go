@@ -0,0 +1,627 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/koykov/byteconv"
+)
+
+// BinaryVersion is a version of binary format of trees and snapshots.
+//
+// Binary format reflects internal structure of the tree, so version increments on any change of it. Data of other
+// versions is rejected and must be rebuilt from the sources.
+const BinaryVersion = 1
+
+var (
+	binTreeMagic = []byte("DECT")
+	binSnapMagic = []byte("DECS")
+)
+
+// Bits of node flags in binary format.
+const (
+	binDstDyn = 1 << iota
+	binSrcDyn
+	binPush
+	binStatic
+	binGetter
+	binCallback
+	binLoopCntStatic
+	binLoopLimStatic
+	binCondStaticL
+	binCondStaticR
+	binCaseStaticL
+	binCaseStaticR
+	binAutomap
+)
+
+// MarshalBinary encodes the tree to compact binary format.
+//
+// Unlike JSON, binary format keeps nodes as is, so unmarshalling doesn't need parsing and is much faster. Functions
+// are stored by names and re-resolves during unmarshalling.
+func (t *Tree) MarshalBinary() ([]byte, error) {
+	w := binWriter{buf: append([]byte(nil), binTreeMagic...)}
+	w.uvarint(BinaryVersion)
+	w.tree(t)
+	return w.buf, nil
+}
+
+// UnmarshalBinary builds the tree from data made by MarshalBinary.
+//
+// Functions are resolving in strict mode, so all of them must be registered. Use ParseBinary to specify parse mode.
+func (t *Tree) UnmarshalBinary(data []byte) error {
+	tree, err := ParseBinary(data)
+	if err != nil {
+		return err
+	}
+	*t = *tree
+	return nil
+}
+
+// ParseBinary builds the tree from data made by Tree.MarshalBinary.
+//
+// Unknown functions are handled according parse mode (see ParseMode), other options don't matter. Imported libraries
+// must be registered before.
+func ParseBinary(data []byte, opts ...ParseOption) (*Tree, error) {
+	r := newBinReader(data, opts)
+	r.header(binTreeMagic)
+	tree := r.tree()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return tree, nil
+}
+
+// SaveSnapshot writes all registered decoders to w in binary format.
+//
+// Decoders are written in order of registration, thus libraries and parents go before decoders that use them.
+func SaveSnapshot(w io.Writer) error {
+	decDB.mux.RLock()
+	list := append([]*Decoder(nil), decDB.buf...)
+	decDB.mux.RUnlock()
+
+	bw := binWriter{buf: append([]byte(nil), binSnapMagic...)}
+	bw.uvarint(BinaryVersion)
+	bw.uvarint(uint64(len(list)))
+	for i := 0; i < len(list); i++ {
+		dec := list[i]
+		bw.int(dec.ID)
+		bw.str(dec.Key)
+		bw.tree(dec.orig)
+	}
+	_, err := w.Write(bw.buf)
+	return err
+}
+
+// LoadSnapshot reads decoders made by SaveSnapshot and registers them.
+//
+// Decoders are registered the same way as RegisterDecoder does, so existing decoders with the same IDs or keys are
+// overwritten. Unknown functions are handled according parse mode (see ParseMode). Loading stops on the first error,
+// but decoders read before keep registered.
+func LoadSnapshot(r io.Reader, opts ...ParseOption) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	br := newBinReader(data, opts)
+	br.header(binSnapMagic)
+	n := br.uvarint()
+	for i := uint64(0); i < n && br.err == nil; i++ {
+		id, key := br.int(), br.str()
+		tree := br.tree()
+		if br.err != nil {
+			break
+		}
+		decDB.set(id, key, tree)
+	}
+	return br.err
+}
+
+// Binary encoder.
+type binWriter struct {
+	buf []byte
+}
+
+func (w *binWriter) uvarint(x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], x)
+	w.buf = append(w.buf, b[:n]...)
+}
+
+func (w *binWriter) int(x int) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], int64(x))
+	w.buf = append(w.buf, b[:n]...)
+}
+
+func (w *binWriter) bool(x bool) {
+	var b byte
+	if x {
+		b = 1
+	}
+	w.buf = append(w.buf, b)
+}
+
+func (w *binWriter) bytes(p []byte) {
+	w.uvarint(uint64(len(p)))
+	w.buf = append(w.buf, p...)
+}
+
+func (w *binWriter) str(s string) {
+	w.bytes(byteconv.S2B(s))
+}
+
+func (w *binWriter) strs(list []string) {
+	w.uvarint(uint64(len(list)))
+	for i := 0; i < len(list); i++ {
+		w.str(list[i])
+	}
+}
+
+func (w *binWriter) bytesList(list [][]byte) {
+	w.uvarint(uint64(len(list)))
+	for i := 0; i < len(list); i++ {
+		w.bytes(list[i])
+	}
+}
+
+func (w *binWriter) tree(t *Tree) {
+	w.uvarint(t.hsum)
+	w.str(t.parent)
+	w.strs(t.imports)
+	keys := make([]string, 0, len(t.consts))
+	for k := range t.consts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		w.str(k)
+		w.bytes(t.consts[k])
+	}
+	w.uvarint(uint64(len(t.udfs)))
+	for i := 0; i < len(t.udfs); i++ {
+		f := t.udfs[i]
+		w.bytes(f.name)
+		w.bool(f.macro)
+		w.bytesList(f.params)
+		w.nodes(f.body)
+	}
+	w.nodes(t.nodes)
+}
+
+func (w *binWriter) nodes(nodes []node) {
+	w.uvarint(uint64(len(nodes)))
+	for i := 0; i < len(nodes); i++ {
+		w.node(&nodes[i])
+	}
+}
+
+func (w *binWriter) node(n *node) {
+	var flags uint64
+	for i, ok := range [...]bool{
+		n.dstDyn, n.srcDyn, n.push, n.static, n.getter != nil, n.callback != nil, n.loopCntStatic, n.loopLimStatic,
+		n.condStaticL, n.condStaticR, n.caseStaticL, n.caseStaticR, n.am != nil,
+	} {
+		// Order must be in sync with bin* constants.
+		if ok {
+			flags |= 1 << i
+		}
+	}
+	w.uvarint(uint64(n.typ))
+	w.uvarint(flags)
+	w.int(n.line)
+	w.int(n.col)
+	w.bytes(n.dst)
+	w.bytes(n.src)
+	w.bytes(n.ins)
+	w.strs(n.dsta)
+	w.strs(n.srca)
+	w.bytesList(n.subset)
+	w.uvarint(uint64(len(n.mod)))
+	for i := 0; i < len(n.mod); i++ {
+		w.bytes(n.mod[i].id)
+		w.args(n.mod[i].arg)
+	}
+	w.args(n.arg)
+
+	w.bytes(n.loopKey)
+	w.bytes(n.loopVal)
+	w.bytes(n.loopSrc)
+	w.bytes(n.loopCnt)
+	w.bytes(n.loopCntInit)
+	w.uvarint(uint64(n.loopCntOp))
+	w.uvarint(uint64(n.loopCondOp))
+	w.bytes(n.loopLim)
+	w.int(n.loopBrkD)
+	w.bytes(n.loopLbl)
+
+	w.bytes(n.withVar)
+	w.bytes(n.subKey)
+	w.bytes(n.flag)
+	if n.am != nil {
+		w.uvarint(uint64(n.am.strategy))
+		w.strs(n.am.except)
+		w.uvarint(uint64(len(n.am.rename)))
+		for i := 0; i < len(n.am.rename); i++ {
+			w.str(n.am.rename[i][0])
+			w.str(n.am.rename[i][1])
+		}
+	}
+
+	w.bytes(n.condL)
+	w.bytes(n.condOKL)
+	w.bytes(n.condR)
+	w.bytes(n.condOKR)
+	w.uvarint(uint64(n.condOp))
+	w.bytes(n.condHlp)
+	w.args(n.condHlpArg)
+	w.bytes(n.condIns)
+	w.uvarint(uint64(n.condLC))
+
+	w.bytes(n.switchArg)
+
+	w.bytes(n.caseL)
+	w.bytes(n.caseR)
+	w.uvarint(uint64(n.caseOp))
+	w.bytes(n.caseHlp)
+	w.args(n.caseHlpArg)
+
+	w.nodes(n.child)
+}
+
+func (w *binWriter) args(args []*arg) {
+	w.uvarint(uint64(len(args)))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		w.bytes(a.val)
+		w.bytesList(a.subset)
+		w.bool(a.static)
+		w.uvarint(uint64(a.kind))
+	}
+}
+
+// Binary decoder.
+//
+// Reader keeps the first error and returns zero values after it, so errors may be checked once at the end.
+type binReader struct {
+	buf []byte
+	off int
+	err error
+	// Parser keeps parse mode and functions declared in the current tree.
+	p *parser
+}
+
+func newBinReader(data []byte, opts []ParseOption) *binReader {
+	p := &parser{}
+	for i := 0; i < len(opts); i++ {
+		opts[i].apply(p)
+	}
+	// Copy data since nodes refer to it.
+	return &binReader{buf: append([]byte(nil), data...), p: p}
+}
+
+// Check magic bytes and version.
+func (r *binReader) header(magic []byte) {
+	if len(r.buf) < len(magic) || !bytes.Equal(r.buf[:len(magic)], magic) {
+		r.fail()
+		return
+	}
+	r.off = len(magic)
+	if v := r.uvarint(); r.err == nil && v != BinaryVersion {
+		r.err = fmt.Errorf("%w: %d", ErrVersion, v)
+	}
+}
+
+func (r *binReader) fail() {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w at offset %d", ErrCorruptedData, r.off)
+	}
+}
+
+func (r *binReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(r.buf[r.off:])
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.off += n
+	return x
+}
+
+// Read length of the list and check that data has at least one byte per item.
+func (r *binReader) count() int {
+	n := r.uvarint()
+	if n > uint64(len(r.buf)-r.off) {
+		r.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (r *binReader) int() int {
+	if r.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(r.buf[r.off:])
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.off += n
+	return int(x)
+}
+
+func (r *binReader) bool() bool {
+	if r.err != nil {
+		return false
+	}
+	if r.off >= len(r.buf) {
+		r.fail()
+		return false
+	}
+	r.off++
+	return r.buf[r.off-1] == 1
+}
+
+func (r *binReader) bytes() []byte {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	p := r.buf[r.off : r.off+n : r.off+n]
+	r.off += n
+	return p
+}
+
+func (r *binReader) str() string {
+	return string(r.bytes())
+}
+
+func (r *binReader) strs() []string {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	list := make([]string, n)
+	for i := 0; i < n; i++ {
+		list[i] = r.str()
+	}
+	return list
+}
+
+func (r *binReader) bytesList() [][]byte {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	list := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		list[i] = r.bytes()
+	}
+	return list
+}
+
+func (r *binReader) tree() *Tree {
+	p := r.p
+	p.udfs, p.imp, p.libs = nil, nil, nil
+	t := &Tree{gen: regGeneration()}
+	t.hsum = r.uvarint()
+	t.parent = r.str()
+	t.imports = r.strs()
+	for _, key := range t.imports {
+		lib := decDB.getKey(key)
+		if lib == nil {
+			if r.err == nil {
+				r.err = fmt.Errorf("%w: '%s'", ErrLibraryNotFound, key)
+			}
+			return nil
+		}
+		p.imp = append(p.imp, lib.orig.udfs...)
+	}
+	if n := r.count(); n > 0 {
+		t.consts = make(map[string][]byte, n)
+		for i := 0; i < n; i++ {
+			k := r.str()
+			t.consts[k] = r.bytes()
+		}
+	}
+	for i, n := 0, r.count(); i < n; i++ {
+		f := &udf{name: r.bytes(), macro: r.bool(), params: r.bytesList()}
+		f.body = r.nodes()
+		// Register function after its body, the same as parser does.
+		p.udfs = append(p.udfs, f)
+	}
+	t.udfs = p.udfs
+	t.nodes = r.nodes()
+	if r.err != nil {
+		return nil
+	}
+	return t
+}
+
+func (r *binReader) nodes() Ruleset {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	nodes := make(Ruleset, n)
+	for i := 0; i < n; i++ {
+		r.node(&nodes[i])
+	}
+	return nodes
+}
+
+func (r *binReader) node(n *node) {
+	n.typ = rtype(r.uvarint())
+	flags := r.uvarint()
+	n.dstDyn = flags&binDstDyn != 0
+	n.srcDyn = flags&binSrcDyn != 0
+	n.push = flags&binPush != 0
+	n.static = flags&binStatic != 0
+	n.loopCntStatic = flags&binLoopCntStatic != 0
+	n.loopLimStatic = flags&binLoopLimStatic != 0
+	n.condStaticL = flags&binCondStaticL != 0
+	n.condStaticR = flags&binCondStaticR != 0
+	n.caseStaticL = flags&binCaseStaticL != 0
+	n.caseStaticR = flags&binCaseStaticR != 0
+	n.line = r.int()
+	n.col = r.int()
+	n.dst = r.bytes()
+	n.src = r.bytes()
+	n.ins = r.bytes()
+	n.dsta = r.strs()
+	n.srca = r.strs()
+	n.subset = r.bytesList()
+	if l := r.count(); l > 0 {
+		n.mod = make([]mod, 0, l)
+		for i := 0; i < l; i++ {
+			m := mod{id: r.bytes(), arg: r.args()}
+			// Unknown modifiers skips in lax mode, see parser.extractMods().
+			if m.fn = r.bindMod(m.id); m.fn != nil {
+				n.mod = append(n.mod, m)
+			}
+		}
+	}
+	n.arg = r.args()
+
+	n.loopKey = r.bytes()
+	n.loopVal = r.bytes()
+	n.loopSrc = r.bytes()
+	n.loopCnt = r.bytes()
+	n.loopCntInit = r.bytes()
+	n.loopCntOp = op(r.uvarint())
+	n.loopCondOp = op(r.uvarint())
+	n.loopLim = r.bytes()
+	n.loopBrkD = r.int()
+	n.loopLbl = r.bytes()
+
+	n.withVar = r.bytes()
+	n.subKey = r.bytes()
+	n.flag = r.bytes()
+	if flags&binAutomap != 0 {
+		n.am = &automap{strategy: amStrategy(r.uvarint()), except: r.strs()}
+		if l := r.count(); l > 0 {
+			n.am.rename = make([][2]string, l)
+			for i := 0; i < l; i++ {
+				n.am.rename[i] = [2]string{r.str(), r.str()}
+			}
+		}
+	}
+
+	n.condL = r.bytes()
+	n.condOKL = r.bytes()
+	n.condR = r.bytes()
+	n.condOKR = r.bytes()
+	n.condOp = op(r.uvarint())
+	n.condHlp = r.bytes()
+	n.condHlpArg = r.args()
+	n.condIns = r.bytes()
+	n.condLC = lc(r.uvarint())
+
+	n.switchArg = r.bytes()
+
+	n.caseL = r.bytes()
+	n.caseR = r.bytes()
+	n.caseOp = op(r.uvarint())
+	n.caseHlp = r.bytes()
+	n.caseHlpArg = r.args()
+
+	n.child = r.nodes()
+
+	if r.err != nil {
+		return
+	}
+	if flags&binGetter != 0 {
+		n.getter = r.bindGetter(n.src)
+	}
+	if flags&binCallback != 0 {
+		n.callback = r.bindCallback(n.src)
+	}
+	switch {
+	case n.typ == typeCondOK && len(n.condHlp) > 0,
+		n.typ == typeCond && len(n.condHlp) > 0 && n.condLC == lcNone:
+		r.bindCondHlp(n, n.condHlp)
+	case n.typ == typeCase && len(n.caseHlp) > 0:
+		r.bindCondHlp(n, n.caseHlp)
+	}
+}
+
+func (r *binReader) args() []*arg {
+	n := r.count()
+	if n == 0 {
+		return nil
+	}
+	args := make([]*arg, n)
+	for i := 0; i < n; i++ {
+		a := &arg{val: r.bytes(), subset: r.bytesList(), static: r.bool(), kind: ArgKind(r.uvarint())}
+		a.global = !a.static && GetGlobal(byteconv.B2S(a.val)) != nil
+		args[i] = a
+	}
+	return args
+}
+
+// Resolve modifier by name, see parser.bindMod().
+func (r *binReader) bindMod(name []byte) ModFn {
+	if r.err != nil {
+		return nil
+	}
+	fn := GetModFn(byteconv.B2S(name))
+	if f := r.p.getUDF(name); fn == nil && f != nil && !f.macro {
+		fn = f.modifier()
+	}
+	if fn == nil && r.p.mode == ModeStrict {
+		r.err = fmt.Errorf("%w: '%s'", ErrModNotFound, name)
+	} else if fn == nil {
+		fn, _ = r.p.unknownMod(name, 0)
+	}
+	return fn
+}
+
+func (r *binReader) bindGetter(name []byte) GetterFn {
+	fn := GetGetterFn(byteconv.B2S(name))
+	if f := r.p.getUDF(name); fn == nil && f != nil && !f.macro {
+		fn = f.getter()
+	}
+	if fn == nil {
+		fn, _ = r.p.unknownGetter(name, 0)
+	}
+	if fn == nil {
+		// Parser doesn't allow unknown getters in lax mode as well.
+		r.err = fmt.Errorf("%w: '%s'", ErrGetterNotFound, name)
+	}
+	return fn
+}
+
+func (r *binReader) bindCallback(name []byte) CallbackFn {
+	fn := GetCallbackFn(byteconv.B2S(name))
+	if f := r.p.getUDF(name); fn == nil && f != nil && f.macro {
+		fn = f.callback()
+	}
+	if fn == nil {
+		fn, _ = r.p.unknownCallback(name, 0)
+	}
+	if fn == nil {
+		// Parser doesn't allow unknown callbacks in lax mode as well.
+		r.err = fmt.Errorf("%w: '%s'", ErrCallbackNotFound, name)
+	}
+	return fn
+}
+
+// Bind condition helper, unknown helpers resolves at decode time in lax and lazy modes.
+func (r *binReader) bindCondHlp(n *node, name []byte) {
+	if n.typ == typeCondOK {
+		n.condOKFn = GetCondOKFn(byteconv.B2S(name))
+	} else {
+		n.condFn = GetCondFn(byteconv.B2S(name))
+	}
+	if n.condFn == nil && n.condOKFn == nil && r.p.mode == ModeStrict {
+		r.err = fmt.Errorf("%w: '%s'", ErrCondHlpNotFound, name)
+	}
+}
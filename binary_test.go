@@ -0,0 +1,165 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/inspector/testobj_ins"
+	"github.com/koykov/jsonvector"
+)
+
+func TestBinary(t *testing.T) {
+	t.Run("roundtrip", func(t *testing.T) {
+		var files []string
+		for _, dir := range []string{"parser", "decoder", "mod", "getter", "fmt", "datetime", "source"} {
+			list, _ := filepath.Glob("testdata/" + dir + "/*.dec")
+			files = append(files, list...)
+		}
+		for _, file := range files {
+			// Some fixtures use unregistered functions, lazy mode keeps them in the tree.
+			src, _ := os.ReadFile(file)
+			tree, err := Parse(src, ModeLazy)
+			if err != nil {
+				t.Fatalf("%s: %s", file, err)
+			}
+			r, _ := tree.MarshalBinary()
+			tree1, err := ParseBinary(r, ModeLazy)
+			if err != nil {
+				t.Errorf("%s: %s", file, err)
+				continue
+			}
+			if !bytes.Equal(tree.HumanReadable(), tree1.HumanReadable()) {
+				t.Errorf("%s: tree mismatch, need:\n%s\ngot:\n%s", file, tree.HumanReadable(), tree1.HumanReadable())
+				continue
+			}
+			if r1, _ := tree1.MarshalBinary(); !bytes.Equal(r, r1) {
+				t.Errorf("%s: repeated binary mismatch", file)
+			}
+		}
+	})
+	t.Run("version", func(t *testing.T) {
+		tree, _ := Parse([]byte(`obj.Id = jso.identifier`))
+		r, _ := tree.MarshalBinary()
+		r[len(binTreeMagic)]++
+		if _, err := ParseBinary(r); !errors.Is(err, ErrVersion) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("corrupted", func(t *testing.T) {
+		tree, _ := Parse([]byte(`obj.Id = jso.identifier|default("N/D")`))
+		r, _ := tree.MarshalBinary()
+		for i := 0; i < len(r); i++ {
+			if _, err := ParseBinary(r[:i]); !errors.Is(err, ErrCorruptedData) && !errors.Is(err, ErrVersion) {
+				t.Errorf("unexpected error at length %d: %v", i, err)
+			}
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		tree, _ := Parse([]byte(`obj.Id = jso.identifier|binaryTestMod()`), ModeLazy)
+		r, _ := tree.MarshalBinary()
+		if _, err := ParseBinary(r); !errors.Is(err, ErrModNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := ParseBinary(r, ModeLazy); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("lax", func(t *testing.T) {
+		// Modifier known at marshalling time, but missing at parsing, must be skipped.
+		RegisterModFnNS("testns", "binaryLaxMod", "", func(_ *Ctx, buf *any, _ any, _ []any) error {
+			*buf = "mod"
+			return nil
+		})
+		tree, _ := Parse([]byte(`obj.Id = jso.identifier|testns::binaryLaxMod()`))
+		r, _ := tree.MarshalBinary()
+		r = bytes.Replace(r, []byte("binaryLaxMod"), []byte("binaryLaxMox"), -1)
+		if _, err := ParseBinary(r); !errors.Is(err, ErrModNotFound) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		tree1, err := ParseBinary(r, ModeLax)
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("binary/lax", tree1)
+		ctx := NewCtx()
+		obj := &testobj.TestObject{}
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(jsonSrc["src"])
+		ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+		ctx.SetVector("jso", vec)
+		if err = Decode("binary/lax", ctx); err != nil || ctx.Err != nil {
+			t.Error(err, ctx.Err)
+		}
+		assertS(t, "Id", obj.Id, "xf44e")
+
+		// Unknown getters and callbacks aren't allowed in lax mode, as in parser.
+		for _, src := range []string{`obj.Id = testns::binaryLaxGetter()`, `testns::binaryLaxCallback(obj.Id)`} {
+			tree, err = Parse([]byte(src), ModeLazy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, _ = tree.MarshalBinary()
+			if _, err = Parse([]byte(src), ModeLax); err == nil {
+				t.Errorf("%s: parser error expected", src)
+			}
+			if _, err = ParseBinary(r, ModeLax); !errors.Is(err, ErrGetterNotFound) && !errors.Is(err, ErrCallbackNotFound) {
+				t.Errorf("%s: unexpected error: %v", src, err)
+			}
+		}
+	})
+	t.Run("snapshot", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := SaveSnapshot(&buf); err != nil {
+			t.Fatal(err)
+		}
+		before := make([][]byte, len(decDB.buf))
+		for i, dec := range decDB.buf {
+			before[i], _ = dec.orig.MarshalBinary()
+		}
+		if err := LoadSnapshot(&buf); err != nil {
+			t.Fatal(err)
+		}
+		for i, dec := range decDB.buf {
+			if r, _ := dec.orig.MarshalBinary(); !bytes.Equal(r, before[i]) {
+				t.Errorf("%s: decoder mismatch after loading", dec.Key)
+			}
+		}
+	})
+	// Decoders loaded from snapshot must work.
+	t.Run("decoder0", func(t *testing.T) { testDecoder(t, "src", scenarioDec0) })
+	t.Run("extends", func(t *testing.T) { testDecoder(t, "src", scenarioExtends) })
+	t.Run("udf", func(t *testing.T) { testDecoder(t, "src", scenarioUDF) })
+	t.Run("automap", func(t *testing.T) { testDecoder(t, "src", scenarioAutomap) })
+}
+
+func BenchmarkBinary(b *testing.B) {
+	// Sample uses unregistered functions, so it parses in lazy mode.
+	src, _ := os.ReadFile("testdata/source/sample.dec")
+	b.Run("parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			// Use flags to skip cache of parsed trees.
+			if _, err := Parse(src, Flags{}, ModeLazy); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("binary", func(b *testing.B) {
+		tree, err := Parse(src, ModeLazy)
+		if err != nil {
+			b.Fatal(err)
+		}
+		r, _ := tree.MarshalBinary()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := ParseBinary(r, ModeLazy); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,585 @@
+package decoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/koykov/byteconv"
+)
+
+// Builder constructs the tree programmatically, without writing and parsing of decoder source.
+//
+// Builder produces the same tree as parser does for the equivalent source and applies the same checks: functions
+// resolves according parse mode, arguments checks against signatures, loop control statements must be inside loops,
+// etc. First error stops building and returns by Build(), offset in the error means the number of rule (starting from
+// 0) in order of adding.
+type Builder struct {
+	s     *builderState
+	nodes []node
+}
+
+// State shared between builder and builders of nested blocks.
+type builderState struct {
+	p   parser
+	n   int
+	err error
+}
+
+// Value represents expression for Builder: variable, static value or function call.
+type Value struct {
+	// Variable path or name of called function.
+	path, call string
+	// Static value.
+	lit    string
+	static bool
+	kind   ArgKind
+	subset []string
+	args   []Value
+	mods   []valueMod
+	// Type of context variable.
+	ins string
+	err error
+}
+
+type valueMod struct {
+	name string
+	args []Value
+}
+
+// Condition represents condition for Builder: comparison or condition helper call.
+type Condition struct {
+	left, right Value
+	op          string
+	helper      string
+	args        []Value
+}
+
+// NewBuilder makes new builder. Parse mode (see ParseMode) applies to unknown functions the same way as in Parse.
+func NewBuilder(opts ...ParseOption) *Builder {
+	b := &Builder{s: &builderState{}}
+	for i := 0; i < len(opts); i++ {
+		opts[i].apply(&b.s.p)
+	}
+	return b
+}
+
+// Path makes variable value, eg: Path("src.user.name") or Path("src").Subset("id", "title").
+func Path(path string) Value {
+	v := Value{path: path, kind: ArgPath}
+	if !validPath(path) {
+		v.err = fmt.Errorf("%w: '%s'", ErrMalformedPath, path)
+	}
+	return v
+}
+
+// Lit makes static value. Supported types are string, []byte, bool, nil and numeric types.
+func Lit(x any) Value {
+	v := Value{static: true}
+	switch x := x.(type) {
+	case string:
+		v.lit, v.kind = x, ArgString
+	case []byte:
+		v.lit, v.kind = string(x), ArgString
+	case bool:
+		v.lit, v.kind = strconv.FormatBool(x), ArgBool
+	case nil:
+		v.lit, v.kind = "nil", ArgAny
+	case int:
+		v.lit, v.kind = strconv.FormatInt(int64(x), 10), ArgNumber
+	case int8:
+		v.lit, v.kind = strconv.FormatInt(int64(x), 10), ArgNumber
+	case int16:
+		v.lit, v.kind = strconv.FormatInt(int64(x), 10), ArgNumber
+	case int32:
+		v.lit, v.kind = strconv.FormatInt(int64(x), 10), ArgNumber
+	case int64:
+		v.lit, v.kind = strconv.FormatInt(x, 10), ArgNumber
+	case uint:
+		v.lit, v.kind = strconv.FormatUint(uint64(x), 10), ArgNumber
+	case uint8:
+		v.lit, v.kind = strconv.FormatUint(uint64(x), 10), ArgNumber
+	case uint16:
+		v.lit, v.kind = strconv.FormatUint(uint64(x), 10), ArgNumber
+	case uint32:
+		v.lit, v.kind = strconv.FormatUint(uint64(x), 10), ArgNumber
+	case uint64:
+		v.lit, v.kind = strconv.FormatUint(x, 10), ArgNumber
+	case float32:
+		v.lit, v.kind = strconv.FormatFloat(float64(x), 'f', -1, 32), ArgNumber
+	case float64:
+		v.lit, v.kind = strconv.FormatFloat(x, 'f', -1, 64), ArgNumber
+	default:
+		v.err = fmt.Errorf("%w: unsupported static value %T", ErrMalformedValue, x)
+	}
+	return v
+}
+
+// Fn makes call of getter or modifier without variable, eg: Fn("crc32", Path("src.id")) or
+// Fn("new", Lit("TestObject")).As("TestObject").
+func Fn(name string, args ...Value) Value {
+	return Value{call: name, args: args}
+}
+
+// Mod adds modifier to the value, eg: Path("src.name").Mod("default", Lit("N/D")).
+func (v Value) Mod(name string, args ...Value) Value {
+	v.mods = append(v.mods[:len(v.mods):len(v.mods)], valueMod{name: name, args: args})
+	return v
+}
+
+// Subset specifies keys to check sequentially in the variable, eg: Path("src").Subset("id", "title") is an equivalent
+// of "src.{id|title}".
+func (v Value) Subset(keys ...string) Value {
+	v.subset = keys
+	return v
+}
+
+// As specifies type of context variable, eg: Path("src.finance").As("Finance").
+func (v Value) As(typ string) Value {
+	v.ins = typ
+	return v
+}
+
+// Compare makes comparison condition, eg: Compare(Path("src.status"), "==", Lit(1)).
+func Compare(left Value, op string, right Value) Condition {
+	return Condition{left: left, op: op, right: right}
+}
+
+// Helper makes condition helper call, eg: Helper("len", Path("src.items")).
+func Helper(name string, args ...Value) Condition {
+	return Condition{helper: name, args: args}
+}
+
+// Assign adds assignment of the value to the destination. Destination with "[]" suffix (eg: "dst.Tags[]") appends
+// value to the slice.
+func (b *Builder) Assign(dst string, v Value) *Builder {
+	r := node{typ: typeOperator, dst: []byte(dst)}
+	offset := b.next()
+	switch {
+	case !validPath(dst):
+		b.fail(fmt.Errorf("%w: '%s' at offset %d", ErrMalformedPath, dst, offset))
+		return b
+	case v.err != nil:
+		b.fail(fmt.Errorf("%w at offset %d", v.err, offset))
+		return b
+	case len(v.ins) > 0 && !isCtxDst(dst):
+		b.fail(fmt.Errorf("%w: type of non-context variable '%s' at offset %d", ErrMalformedValue, dst, offset))
+		return b
+	}
+	r.ins = []byte(v.ins)
+	switch {
+	case v.static:
+		r.src, r.static = []byte(v.lit), true
+	case len(v.call) > 0:
+		name := []byte(v.call)
+		args, err := b.args(v.args, offset)
+		if err != nil {
+			b.fail(err)
+			return b
+		}
+		// Getter has priority over modifier, see parser.
+		fn := GetGetterFn(v.call)
+		f := b.s.p.getUDF(name)
+		if fn == nil && f != nil && !f.macro {
+			fn = f.getter()
+		}
+		if fn == nil && f == nil && GetModFn(v.call) == nil {
+			if fn, err = b.s.p.unknownGetter(name, offset); err != nil {
+				b.fail(err)
+				return b
+			}
+		}
+		if fn != nil {
+			if len(v.mods) > 0 || len(v.ins) > 0 {
+				b.fail(fmt.Errorf("%w: modifiers of getter '%s' at offset %d", ErrMalformedValue, v.call, offset))
+				return b
+			}
+			r.src, r.getter, r.arg = name, fn, args
+			if err = b.s.p.checkSig(getGetterSig(name), "getter", name, args, offset); err != nil {
+				b.fail(err)
+				return b
+			}
+			break
+		}
+		// Modifier without variable, eg: "new(TestObject)".
+		mfn, err := b.s.p.bindMod(name, args, offset)
+		if err != nil {
+			b.fail(err)
+			return b
+		}
+		if mfn != nil {
+			r.mod = append(r.mod, mod{id: name, fn: mfn, arg: args})
+		}
+		if r.mod, err = b.mods(r.mod, v.mods, offset); err != nil {
+			b.fail(err)
+			return b
+		}
+		if len(r.mod) == 0 {
+			b.fail(fmt.Errorf("unknown getter nor modifier function '%s' at offset %d", v.call, offset))
+			return b
+		}
+	default:
+		var err error
+		r.src, r.subset = []byte(v.path), b.subset(v.subset)
+		if r.mod, err = b.mods(nil, v.mods, offset); err != nil {
+			b.fail(err)
+			return b
+		}
+	}
+	r.tokenizePaths()
+	b.nodes = append(b.nodes, r)
+	return b
+}
+
+// Call adds callback call, eg: Call("reset", Lit("dst.Items")).
+func (b *Builder) Call(name string, args ...Value) *Builder {
+	offset := b.next()
+	bname := []byte(name)
+	a, err := b.args(args, offset)
+	if err != nil {
+		b.fail(err)
+		return b
+	}
+	fn := GetCallbackFn(name)
+	if f := b.s.p.getUDF(bname); fn == nil && f != nil && f.macro {
+		fn = f.callback()
+	}
+	if fn == nil {
+		if fn, err = b.s.p.unknownCallback(bname, offset); err != nil {
+			b.fail(err)
+			return b
+		}
+	}
+	if fn == nil {
+		b.fail(fmt.Errorf("unknown callback function '%s' at offset %d", name, offset))
+		return b
+	}
+	if err = b.s.p.checkSig(getCallbackSig(bname), "callback", bname, a, offset); err != nil {
+		b.fail(err)
+		return b
+	}
+	r := node{typ: typeOperator, src: bname, callback: fn, arg: a}
+	r.tokenizePaths()
+	b.nodes = append(b.nodes, r)
+	return b
+}
+
+// If adds condition block.
+func (b *Builder) If(c Condition, then func(b *Builder)) *Builder {
+	return b.cond(c, then, nil)
+}
+
+// IfElse adds condition block with else branch.
+func (b *Builder) IfElse(c Condition, then, els func(b *Builder)) *Builder {
+	if els == nil {
+		els = func(*Builder) {}
+	}
+	return b.cond(c, then, els)
+}
+
+// Range adds range loop, eg: Range("k", "v", "src.items", ...) is an equivalent of "for k, v := range src.items {".
+// Empty key means "_".
+func (b *Builder) Range(key, val, src string, body func(b *Builder)) *Builder {
+	offset := b.next()
+	if !validPath(src) || (len(key) > 0 && !validName(key)) || (len(val) > 0 && !validName(val)) {
+		b.fail(fmt.Errorf("%w: range loop '%s, %s := range %s' at offset %d", ErrMalformedValue, key, val, src, offset))
+		return b
+	}
+	r := node{typ: typeLoopRange, loopSrc: []byte(src)}
+	if len(key) > 0 && key != "_" {
+		r.loopKey = []byte(key)
+	}
+	if len(val) > 0 {
+		r.loopVal = []byte(val)
+	}
+	b.loop(&r, body)
+	return b
+}
+
+// For adds counter loop, eg: For("i", Lit(0), "<", Lit(10), ...) is an equivalent of "for i := 0; i < 10; i++ {".
+// Counter increments for operators "<", "<=" and "!=", otherwise decrements.
+func (b *Builder) For(counter string, init Value, op string, limit Value, body func(b *Builder)) *Builder {
+	offset := b.next()
+	r := node{typ: typeLoopCount, loopCnt: []byte(counter), loopCondOp: b.s.p.parseOp([]byte(op))}
+	switch {
+	case !validName(counter) || !init.plain() || !limit.plain():
+		b.fail(fmt.Errorf("%w: counter loop '%s' at offset %d", ErrMalformedValue, counter, offset))
+		return b
+	case r.loopCondOp < opEq || r.loopCondOp > opLtq:
+		b.fail(fmt.Errorf("%w '%s' at offset %d", ErrWrongLoopCond, op, offset))
+		return b
+	}
+	r.loopCntInit, r.loopCntStatic = init.raw(), init.static
+	r.loopLim, r.loopLimStatic = limit.raw(), limit.static
+	switch r.loopCondOp {
+	case opLt, opLtq, opNq:
+		r.loopCntOp = opInc
+	default:
+		r.loopCntOp = opDec
+	}
+	b.loop(&r, body)
+	return b
+}
+
+// Break adds break statement of the nearest loop.
+func (b *Builder) Break() *Builder {
+	return b.ctl(typeBreak)
+}
+
+// Continue adds continue statement of the nearest loop.
+func (b *Builder) Continue() *Builder {
+	return b.ctl(typeContinue)
+}
+
+// Return adds return statement.
+func (b *Builder) Return() *Builder {
+	b.next()
+	b.nodes = append(b.nodes, node{typ: typeReturn})
+	return b
+}
+
+// With adds with block, eg: With("dst.Items", "it", ...) is an equivalent of "with dst.Items[+] as it {".
+func (b *Builder) With(dst, as string, body func(b *Builder)) *Builder {
+	offset := b.next()
+	if !validPath(dst) || !validName(as) {
+		b.fail(fmt.Errorf("%w: with block '%s' as '%s' at offset %d", ErrMalformedValue, dst, as, offset))
+		return b
+	}
+	r := node{typ: typeWith, dst: []byte(dst), withVar: []byte(as)}
+	r.tokenizePaths()
+	b.s.p.openBlock(typeWith)
+	r.child = b.sub(body)
+	_ = b.s.p.closeBlock(typeWith)
+	b.nodes = append(b.nodes, r)
+	return b
+}
+
+// Decode adds call of sub-decoder, eg: "decode("address", dst.Address, src.shipping)".
+func (b *Builder) Decode(key, dst, src string) *Builder {
+	offset := b.next()
+	if len(key) == 0 || !validPath(dst) || !validPath(src) {
+		b.fail(fmt.Errorf("%w: decode '%s' at offset %d", ErrMalformedValue, key, offset))
+		return b
+	}
+	r := node{typ: typeDecode, subKey: []byte(key), dst: []byte(dst), src: []byte(src)}
+	r.tokenizePaths()
+	b.nodes = append(b.nodes, r)
+	return b
+}
+
+// Use adds include of sub-decoder, eg: "use "common_headers"".
+func (b *Builder) Use(key string) *Builder {
+	offset := b.next()
+	if len(key) == 0 {
+		b.fail(fmt.Errorf("%w: use of empty key at offset %d", ErrMalformedValue, offset))
+		return b
+	}
+	b.nodes = append(b.nodes, node{typ: typeUse, subKey: []byte(key)})
+	return b
+}
+
+// Build returns built tree or the first error occurred.
+func (b *Builder) Build() (*Tree, error) {
+	if b.s.err != nil {
+		return nil, b.s.err
+	}
+	return &Tree{nodes: b.nodes, gen: regGeneration()}, nil
+}
+
+func (b *Builder) cond(c Condition, then, els func(b *Builder)) *Builder {
+	offset := b.next()
+	r := node{typ: typeCond}
+	if len(c.helper) > 0 {
+		args, err := b.args(c.args, offset)
+		if err != nil {
+			b.fail(err)
+			return b
+		}
+		r.condHlp, r.condHlpArg = []byte(c.helper), args
+		switch c.helper {
+		case "len":
+			r.condLC = lcLen
+		case "cap":
+			r.condLC = lcCap
+		default:
+			if err = b.s.p.bindCondHlp(&r, r.condHlp, args, offset); err != nil {
+				b.fail(err)
+				return b
+			}
+		}
+	} else {
+		r.condOp = b.s.p.parseOp([]byte(c.op))
+		switch {
+		case r.condOp < opEq || r.condOp > opLtq:
+			b.fail(fmt.Errorf("%w: unknown operator '%s' at offset %d", ErrMalformedValue, c.op, offset))
+			return b
+		case !c.left.plain() || !c.right.plain():
+			b.fail(fmt.Errorf("%w: condition sides must be variables or static values at offset %d", ErrMalformedValue,
+				offset))
+			return b
+		}
+		r.condL, r.condStaticL = c.left.raw(), c.left.static
+		r.condR, r.condStaticR = c.right.raw(), c.right.static
+	}
+
+	b.s.p.openBlock(typeCond)
+	sub := b.sub(then)
+	if els != nil {
+		// Assemble branches the same way as parser does.
+		sub = append(sub, node{typ: typeDiv})
+		sub = append(sub, b.sub(els)...)
+	}
+	_ = b.s.p.closeBlock(typeCond)
+	condBranches(&r, sub)
+	b.nodes = append(b.nodes, r)
+	return b
+}
+
+func (b *Builder) loop(r *node, body func(b *Builder)) {
+	b.s.p.openBlock(r.typ)
+	b.s.p.pushLabel(nil)
+	r.child = b.sub(body)
+	b.s.p.popLabel()
+	_ = b.s.p.closeBlock(r.typ)
+	b.nodes = append(b.nodes, *r)
+}
+
+func (b *Builder) ctl(typ rtype) *Builder {
+	offset := b.next()
+	if b.s.p.cl == 0 {
+		b.fail(fmt.Errorf("%w at offset %d", ErrLoopCtlNoLoop, offset))
+		return b
+	}
+	b.nodes = append(b.nodes, node{typ: typ})
+	return b
+}
+
+// Build nodes of nested block.
+func (b *Builder) sub(fn func(b *Builder)) []node {
+	if fn == nil {
+		return nil
+	}
+	c := &Builder{s: b.s}
+	fn(c)
+	return c.nodes
+}
+
+// Bind modifiers of the value.
+func (b *Builder) mods(dst []mod, list []valueMod, offset int) ([]mod, error) {
+	for i := 0; i < len(list); i++ {
+		name := []byte(list[i].name)
+		args, err := b.args(list[i].args, offset)
+		if err != nil {
+			return dst, err
+		}
+		fn, err := b.s.p.bindMod(name, args, offset)
+		if err != nil {
+			return dst, err
+		}
+		if fn == nil {
+			continue
+		}
+		dst = append(dst, mod{id: name, fn: fn, arg: args})
+	}
+	return dst, nil
+}
+
+// Convert values to arguments of function.
+func (b *Builder) args(list []Value, offset int) ([]*arg, error) {
+	r := make([]*arg, 0, len(list))
+	for i := 0; i < len(list); i++ {
+		v := &list[i]
+		if v.err != nil {
+			return r, fmt.Errorf("%w at offset %d", v.err, offset)
+		}
+		if len(v.call) > 0 || len(v.mods) > 0 || len(v.ins) > 0 {
+			return r, fmt.Errorf("%w: arguments must be variables or static values at offset %d", ErrMalformedValue,
+				offset)
+		}
+		a := &arg{
+			val:    v.raw(),
+			subset: b.subset(v.subset),
+			static: v.static,
+			kind:   v.kind,
+		}
+		a.global = !a.static && GetGlobal(byteconv.B2S(a.val)) != nil
+		r = append(r, a)
+	}
+	return r, nil
+}
+
+func (b *Builder) subset(keys []string) [][]byte {
+	if len(keys) == 0 {
+		return nil
+	}
+	r := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		r = append(r, []byte(k))
+	}
+	return r
+}
+
+// Get offset of the next rule.
+func (b *Builder) next() int {
+	b.s.n++
+	return b.s.n - 1
+}
+
+func (b *Builder) fail(err error) {
+	if b.s.err == nil {
+		b.s.err = err
+	}
+}
+
+// Check if value is a variable or static value.
+func (v *Value) plain() bool {
+	return v.err == nil && len(v.call) == 0 && len(v.mods) == 0 && len(v.subset) == 0 && len(v.ins) == 0 &&
+		(v.static || len(v.path) > 0)
+}
+
+func (v *Value) raw() []byte {
+	if v.static {
+		return []byte(v.lit)
+	}
+	return []byte(v.path)
+}
+
+// Check if path may be used as variable, eg: "dst.Items[i].Name".
+func validPath(path string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	p := byteconv.S2B(path)
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case c == '[':
+			j := indexQB(p, i)
+			if j == -1 {
+				return false
+			}
+			i = j
+		case isIdentChar(c) || c == '.' || c == '@' || c == '-' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Check if s may be used as a name of local variable.
+func validName(s string) bool {
+	if len(s) == 0 || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check if destination is a context variable.
+func isCtxDst(dst string) bool {
+	return strings.HasPrefix(dst, "ctx.") || strings.HasPrefix(dst, "context.")
+}
@@ -0,0 +1,112 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	t.Run("equivalence", func(t *testing.T) {
+		src := []byte(`obj.Id = jso.identifier|default("none")
+obj.Cost = crc32(jso.a, "q")
+ctx.x = new(TestObject)
+obj.Name = jso.{name|title}
+obj.Tags[] = jso.tag
+if jso.status == 1 {
+  obj.Status = 1
+} else {
+  obj.Status = 2
+}
+if testns::check(jso.id, 15) {
+  obj.Ustate = 5
+}
+for k, v := range jso.history {
+  obj.Flags[k] = v.val
+  break
+}
+for i := 0; i < 10; i++ {
+  continue
+}
+with obj.Finance.History[+] as h {
+  h.Cost = jso.cost
+}
+decode("item", obj.Permission, jso.perm)
+use "common"
+reset(obj.Name)
+`)
+		expect, err := Parse(src, ModeLax)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := NewBuilder(ModeLax).
+			Assign("obj.Id", Path("jso.identifier").Mod("default", Lit("none"))).
+			Assign("obj.Cost", Fn("crc32", Path("jso.a"), Lit("q"))).
+			Assign("ctx.x", Fn("new", Lit("TestObject")).As("TestObject")).
+			Assign("obj.Name", Path("jso").Subset("name", "title")).
+			Assign("obj.Tags[]", Path("jso.tag")).
+			IfElse(Compare(Path("jso.status"), "==", Lit(1)), func(b *Builder) {
+				b.Assign("obj.Status", Lit(1))
+			}, func(b *Builder) {
+				b.Assign("obj.Status", Lit(2))
+			}).
+			If(Helper("testns::check", Path("jso.id"), Lit(15)), func(b *Builder) {
+				b.Assign("obj.Ustate", Lit(5))
+			}).
+			Range("k", "v", "jso.history", func(b *Builder) {
+				b.Assign("obj.Flags[k]", Path("v.val")).Break()
+			}).
+			For("i", Lit(0), "<", Lit(10), func(b *Builder) {
+				b.Continue()
+			}).
+			With("obj.Finance.History", "h", func(b *Builder) {
+				b.Assign("h.Cost", Path("jso.cost"))
+			}).
+			Decode("item", "obj.Permission", "jso.perm").
+			Use("common").
+			Call("reset", Lit("obj.Name")).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.HumanReadable(), expect.HumanReadable()) {
+			t.Errorf("tree mismatch, need:\n%s\ngot:\n%s", expect.HumanReadable(), tree.HumanReadable())
+		}
+		if !bytes.Equal(tree.Source(), expect.Source()) {
+			t.Errorf("source mismatch, need:\n%s\ngot:\n%s", expect.Source(), tree.Source())
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		stages := []struct {
+			name  string
+			build func(b *Builder)
+			err   error
+		}{
+			{"unknown modifier", func(b *Builder) { b.Assign("obj.Id", Path("jso.id").Mod("unknown")) }, ErrModNotFound},
+			{"unknown getter", func(b *Builder) { b.Assign("obj.Id", Fn("unknown")) }, ErrGetterNotFound},
+			{"unknown callback", func(b *Builder) { b.Call("unknown") }, ErrCallbackNotFound},
+			{"unknown helper", func(b *Builder) { b.If(Helper("unknown"), nil) }, ErrCondHlpNotFound},
+			{"signature", func(b *Builder) { b.Assign("obj.Id", Path("jso.id").Mod("default")) }, ErrSignature},
+			{"break outside loop", func(b *Builder) { b.If(Compare(Path("x"), "==", Lit(1)), func(b *Builder) { b.Break() }) }, ErrLoopCtlNoLoop},
+			{"malformed path", func(b *Builder) { b.Assign("obj.Id = 1", Lit(1)) }, ErrMalformedPath},
+			{"malformed static", func(b *Builder) { b.Assign("obj.Id", Lit(struct{}{})) }, ErrMalformedValue},
+			{"unknown operator", func(b *Builder) { b.If(Compare(Path("x"), "~", Lit(1)), nil) }, ErrMalformedValue},
+			{"loop condition", func(b *Builder) { b.For("i", Lit(0), "++", Lit(10), nil) }, ErrWrongLoopCond},
+		}
+		for _, st := range stages {
+			t.Run(st.name, func(t *testing.T) {
+				b := NewBuilder()
+				st.build(b)
+				if _, err := b.Build(); !errors.Is(err, st.err) {
+					t.Errorf("expected error %s, got %v", st.err, err)
+				}
+			})
+		}
+	})
+	t.Run("lazy", func(t *testing.T) {
+		b := NewBuilder(ModeLazy).Assign("obj.Id", Path("jso.id").Mod("unknownBuilderMod"))
+		if _, err := b.Build(); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -20,6 +20,8 @@ var (
 
 // RegisterCallbackFn registers new callback to the registry.
 func RegisterCallbackFn(name, alias string, cb CallbackFn) *CallbackFnTuple {
+	regMux.Lock()
+	defer regMux.Unlock()
 	if idx, ok := callbackRegistry[name]; ok && idx >= 0 && idx < len(callbackBuf) {
 		return &callbackBuf[idx]
 	}
@@ -35,6 +37,7 @@ func RegisterCallbackFn(name, alias string, cb CallbackFn) *CallbackFnTuple {
 	if len(alias) > 0 {
 		callbackRegistry[alias] = idx
 	}
+	nextRegGen()
 	return &callbackBuf[idx]
 }
 
@@ -49,6 +52,8 @@ func RegisterCallbackFnNS(namespace, name, alias string, cb CallbackFn) *Callbac
 
 // GetCallbackFn returns callback function from the registry.
 func GetCallbackFn(name string) CallbackFn {
+	regMux.RLock()
+	defer regMux.RUnlock()
 	if idx, ok := callbackRegistry[name]; ok && idx >= 0 && idx < len(callbackBuf) {
 		return callbackBuf[idx].fn
 	}
@@ -2,8 +2,11 @@ package decoder
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 
 	"github.com/koykov/byteconv"
+	"github.com/koykov/x2bytes"
 )
 
 func cbPrint(_ *Ctx, args []any) error {
@@ -34,7 +37,7 @@ func cbReset(ctx *Ctx, args []any) error {
 		return nil // cannot check path
 	}
 
-	ctx.bufS = tokenize(ctx.bufS[:0], path)
+	ctx.bufS = ctx.splitPath(ctx.bufS[:0], byteconv.S2B(path), false)
 	if len(ctx.bufS) == 0 {
 		return nil
 	}
@@ -44,3 +47,108 @@ func cbReset(ctx *Ctx, args []any) error {
 	err = ins.Reset(src, ctx.bufS[1:]...)
 	return err
 }
+
+func cbDelete(ctx *Ctx, args []any) error {
+	if len(args) < 2 {
+		return ErrCbPoorArgs
+	}
+	var path []byte
+	switch x := args[0].(type) {
+	case string:
+		path = byteconv.S2B(x)
+	case *string:
+		path = byteconv.S2B(*x)
+	case []byte:
+		path = x
+	case *[]byte:
+		path = *x
+	default:
+		return nil // cannot check path
+	}
+
+	ctx.bufS = ctx.splitPath(ctx.bufS[:0], path, false)
+	if len(ctx.bufS) == 0 {
+		return nil
+	}
+	m, _ := ctx.get2(ctx.bufS, nil)
+	if ctx.Err != nil {
+		return ctx.Err
+	}
+
+	// Convert key to bytes.
+	off := len(ctx.bufKB)
+	var err error
+	if ctx.bufKB, err = x2bytes.ToBytes(ctx.bufKB, args[1]); err != nil {
+		return err
+	}
+	return ctx.mapDelete(m, ctx.bufKB[off:])
+}
+
+// Remove key from map (given as map or pointer to map).
+//
+// Inspectors can't remove map keys, so reflection is used. Keys are converting to the type of map keys using values
+// cached in the context (see mapKey()), so deletion doesn't allocate.
+func (ctx *Ctx) mapDelete(m any, key []byte) error {
+	v := reflect.ValueOf(m)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Map {
+		return ErrDeleteNoMap
+	}
+	if v.IsNil() {
+		return nil
+	}
+	k := ctx.mapKey(v.Type().Key())
+	switch k.Kind() {
+	case reflect.String:
+		k.SetString(byteconv.B2S(key))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(byteconv.B2S(key), 0, 64)
+		if err != nil {
+			return err
+		}
+		k.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(byteconv.B2S(key), 0, 64)
+		if err != nil {
+			return err
+		}
+		k.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(byteconv.B2S(key), 64)
+		if err != nil {
+			return err
+		}
+		k.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(byteconv.B2S(key))
+		if err != nil {
+			return err
+		}
+		k.SetBool(b)
+	default:
+		return ErrDeleteKeyType
+	}
+	v.SetMapIndex(k, reflect.Value{})
+	if k.Kind() == reflect.String {
+		// Don't keep reference to the key buffer.
+		k.SetString("")
+	}
+	return nil
+}
+
+// Get reusable value of map key type.
+func (ctx *Ctx) mapKey(typ reflect.Type) reflect.Value {
+	for i := 0; i < len(ctx.bufMK); i++ {
+		if ctx.bufMK[i].Type() == typ {
+			return ctx.bufMK[i]
+		}
+	}
+	k := reflect.New(typ).Elem()
+	ctx.bufMK = append(ctx.bufMK, k)
+	return k
+}
@@ -0,0 +1,389 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/koykov/byteconv"
+	"github.com/koykov/inspector"
+	"github.com/koykov/vector_inspector"
+)
+
+// Bindings describes inspectors of variables the decoder expects, eg: {"data": TestObjectInspector{}, "resp": Vector}.
+type Bindings map[string]inspector.Inspector
+
+// Vector is a binding of variable that contains parsed vector (JSON, XML, ...). Paths of such variables aren't checked.
+var Vector inspector.Inspector = vector_inspector.VectorInspector{}
+
+// Check statically verifies the tree against bindings of variables.
+//
+// Check reports paths that don't exist in the bound types, destinations that can't be written through the inspector,
+// assignments of values that can't be converted to the destination type and variables that are never bound. Variables
+// declared by the decoder itself (context variables, loop variables, function params, ...) and globals are considered
+// bound. Returns all found problems at once, nil means that tree is clean. Problems are reported at position of the
+// rule, eg: "path not found: 'obj.Nmae' at line 1:1".
+//
+// Inspectors can't describe types, so types of paths are resolved by reflection over instance of the bound inspector.
+func Check(tree *Tree, bindings Bindings) []error {
+	if tree == nil {
+		return nil
+	}
+	c := checker{bind: bindings, local: make(map[string]bool), types: make(map[string]reflect.Type)}
+	for i := 0; i < len(tree.udfs); i++ {
+		f := tree.udfs[i]
+		for j := 0; j < len(f.params); j++ {
+			c.local[string(f.params[j])] = true
+		}
+		c.collect(f.body)
+	}
+	c.collect(tree.nodes)
+	for i := 0; i < len(tree.udfs); i++ {
+		c.checkNodes(tree.udfs[i].body)
+	}
+	c.checkNodes(tree.nodes)
+	return c.errs
+}
+
+// Static checker state.
+type checker struct {
+	bind Bindings
+	// Variables declared by the decoder.
+	local map[string]bool
+	errs  []error
+	seen  map[string]bool
+	// Types of bound variables.
+	types map[string]reflect.Type
+	// Position of the current rule.
+	line, col int
+}
+
+// Collect variables declared by nodes.
+func (c *checker) collect(nodes []node) {
+	for i := 0; i < len(nodes); i++ {
+		r := &nodes[i]
+		if r.typ == typeOperator && len(r.dsta) > 1 && isCtxRoot(r.dsta[0]) {
+			c.local[r.dsta[1]] = true
+		}
+		for _, v := range [][]byte{r.loopKey, r.loopVal, r.loopCnt, r.withVar, r.condOKL, r.condOKR} {
+			if len(v) > 0 {
+				c.local[string(v)] = true
+			}
+		}
+		c.collect(r.child)
+	}
+}
+
+// Check nodes recursively.
+func (c *checker) checkNodes(nodes []node) {
+	for i := 0; i < len(nodes); i++ {
+		r := &nodes[i]
+		if r.line > 0 {
+			// Nested nodes without own position (eg: branches of condition) inherit position of the parent.
+			c.line, c.col = r.line, r.col
+		}
+		switch r.typ {
+		case typeOperator:
+			c.checkOperator(r)
+		case typeReturn:
+			if len(r.src) > 0 && !r.static {
+				c.src(r.src)
+			}
+			c.checkMods(r.mod)
+		case typeAutomap, typeDecode:
+			c.dst(r.dst)
+			c.src(r.src)
+		case typeWith:
+			c.dst(r.dst)
+		case typeCond, typeCondOK:
+			if !r.condStaticL && len(r.condL) > 0 && r.typ == typeCond {
+				c.src(r.condL)
+			}
+			if !r.condStaticR && len(r.condR) > 0 && r.typ == typeCond {
+				c.src(r.condR)
+			}
+			c.checkArgs(r.condHlpArg, getCondSig(r.condHlp, r.typ == typeCondOK))
+		case typeSwitch:
+			if len(r.switchArg) > 0 {
+				c.src(r.switchArg)
+			}
+		case typeCase:
+			if !r.caseStaticL && len(r.caseL) > 0 {
+				c.src(r.caseL)
+			}
+			if !r.caseStaticR && len(r.caseR) > 0 {
+				c.src(r.caseR)
+			}
+			c.checkArgs(r.caseHlpArg, getCondSig(r.caseHlp, false))
+		case typeLoopRange:
+			c.src(r.loopSrc)
+		case typeLoopCount:
+			if !r.loopCntStatic {
+				c.src(r.loopCntInit)
+			}
+			if !r.loopLimStatic {
+				c.src(r.loopLim)
+			}
+		}
+		c.checkNodes(r.child)
+	}
+}
+
+// Check assignment, getter or callback node.
+func (c *checker) checkOperator(r *node) {
+	if r.callback != nil {
+		c.checkArgs(r.arg, getCallbackSig(r.src))
+		return
+	}
+	if r.getter != nil {
+		c.checkArgs(r.arg, getGetterSig(r.src))
+		c.dst(r.dst)
+		return
+	}
+	c.checkMods(r.mod)
+	if len(r.dsta) > 0 && isCtxRoot(r.dsta[0]) {
+		// Var-to-ctx assignment declares variable of any type.
+		if !r.static {
+			c.src(r.src)
+		}
+		return
+	}
+	dt := c.dst(r.dst)
+	if dt != nil && r.push {
+		if dt = indirectType(dt); dt.Kind() != reflect.Slice {
+			c.fail(fmt.Errorf("%w: '%s' isn't a slice", ErrTypeMismatch, r.dst))
+			return
+		}
+		dt = dt.Elem()
+	}
+	if r.static {
+		if dt != nil && !staticConvertible(r.src, dt) {
+			c.fail(fmt.Errorf("%w: can't assign '%s' to '%s' of type %s", ErrTypeMismatch, r.src, r.dst, dt))
+		}
+		return
+	}
+	st := c.src(r.src)
+	if dt != nil && st != nil && len(r.mod) == 0 && !convertible(st, dt) {
+		c.fail(fmt.Errorf("%w: can't assign '%s' of type %s to '%s' of type %s", ErrTypeMismatch, r.src, st, r.dst, dt))
+	}
+}
+
+// Check arguments of modifiers.
+func (c *checker) checkMods(mods []mod) {
+	for i := 0; i < len(mods); i++ {
+		c.checkArgs(mods[i].arg, getModSig(mods[i].id))
+	}
+}
+
+// Check arguments of function with signature sig. Type literals (see ArgType) aren't paths, so skip them.
+func (c *checker) checkArgs(args []*arg, sig *Signature) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a.static || a.global || len(a.val) == 0 {
+			continue
+		}
+		if sig != nil && len(sig.Args) > 0 {
+			k := sig.Args[len(sig.Args)-1]
+			if i < len(sig.Args) {
+				k = sig.Args[i]
+			}
+			if k == ArgType {
+				continue
+			}
+		}
+		c.src(a.val)
+	}
+}
+
+// Check source path and get its type.
+func (c *checker) src(path []byte) reflect.Type {
+	return c.resolve(path, false)
+}
+
+// Check destination path and get its type.
+func (c *checker) dst(path []byte) reflect.Type {
+	return c.resolve(path, true)
+}
+
+// Resolve type of the path using bound inspectors.
+//
+// Returns nil type if it can't be determined statically (vectors, locals, interfaces, ...).
+func (c *checker) resolve(path []byte, lv bool) reflect.Type {
+	if argKindOf(path) != ArgPath {
+		// Static value that isn't marked as static, eg: negative number in case.
+		return nil
+	}
+	tokens := checkTokens(path)
+	if len(tokens) == 0 {
+		return nil
+	}
+	root := tokens[0]
+	if isCtxRoot(root) || c.local[root] || GetGlobal(root) != nil {
+		return nil
+	}
+	ins, ok := c.bind[root]
+	if !ok {
+		c.fail(fmt.Errorf("%w: '%s'", ErrVarUnbound, root))
+		return nil
+	}
+	if ins == nil {
+		return nil
+	}
+	t, ok := c.types[root]
+	if !ok {
+		if x := ins.Instance(false); x != nil {
+			t = reflect.TypeOf(x)
+		}
+		c.types[root] = t
+	}
+	if t == nil {
+		return nil
+	}
+	for i := 1; i < len(tokens); i++ {
+		t = indirectType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			if len(tokens[i]) == 0 {
+				return nil
+			}
+			f, ok := t.FieldByName(tokens[i])
+			if !ok || len(f.PkgPath) > 0 {
+				c.fail(fmt.Errorf("%w: '%s'", ErrPathNotFound, path))
+				return nil
+			}
+			t = f.Type
+		case reflect.Map:
+			if lv && i < len(tokens)-1 && t.Elem().Kind() == reflect.Struct {
+				// Values of map aren't addressable, so their fields can't be set.
+				c.fail(fmt.Errorf("%w: '%s'", ErrPathReadOnly, path))
+				return nil
+			}
+			t = t.Elem()
+		case reflect.Slice, reflect.Array:
+			if len(tokens[i]) > 0 && !isDigits(byteconv.S2B(tokens[i])) {
+				c.fail(fmt.Errorf("%w: '%s'", ErrPathNotFound, path))
+				return nil
+			}
+			t = t.Elem()
+		case reflect.Interface:
+			return nil
+		default:
+			c.fail(fmt.Errorf("%w: '%s'", ErrPathNotFound, path))
+			return nil
+		}
+	}
+	return t
+}
+
+// Register problem at position of the current rule. Duplicates (eg: the same unbound variable) are skipped.
+func (c *checker) fail(err error) {
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	msg := err.Error()
+	if c.seen[msg] {
+		return
+	}
+	c.seen[msg] = true
+	if c.line > 0 {
+		err = fmt.Errorf("%w at line %d:%d", err, c.line, c.col)
+	}
+	c.errs = append(c.errs, err)
+}
+
+// Split path to tokens. Dynamic indexes and keys (eg: items[i]) replaces with empty tokens.
+func checkTokens(path []byte) []string {
+	if bytes.IndexByte(path, '[') == -1 {
+		return tokenize(nil, byteconv.B2S(path))
+	}
+	var (
+		r []string
+		o int
+	)
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '.', '@':
+			if i > o {
+				r = append(r, string(path[o:i]))
+			}
+			o = i + 1
+		case '[':
+			if i > o {
+				r = append(r, string(path[o:i]))
+			}
+			j := indexQB(path, i)
+			if j == -1 {
+				return append(r, tokenize(nil, byteconv.B2S(path[i+1:]))...)
+			}
+			key := bytes.TrimSpace(path[i+1 : j])
+			if n := len(key); n > 1 && (key[0] == '"' || key[0] == '\'' || key[0] == '`') && key[n-1] == key[0] {
+				key = key[1 : n-1]
+			} else if !isDigits(key) {
+				key = nil
+			}
+			r = append(r, string(key))
+			i, o = j, j+1
+		}
+	}
+	if o < len(path) {
+		r = append(r, string(path[o:]))
+	}
+	return r
+}
+
+func isCtxRoot(s string) bool {
+	return s == "ctx" || s == "context"
+}
+
+// Check if type is a scalar (number, bool, string or bytes).
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	default:
+		return false
+	}
+}
+
+// Check if value of type st may be assigned to destination of type dt.
+//
+// Scalars converts to each other at decode time, so only mixing of scalars and composite types and incompatible
+// composite types are reported.
+func convertible(st, dt reflect.Type) bool {
+	st, dt = indirectType(st), indirectType(dt)
+	if st.Kind() == reflect.Interface || dt.Kind() == reflect.Interface {
+		return true
+	}
+	if ss, ds := isScalar(st), isScalar(dt); ss || ds {
+		return ss == ds
+	}
+	return st.ConvertibleTo(dt)
+}
+
+// Check if static value may be assigned to destination of type dt.
+func staticConvertible(val []byte, dt reflect.Type) bool {
+	if byteconv.B2S(val) == "nil" {
+		return true
+	}
+	dt = indirectType(dt)
+	switch dt.Kind() {
+	case reflect.Interface, reflect.String:
+		return true
+	case reflect.Bool:
+		_, err := strconv.ParseBool(byteconv.B2S(val))
+		return err == nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		_, err := strconv.ParseFloat(byteconv.B2S(val), 64)
+		return err == nil
+	default:
+		return isScalar(dt)
+	}
+}
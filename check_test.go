@@ -0,0 +1,51 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/koykov/inspector/testobj_ins"
+)
+
+func TestCheck(t *testing.T) {
+	bindings := Bindings{"obj": testobj_ins.TestObjectInspector{}, "jso": Vector}
+	parse := func(t *testing.T, name string) *Tree {
+		body, _ := os.ReadFile("testdata/check/" + name + ".dec")
+		tree, err := Parse(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tree
+	}
+	t.Run("clean", func(t *testing.T) {
+		if errs := Check(parse(t, "clean"), bindings); len(errs) > 0 {
+			t.Errorf("unexpected problems: %v", errs)
+		}
+	})
+	t.Run("problems", func(t *testing.T) {
+		expect := []error{
+			ErrPathNotFound,
+			ErrTypeMismatch,
+			ErrTypeMismatch,
+			ErrVarUnbound,
+			ErrPathNotFound,
+			ErrVarUnbound,
+		}
+		errs := Check(parse(t, "problems"), bindings)
+		if len(errs) != len(expect) {
+			t.Fatalf("problems count mismatch: need %d, got %d: %v", len(expect), len(errs), errs)
+		}
+		for i := 0; i < len(expect); i++ {
+			if !errors.Is(errs[i], expect[i]) {
+				t.Errorf("problem #%d mismatch: need %s, got %s", i, expect[i], errs[i])
+			}
+			// Every problem is reported at the line of its rule.
+			if pos := fmt.Sprintf(" at line %d:1", i+1); !strings.HasSuffix(errs[i].Error(), pos) {
+				t.Errorf("problem #%d position mismatch: need %s, got %s", i, pos, errs[i])
+			}
+		}
+	})
+}
@@ -47,9 +47,6 @@ func (ctx *Ctx) cloop(r *node, _ []node) {
 			ctx.Err = ErrWrongLoopCond
 			break
 		}
-		// Check breakN signal from child loop.
-		allowIter = allowIter && ctx.brkD == 0
-
 		if !allowIter {
 			break
 		}
@@ -57,8 +54,7 @@ func (ctx *Ctx) cloop(r *node, _ []node) {
 		// Set/update counter var.
 		ctx.SetStatic(byteconv.B2S(r.loopCnt), &ctx.bufLC[idxLC])
 
-		// Loop over child nodes with square brackets check in paths.
-		ctx.chQB = true
+		// Loop over child nodes.
 		var err, lerr error
 		for i := 0; i < len(r.child); i++ {
 			ch := &r.child[i]
@@ -69,8 +65,22 @@ func (ctx *Ctx) cloop(r *node, _ []node) {
 			if err == ErrBreakLoop || err == ErrContLoop {
 				break
 			}
+			if err == ErrReturn {
+				ctx.ret = true
+				break
+			}
+		}
+		if ctx.ret {
+			break
+		}
+		// Check if break/continue signal addressed to the outer loop.
+		if err == ErrBreakLoop || err == ErrContLoop {
+			if ctx.loopSignalOuter(err) {
+				break
+			}
+		} else if lerr == ErrLBreakLoop && ctx.loopSignalOuter(lerr) {
+			break
 		}
-		ctx.chQB = false
 
 		// Modify counter var.
 		switch r.loopCntOp {
@@ -87,9 +97,6 @@ func (ctx *Ctx) cloop(r *node, _ []node) {
 
 		// Handle break/continue cases.
 		if err == ErrBreakLoop || lerr == ErrLBreakLoop {
-			if ctx.brkD > 0 {
-				ctx.brkD--
-			}
 			break
 		}
 		if err == ErrContLoop {
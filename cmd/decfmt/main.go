@@ -0,0 +1,95 @@
+// Command decfmt formats decoder sources.
+//
+// Usage:
+//
+//	decfmt [-l] [-w] [path ...]
+//
+// Paths may be files or directories, directories are walked recursively for *.dec files. Without paths decfmt formats
+// standard input. By default formatted source is printed to standard output.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/koykov/decoder"
+)
+
+var (
+	list  = flag.Bool("l", false, "list files whose formatting differs from canonical")
+	write = flag.Bool("w", false, "write result to source file instead of stdout")
+)
+
+func main() {
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: decfmt [-l] [-w] [path ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			err = process("<stdin>", src, false)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	var failed bool
+	for _, root := range flag.Args() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (path != root && filepath.Ext(path) != ".dec") {
+				return nil
+			}
+			src, err := os.ReadFile(path)
+			if err == nil {
+				err = process(path, src, true)
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+				failed = true
+			}
+			return nil
+		})
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(2)
+	}
+}
+
+func process(path string, src []byte, file bool) error {
+	r, err := decoder.Format(src)
+	if err != nil {
+		return err
+	}
+	changed := !bytes.Equal(src, r)
+	if *list && changed {
+		fmt.Println(path)
+	}
+	if *write && file {
+		if !changed {
+			return nil
+		}
+		return os.WriteFile(path, r, 0644)
+	}
+	if !*list {
+		_, err = os.Stdout.Write(r)
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+// Command declint reports common mistakes in decoder sources.
+//
+// Usage:
+//
+//	declint [-disable DL001,DL002] path ...
+//
+// Paths may be files or directories, directories are walked recursively for *.dec files. Findings are printed in
+// format "file:line:col: code message". Exit code is 1 if any finding reported and 2 on errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/koykov/decoder/lint"
+)
+
+func main() {
+	disable := flag.String("disable", "", "comma separated list of codes to skip")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintf(flag.CommandLine.Output(), "Usage: declint [-disable codes] path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	skip := make(map[lint.Code]bool)
+	for _, code := range strings.Split(*disable, ",") {
+		if code = strings.TrimSpace(code); len(code) > 0 {
+			skip[lint.Code(code)] = true
+		}
+	}
+
+	var found, failed bool
+	check := func(path string) {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			failed = true
+			return
+		}
+		for _, f := range lint.Lint(src) {
+			if skip[f.Code] {
+				continue
+			}
+			fmt.Printf("%s:%s\n", path, f)
+			found = true
+		}
+	}
+	for _, root := range flag.Args() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && (path == root || filepath.Ext(path) == ".dec") {
+				check(path)
+			}
+			return nil
+		})
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	switch {
+	case failed:
+		os.Exit(2)
+	case found:
+		os.Exit(1)
+	}
+}
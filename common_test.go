@@ -134,6 +134,13 @@ func assertI32(t testing.TB, field string, val, expect int32) {
 	}
 }
 
+func assertI64(t testing.TB, field string, val, expect int64) {
+	if val != expect {
+		key := getTBName(t)
+		t.Errorf("%s %s test failed", key, field)
+	}
+}
+
 func assertU64(t testing.TB, field string, val, expect uint64) {
 	if val != expect {
 		key := getTBName(t)
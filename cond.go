@@ -16,6 +16,8 @@ var (
 
 // RegisterCondFn registers new condition helper.
 func RegisterCondFn(name string, cond CondFn) *CondFnTuple {
+	regMux.Lock()
+	defer regMux.Unlock()
 	if idx, ok := condRegistry[name]; ok && idx >= 0 && idx < len(condBuf) {
 		return &condBuf[idx]
 	}
@@ -25,6 +27,7 @@ func RegisterCondFn(name string, cond CondFn) *CondFnTuple {
 	})
 	idx := len(condBuf) - 1
 	condRegistry[name] = idx
+	nextRegGen()
 	return &condBuf[idx]
 }
 
@@ -38,6 +41,8 @@ func RegisterCondFnNS(namespace, name string, cond CondFn) *CondFnTuple {
 
 // GetCondFn returns condition helper from the registry.
 func GetCondFn(name string) CondFn {
+	regMux.RLock()
+	defer regMux.RUnlock()
 	if idx, ok := condRegistry[name]; ok && idx >= 0 && idx < len(condBuf) {
 		return condBuf[idx].fn
 	}
@@ -16,6 +16,8 @@ var (
 
 // RegisterCondOKFn registers new condition-OK helper.
 func RegisterCondOKFn(name string, cond CondOKFn) *CondOKTuple {
+	regMux.Lock()
+	defer regMux.Unlock()
 	if idx, ok := condOKRegistry[name]; ok && idx >= 0 && idx < len(condOkBuf) {
 		return &condOkBuf[idx]
 	}
@@ -25,6 +27,7 @@ func RegisterCondOKFn(name string, cond CondOKFn) *CondOKTuple {
 	})
 	idx := len(condOkBuf) - 1
 	condOKRegistry[name] = idx
+	nextRegGen()
 	return &condOkBuf[idx]
 }
 
@@ -38,6 +41,8 @@ func RegisterCondOKFnNS(namespace, name string, cond CondOKFn) *CondOKTuple {
 
 // GetCondOKFn returns condition-OK helper from the registry.
 func GetCondOKFn(name string) CondOKFn {
+	regMux.RLock()
+	defer regMux.RUnlock()
 	if idx, ok := condOKRegistry[name]; ok && idx >= 0 && idx < len(condOkBuf) {
 		return condOkBuf[idx].fn
 	}
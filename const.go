@@ -0,0 +1,378 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/koykov/byteconv"
+)
+
+var (
+	reConstEntry = regexp.MustCompile(`^(\w+)\s*=\s*(.+)$`)
+	reImportAll  = regexp.MustCompile(`(?m)^[ \t]*import\s+["']([^"']+)["'][ \t]*$`)
+	reExtendsAll = regexp.MustCompile(`(?m)^[ \t]*extends\s+["']([^"']+)["'][ \t]*$`)
+
+	// Statements binding local names, that constants must not replace.
+	reConstBindFor = regexp.MustCompile(`^(?:\w+\s*:\s*)?for\s+(\w+)(?:\s*,\s*(\w+))?\s*:?=[^=]`)
+	reConstBindIf  = regexp.MustCompile(`^if\s+(\w+)(?:\s*,\s*(\w+))?\s*:?=[^=]`)
+
+	kwConst   = []byte("const")
+	kwAs      = []byte("as")
+	kwFor     = []byte("for")
+	kwCont    = []byte("continue")
+	kwAutomap = []byte("automap(")
+)
+
+// Collect constants declarations and substitute them in the body.
+//
+// Constants declares at decoder (or imported library) scope, eg: `const (StatusActive = 1; DefaultCurrency = "USD")`
+// and replaces with their values before parsing, thus they may be used in any place where static value is allowed.
+// Constants of parent decoder (see extends statement) are available as well, if parent is already registered.
+func (p *parser) substConsts(body []byte) ([]byte, error) {
+	if m := reExtendsAll.FindSubmatch(body); m != nil {
+		if parent := decDB.getKey(byteconv.B2S(m[1])); parent != nil && parent.tree != nil {
+			for k, v := range parent.tree.consts {
+				p.setConst(k, v)
+			}
+		}
+	}
+	for _, m := range reImportAll.FindAllSubmatch(body, -1) {
+		if lib := decDB.getKey(byteconv.B2S(m[1])); lib != nil {
+			for k, v := range lib.orig.consts {
+				p.setConst(k, v)
+			}
+		}
+	}
+	body, own, err := p.cutConsts(body)
+	if err != nil {
+		return body, err
+	}
+	p.own = own
+	if len(p.consts) == 0 {
+		return body, nil
+	}
+	return p.replaceConsts(body), nil
+}
+
+// Cut off constants declarations from the body and register them.
+//
+// Declarations are tokenized with respect of quotes and comments, thus values like "a)b" or "a;b" are allowed.
+// Line breaks of declarations keep to not shift positions of the following rules.
+func (p *parser) cutConsts(body []byte) ([]byte, map[string][]byte, error) {
+	var (
+		own map[string][]byte
+		err error
+	)
+	buf := make([]byte, 0, len(body))
+	for off := 0; off < len(body); {
+		line := body[off:]
+		if i := bytes.IndexByte(line, '\n'); i != -1 {
+			line = line[:i+1]
+		}
+		stmt := bytes.TrimSpace(line)
+		if !bytes.HasPrefix(stmt, kwConst) || len(stmt) == len(kwConst) ||
+			(stmt[len(kwConst)] != ' ' && stmt[len(kwConst)] != '\t' && stmt[len(kwConst)] != '(') {
+			buf = append(buf, line...)
+			off += len(line)
+			continue
+		}
+		decl := bytes.TrimLeft(stmt[len(kwConst):], " \t")
+		end := off + len(line)
+		if decl[0] == '(' {
+			// Block declaration, may take several lines.
+			lo := off + bytes.IndexByte(line, '(') + 1
+			hi := constBlockEnd(body, lo)
+			if hi == -1 {
+				return body, own, fmt.Errorf("%w: unclosed block '%s'", ErrConstMalformed, stmt)
+			}
+			tail := body[hi+1:]
+			if i := bytes.IndexByte(tail, '\n'); i != -1 {
+				tail = tail[:i]
+			}
+			if tail = bytes.TrimSpace(tail); len(tail) > 0 && !bytes.HasPrefix(tail, comment) {
+				return body, own, fmt.Errorf("%w: '%s'", ErrConstMalformed, tail)
+			}
+			decl = body[lo:hi]
+			if end = bytes.IndexByte(body[hi:], '\n'); end == -1 {
+				end = len(body)
+			} else {
+				end += hi + 1
+			}
+		}
+		for _, d := range splitConsts(decl) {
+			if own, err = p.addConst(own, d); err != nil {
+				return body, own, err
+			}
+		}
+		buf = append(buf, bytes.Repeat(nl, bytes.Count(body[off:end], nl))...)
+		off = end
+	}
+	return buf, own, nil
+}
+
+// Find closing parenthesis of constants block starting from offset i.
+func constBlockEnd(body []byte, i int) int {
+	var q byte
+	for ; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case q != 0:
+			if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			q = c
+		case c == '/' && i+1 < len(body) && body[i+1] == '/':
+			// Skip comment till the end of line.
+			for i < len(body) && body[i] != '\n' {
+				i++
+			}
+		case c == ')':
+			return i
+		}
+	}
+	return -1
+}
+
+// Split constants declarations separated by line breaks and semicolons, comments drop.
+func splitConsts(p []byte) (r [][]byte) {
+	var (
+		q   byte
+		off int
+	)
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case q != 0:
+			if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			q = c
+		case c == '/' && i+1 < len(p) && p[i+1] == '/':
+			r = append(r, p[off:i])
+			for i < len(p) && p[i] != '\n' {
+				i++
+			}
+			off = i
+		case c == '\n' || c == '\r' || c == ';':
+			r = append(r, p[off:i])
+			off = i + 1
+		}
+	}
+	if off < len(p) {
+		r = append(r, p[off:])
+	}
+	return
+}
+
+// Parse constant declaration and register it.
+func (p *parser) addConst(own map[string][]byte, decl []byte) (map[string][]byte, error) {
+	decl = bytes.TrimSpace(decl)
+	if len(decl) == 0 {
+		return own, nil
+	}
+	m := reConstEntry.FindSubmatch(decl)
+	if m == nil {
+		return own, fmt.Errorf("%w: '%s'", ErrConstMalformed, decl)
+	}
+	name, val := string(m[1]), bytes.TrimSpace(m[2])
+	if !isStatic(val) {
+		return own, fmt.Errorf("%w: '%s'", ErrConstNoStatic, decl)
+	}
+	if _, ok := own[name]; ok {
+		return own, fmt.Errorf("%w: '%s'", ErrConstRedeclared, name)
+	}
+	if own == nil {
+		own = make(map[string][]byte)
+	}
+	own[name] = val
+	// Own constants overrides imported ones.
+	p.setConst(name, val)
+	return own, nil
+}
+
+func (p *parser) setConst(name string, val []byte) {
+	if p.consts == nil {
+		p.consts = make(map[string][]byte)
+	}
+	p.consts[name] = val
+}
+
+// Names bound by control statement and depth of the block they are visible in.
+type constScope struct {
+	depth int
+	names [][]byte
+}
+
+// Replace names of constants with their values.
+//
+// Only standalone identifiers in value and argument positions replaces, thus paths like "obj.StatusActive", names of
+// functions and modifiers, destinations of assignments, labels and locally bound names (loop variables, with aliases,
+// parameters of functions) stay untouched. Quoted strings, comments and options of automap also keep as is.
+func (p *parser) replaceConsts(body []byte) []byte {
+	buf := make([]byte, 0, len(body))
+	var (
+		scopes []constScope
+		depth  int
+		q      byte
+	)
+	for len(body) > 0 {
+		line := body
+		if i := bytes.IndexByte(line, '\n'); i != -1 {
+			line = line[:i+1]
+		}
+		body = body[len(line):]
+		stmt := bytes.TrimSpace(line)
+		if q == 0 {
+			if names := constBoundNames(stmt); len(names) > 0 {
+				scopes = append(scopes, constScope{depth: depth, names: names})
+			}
+			if bytes.HasPrefix(stmt, kwAutomap) {
+				buf = append(buf, line...)
+				continue
+			}
+		}
+		buf, depth, q = p.replaceConstsLine(buf, line, depth, q, scopes)
+		for len(scopes) > 0 && scopes[len(scopes)-1].depth >= depth {
+			scopes = scopes[:len(scopes)-1]
+		}
+	}
+	return buf
+}
+
+// Replace constants in the single line and track depth of blocks.
+func (p *parser) replaceConstsLine(buf, line []byte, depth int, q byte, scopes []constScope) ([]byte, int, byte) {
+	// Identifier directly preceding the current one, needs to check keywords.
+	var prev []byte
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case q != 0:
+			// Inside quotes.
+			if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			q = c
+		case c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return append(buf, line[i:]...), depth, q
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case isIdentStart(c) && (i == 0 || !isIdentChar(line[i-1])):
+			j := i + 1
+			for j < len(line) && isIdentChar(line[j]) {
+				j++
+			}
+			word := line[i:j]
+			val, ok := p.consts[byteconv.B2S(word)]
+			if ok && isConstPos(line, i, j, prev) && !isBound(scopes, word) {
+				buf = append(buf, val...)
+			} else {
+				buf = append(buf, word...)
+			}
+			prev = word
+			i = j
+			continue
+		}
+		if c != ' ' && c != '\t' {
+			prev = nil
+		}
+		buf = append(buf, c)
+		i++
+	}
+	return buf, depth, q
+}
+
+// Check if identifier line[i:j] is in value position.
+//
+// prev is the identifier directly preceding the current one (separated by spaces only).
+func isConstPos(line []byte, i, j int, prev []byte) bool {
+	if i > 0 {
+		switch line[i-1] {
+		case '.':
+			return false
+		case '|':
+			// Modifier name, but not the right part of "||".
+			if i < 2 || line[i-2] != '|' {
+				return false
+			}
+		}
+	}
+	if bytes.Equal(prev, kwAs) || bytes.Equal(prev, loopBrk) || bytes.Equal(prev, loopLBrk) ||
+		bytes.Equal(prev, kwCont) {
+		// Inspector name, with alias or label of loop.
+		return false
+	}
+	if j == len(line) {
+		return true
+	}
+	switch line[j] {
+	case '.', '(', '[':
+		return false
+	}
+	rest := bytes.TrimLeft(line[j:], " \t")
+	switch {
+	case len(rest) == 0:
+		return true
+	case rest[0] == '=':
+		// Destination of assignment, but not the left part of comparison.
+		return len(rest) > 1 && rest[1] == '='
+	case rest[0] == ':':
+		// Short assignment or label of loop.
+		if len(rest) > 1 && rest[1] == '=' {
+			return false
+		}
+		return !bytes.HasPrefix(bytes.TrimLeft(rest[1:], " \t"), kwFor)
+	}
+	return true
+}
+
+// Get names bound by statement: variables of loops and conditions, alias of with, parameters of function.
+func constBoundNames(stmt []byte) (r [][]byte) {
+	if m := reConstBindFor.FindSubmatch(stmt); m != nil {
+		return appendBound(r, m[1], m[2])
+	}
+	if m := reConstBindIf.FindSubmatch(stmt); m != nil {
+		return appendBound(r, m[1], m[2])
+	}
+	if m := reWith.FindSubmatch(stmt); m != nil {
+		return appendBound(r, m[2])
+	}
+	if m := reFunc.FindSubmatch(stmt); m != nil {
+		return extractParams(m[3])
+	}
+	return
+}
+
+func appendBound(r [][]byte, names ...[]byte) [][]byte {
+	for i := 0; i < len(names); i++ {
+		if len(names[i]) > 0 && !bytes.Equal(names[i], uscore) {
+			r = append(r, names[i])
+		}
+	}
+	return r
+}
+
+// Check if name is bound in one of visible scopes.
+func isBound(scopes []constScope, name []byte) bool {
+	for i := 0; i < len(scopes); i++ {
+		for j := 0; j < len(scopes[i].names); j++ {
+			if bytes.Equal(scopes[i].names[j], name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -1,7 +1,7 @@
 package decoder
 
 import (
-	"bytes"
+	"reflect"
 	"time"
 
 	"github.com/koykov/bytebuf"
@@ -19,9 +19,6 @@ type Ctx struct {
 	vars []ctxVar
 	ln   int
 
-	// Check square brackets flag.
-	chQB bool
-
 	// Internal buffers.
 	accB  []byte
 	buf   []byte
@@ -37,11 +34,36 @@ type Ctx struct {
 	bufX  any
 	bufA  []any
 	bufLC []int64
+	// Path resolving buffers (see splitPath()).
+	bufKB []byte
+	bufPS [][]string
+	bufDP []string
+	bufSP []string
+	// Keys of maps of different types (see mapDelete()).
+	bufMK []reflect.Value
+	// Automap paths buffers.
+	bufAD []string
+	bufAS []string
+	pd    int
+	// Inspectors of with variables and depth of with blocks.
+	withs []*withIns
+	wd    int
+	// Stack of keys of running decoders (see decode/use statements).
+	stk []string
+	// Variables overridden by local variables (see openScope()).
+	bufSV []ctxVarSave
+	// Return value of user-defined function.
+	fret any
+	// Enabled feature flags (see SetFlag()).
+	flags []string
 	// Range loop helper.
 	rl *RangeLoop
 
-	// Break depth.
+	// Break depth (count of loops to exit) and loop control signal waiting to pass to the outer loop.
 	brkD int
+	brkS error
+	// Return signal.
+	ret bool
 
 	// List of variables taken from ipools and registered to return back.
 	ipv  []ipoolVar
@@ -64,11 +86,6 @@ type ctxVar struct {
 	ins inspector.Inspector
 }
 
-var (
-	qbL = []byte("[")
-	qbR = []byte("]")
-)
-
 // NewCtx makes new context object.
 func NewCtx() *Ctx {
 	ctx := Ctx{
@@ -118,6 +135,31 @@ func (ctx *Ctx) SetStatic(key string, val any) {
 	ctx.Set(key, val, ins)
 }
 
+// SetFlag enables or disables feature flag checking by "#if flag(...)" directives at decode time.
+func (ctx *Ctx) SetFlag(name string, on bool) {
+	for i := 0; i < len(ctx.flags); i++ {
+		if ctx.flags[i] == name {
+			if !on {
+				ctx.flags = append(ctx.flags[:i], ctx.flags[i+1:]...)
+			}
+			return
+		}
+	}
+	if on {
+		ctx.flags = append(ctx.flags, name)
+	}
+}
+
+// Flag checks if feature flag is enabled.
+func (ctx *Ctx) Flag(name string) bool {
+	for i := 0; i < len(ctx.flags); i++ {
+		if ctx.flags[i] == name {
+			return true
+		}
+	}
+	return false
+}
+
 // SetVector directly register vector in context.
 func (ctx *Ctx) SetVector(key string, vec vector.Interface) {
 	if vec == nil {
@@ -201,7 +243,7 @@ func (ctx *Ctx) get(path []byte, subset [][]byte) any {
 		return nil
 	}
 	// Split path to separate words using dot as separator.
-	ctx.bufS = tokenize(ctx.bufS[:0], byteconv.B2S(path))
+	ctx.bufS = ctx.splitPath(ctx.bufS[:0], path, false)
 	if len(ctx.bufS) == 0 {
 		return nil
 	}
@@ -279,7 +321,7 @@ func (ctx *Ctx) set(path []byte, val any, insName []byte) error {
 	if len(path) == 0 {
 		return nil
 	}
-	ctx.bufS = tokenize(ctx.bufS[:0], byteconv.B2S(path))
+	ctx.bufS = ctx.splitPath(ctx.bufS[:0], path, true)
 	return ctx.set2(ctx.bufS, val, insName)
 }
 
@@ -326,7 +368,7 @@ func (ctx *Ctx) set2(path []string, val any, insName []byte) error {
 }
 
 func (ctx *Ctx) rloop(path []byte, r *node, nodes []node) {
-	ctx.bufS = tokenize(ctx.bufS[:0], byteconv.B2S(path))
+	ctx.bufS = ctx.splitPath(ctx.bufS[:0], path, false)
 	if len(ctx.bufS) == 0 {
 		return
 	}
@@ -376,32 +418,10 @@ func (ctx *Ctx) rloop(path []byte, r *node, nodes []node) {
 	}
 }
 
-func (ctx *Ctx) replaceQB(path []byte) []byte {
-	qbLi := bytes.Index(path, qbL)
-	qbRi := bytes.Index(path, qbR)
-	if qbLi != -1 && qbRi != -1 && qbLi < qbRi && qbRi < len(path) {
-		ctx.BufAcc.StakeOut()
-		ctx.BufAcc.Write(path[0:qbLi]).Write(dot)
-		ctx.chQB = false
-		ctx.bufX = ctx.get(path[qbLi+1:qbRi], nil)
-		if ctx.bufX != nil {
-			if err := ctx.BufAcc.WriteX(ctx.bufX).Error(); err != nil {
-				ctx.Err = err
-				ctx.chQB = true
-				return nil
-			}
-		}
-		ctx.chQB = true
-		ctx.BufAcc.Write(path[qbRi+1:])
-		path = ctx.BufAcc.StakedBytes()
-	}
-	return path
-}
-
 // Compare method.
 func (ctx *Ctx) cmp(path []byte, cond op, right []byte) bool {
 	// Split path.
-	ctx.bufS = tokenize(ctx.bufS[:0], byteconv.B2S(path))
+	ctx.bufS = ctx.splitPath(ctx.bufS[:0], path, false)
 	if len(ctx.bufS) == 0 {
 		return false
 	}
@@ -410,6 +430,8 @@ func (ctx *Ctx) cmp(path []byte, cond op, right []byte) bool {
 		v := &ctx.vars[i]
 		if v.key == ctx.bufS[0] {
 			// Compare var with right value using inspector.
+			// Inspector may leave result untouched (eg: in case of types mismatch), so reset it first.
+			ctx.bufBl = false
 			ctx.Err = v.ins.Compare(v.val, inspector.Op(cond), byteconv.B2S(right), &ctx.bufBl, ctx.bufS[1:]...)
 			if ctx.Err != nil {
 				return false
@@ -451,12 +473,22 @@ func (ctx *Ctx) Reset() {
 	ctx.bufS = ctx.bufS[:0]
 	ctx.bufA = ctx.bufA[:0]
 	ctx.bufLC = ctx.bufLC[:0]
+	ctx.bufKB = ctx.bufKB[:0]
+	ctx.bufDP, ctx.bufSP = ctx.bufDP[:0], ctx.bufSP[:0]
+	ctx.bufAD, ctx.bufAS = ctx.bufAD[:0], ctx.bufAS[:0]
+	ctx.pd = 0
+	ctx.stk = ctx.stk[:0]
+	ctx.wd = 0
+	ctx.bufSV = ctx.bufSV[:0]
+	ctx.fret = nil
+	ctx.flags = ctx.flags[:0]
 	ctx.bufI, ctx.bufI_ = 0, 0
 	ctx.BufAcc.Reset()
 	ctx.Buf.Reset()
 	ctx.Buf1.Reset()
 	ctx.Buf2.Reset()
 
-	ctx.brkD = 0
+	ctx.brkD, ctx.brkS = 0, nil
+	ctx.ret = false
 	ctx.rl.Reset()
 }
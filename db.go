@@ -18,6 +18,8 @@ type db struct {
 	idxHash map[uint64]int
 	// Decoders storage.
 	buf []*Decoder
+	// Generation of registries the decoders bound to (see Rebind()).
+	gen uint64
 }
 
 func initDB() *db {
@@ -33,9 +35,10 @@ func (db *db) set(id int, key string, tree *Tree) {
 	dec := Decoder{
 		ID:   id,
 		Key:  key,
-		tree: tree,
+		orig: tree,
 	}
 	db.mux.Lock()
+	dec.tree, dec.err = db.inheritLF(key, tree)
 	var idx int
 	if idx = db.getIdxLF(id, key); idx >= 0 && idx < len(db.buf) {
 		db.buf[idx] = &dec
@@ -52,11 +55,14 @@ func (db *db) set(id int, key string, tree *Tree) {
 	if _, ok := db.idxHash[tree.hsum]; !ok {
 		db.idxHash[tree.hsum] = idx
 	}
+	// Rebuild decoders inherited from this one.
+	db.rebuildLF(key, 0)
 	db.mux.Unlock()
 }
 
 // Get first decoder found by key or ID.
 func (db *db) get(id int, key string) (dec *Decoder) {
+	db.checkGen()
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 	if idx := db.getIdxLF(id, key); idx >= 0 && idx < len(db.buf) {
@@ -91,6 +97,7 @@ func (db *db) getKey(key string) *Decoder {
 // Get decoder by key and fallback key.
 func (db *db) getKey1(key, key1 string) (dec *Decoder) {
 	idx := -1
+	db.checkGen()
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 	idx1, ok := db.idxKey[key]
@@ -111,7 +118,7 @@ func (db *db) getTreeByHash(hsum uint64) *Tree {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 	if idx, ok := db.idxHash[hsum]; ok && idx >= 0 && idx < len(db.buf) {
-		return db.buf[idx].tree
+		return db.buf[idx].orig
 	}
 	return nil
 }
@@ -122,6 +129,7 @@ func (db *db) getBKeys(bkeys [][]byte) (dec *Decoder) {
 	if l == 0 {
 		return
 	}
+	db.checkGen()
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 	_ = bkeys[l-1]
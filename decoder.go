@@ -9,9 +9,14 @@ import (
 // Decoder contains only parsed ruleset.
 // All temporary and intermediate data should be store in context logic to make using of decoders thread-safe.
 type Decoder struct {
-	ID   int
-	Key  string
+	ID  int
+	Key string
+	// Tree to apply, in case of inheritance contains rules merged with parent's rules.
 	tree *Tree
+	// Tree as it was registered.
+	orig *Tree
+	// Inheritance error (eg: parent isn't registered yet).
+	err error
 }
 
 var decDB = initDB()
@@ -47,7 +52,7 @@ func Decode(key string, ctx *Ctx) error {
 		return ErrDecoderNotFound
 	}
 	// Decode corresponding ruleset.
-	return DecodeRuleset(dec.tree.nodes, ctx)
+	return ctx.call(dec)
 }
 
 // DecodeFallback applies decoder rules using one of keys: key or fallback key.
@@ -67,7 +72,7 @@ func DecodeFallback(key, fbKey string, ctx *Ctx) error {
 		return ErrDecoderNotFound
 	}
 	// Decode corresponding ruleset.
-	return DecodeRuleset(dec.tree.nodes, ctx)
+	return ctx.call(dec)
 }
 
 // DecodeByID applies decoder rules using given id.
@@ -77,11 +82,23 @@ func DecodeByID(id int, ctx *Ctx) error {
 		return ErrDecoderNotFound
 	}
 	// Decode corresponding ruleset.
-	return DecodeRuleset(dec.tree.nodes, ctx)
+	return ctx.call(dec)
 }
 
 // DecodeRuleset applies decoder ruleset without using id.
 func DecodeRuleset(ruleset Ruleset, ctx *Ctx) (err error) {
+	if err = decodeRuleset(ruleset, ctx); err == ErrReturn {
+		// Early return caught - stop the decoding without error.
+		ctx.ret = false
+		err = nil
+	}
+	return
+}
+
+// Internal ruleset applier.
+//
+// Unlike DecodeRuleset() passes control signals (return, break, ...) to the caller.
+func decodeRuleset(ruleset Ruleset, ctx *Ctx) (err error) {
 	n := len(ruleset)
 	if n == 0 {
 		return
@@ -101,8 +118,17 @@ func followRule(r *node, ctx *Ctx) (err error) {
 	case r.typ == typeLoopRange:
 		// Evaluate range loops.
 		// See Ctx.rloop().
-		ctx.brkD = 0
+		ctx.brkD, ctx.brkS = 0, nil
 		ctx.rloop(r.loopSrc, r, r.child)
+		if ctx.ret {
+			err = ErrReturn
+			return
+		}
+		if ctx.brkS != nil {
+			// Pass loop control signal to the outer loop.
+			err, ctx.brkS = ctx.brkS, nil
+			return
+		}
 		if ctx.Err != nil {
 			err = ctx.Err
 			return
@@ -110,8 +136,17 @@ func followRule(r *node, ctx *Ctx) (err error) {
 	case r.typ == typeLoopCount:
 		// Evaluate counter loops.
 		// See Ctx.cloop().
-		ctx.brkD = 0
+		ctx.brkD, ctx.brkS = 0, nil
 		ctx.cloop(r, r.child)
+		if ctx.ret {
+			err = ErrReturn
+			return
+		}
+		if ctx.brkS != nil {
+			// Pass loop control signal to the outer loop.
+			err, ctx.brkS = ctx.brkS, nil
+			return
+		}
 		if ctx.Err != nil {
 			err = ctx.Err
 			return
@@ -126,13 +161,31 @@ func followRule(r *node, ctx *Ctx) (err error) {
 		err = ErrLBreakLoop
 	case r.typ == typeContinue:
 		// Go to next iteration of loop.
+		ctx.brkD = r.loopBrkD
 		err = ErrContLoop
+	case r.typ == typeReturn:
+		if len(r.src) > 0 || len(r.mod) > 0 {
+			// Return value of user-defined function.
+			if ctx.fret, err = ctx.eval(r); err != nil {
+				return
+			}
+			if ctx.Err != nil {
+				err = ctx.Err
+				return
+			}
+		}
+		// Stop the decoding.
+		err = ErrReturn
 	case r.typ == typeCondOK:
 		// Condition-OK node evaluates expressions like if-ok with helper.
 		var ok bool
 		// Check condition-OK helper (mandatory at all).
 		if len(r.condHlp) > 0 {
-			fn := GetCondOKFn(byteconv.B2S(r.condHlp))
+			fn := r.condOKFn
+			if fn == nil {
+				// Helper wasn't registered at parse time.
+				fn = GetCondOKFn(byteconv.B2S(r.condHlp))
+			}
 			if fn == nil {
 				err = ErrCondHlpNotFound
 				return
@@ -193,7 +246,10 @@ func followRule(r *node, ctx *Ctx) (err error) {
 		switch {
 		case len(r.condHlp) > 0 && r.condLC == lcNone:
 			// Condition helper caught (no LC case).
-			fn := GetCondFn(byteconv.B2S(r.condHlp))
+			fn := r.condFn
+			if fn == nil {
+				fn = GetCondFn(byteconv.B2S(r.condHlp))
+			}
 			if fn == nil {
 				err = ErrCondHlpNotFound
 				return
@@ -242,8 +298,29 @@ func followRule(r *node, ctx *Ctx) (err error) {
 				err = followRule(&r.child[1], ctx)
 			}
 		}
+	case r.typ == typeAutomap:
+		// Map fields of source to destination.
+		err = ctx.automap(r)
+	case r.typ == typeDecode || r.typ == typeUse:
+		// Call sub-decoder.
+		err = ctx.subDecode(r)
+	case r.typ == typeFlag:
+		// Apply rules of preprocessor block according feature flag.
+		i := 1
+		if ctx.Flag(byteconv.B2S(r.flag)) {
+			i = 0
+		}
+		err = decodeRuleset(r.child[i].child, ctx)
+	case r.typ == typeWith:
+		// Grow destination slice and apply rules to the new item.
+		wd := ctx.wd
+		if err = ctx.with(r); err != nil {
+			return
+		}
+		err = decodeRuleset(r.child, ctx)
+		ctx.wd = wd
 	case r.typ == typeCondTrue || r.typ == typeCondFalse || r.typ == typeCase || r.typ == typeDefault:
-		if err = DecodeRuleset(r.child, ctx); err != nil {
+		if err = decodeRuleset(r.child, ctx); err != nil {
 			return
 		}
 	case r.typ == typeSwitch:
@@ -278,7 +355,10 @@ func followRule(r *node, ctx *Ctx) (err error) {
 				if ch.typ == typeCase {
 					if len(ch.caseHlp) > 0 {
 						// Case condition helper caught.
-						fn := GetCondFn(byteconv.B2S(ch.caseHlp))
+						fn := ch.condFn
+						if fn == nil {
+							fn = GetCondFn(byteconv.B2S(ch.caseHlp))
+						}
 						if fn == nil {
 							err = ErrCondHlpNotFound
 							return
@@ -389,69 +469,87 @@ func followRule(r *node, ctx *Ctx) (err error) {
 			return
 		}
 		// Assign result to destination.
-		err = ctx.set2(r.dsta, ctx.bufX, r.ins)
-	case len(r.dst) > 0 && len(r.src) > 0 && r.static:
-		// V2V node with static source.
-		// Just assign the source it to destination.
-		ctx.buf = append(ctx.buf[:0], r.src...)
-		err = ctx.set2(r.dsta, &ctx.buf, r.ins)
-	case len(r.dst) > 0 && (len(r.src) > 0 || len(r.mod) > 0) && !r.static:
-		// V2V node with dynamic source.
-		// Get source value.
+		raw := ctx.bufX
+		err = ctx.assign(r, raw)
+	case len(r.dst) > 0 && (len(r.src) > 0 || len(r.mod) > 0):
+		// V2V node.
 		var raw any
-		if len(r.src) > 0 {
-			raw, _ = ctx.get2(r.srca, r.subset)
-			if ctx.Err != nil {
-				err = ctx.Err
-				return
-			}
+		if raw, err = ctx.eval(r); err != nil || (!r.static && ctx.Err != nil) {
+			// Modifier's error keeps in the context.
+			return
 		}
-		// Apply modifiers.
-		if n := len(r.mod); n > 0 {
-			_ = r.mod[n-1]
-			for i := 0; i < n; i++ {
-				m := &r.mod[i]
-				// Collect arguments to buffer.
-				ctx.bufA = ctx.bufA[:0]
-				if k := len(m.arg); k > 0 {
-					_ = m.arg[k-1]
-					for j := 0; j < k; j++ {
-						a := m.arg[j]
-						if a.global {
-							ctx.bufA = append(ctx.bufA, GetGlobal(byteconv.B2S(a.val)))
-						} else if a.static {
-							ctx.bufA = append(ctx.bufA, &a.val)
-						} else {
-							val := ctx.get(a.val, a.subset)
-							ctx.bufA = append(ctx.bufA, val)
-						}
+		// Assign to destination.
+		err = ctx.assign(r, raw)
+	}
+	return
+}
+
+// Evaluate source of the node (static value or variable with modifiers).
+func (ctx *Ctx) eval(r *node) (raw any, err error) {
+	if r.static {
+		// Static source.
+		ctx.buf = append(ctx.buf[:0], r.src...)
+		raw = &ctx.buf
+		return
+	}
+	// Get source value.
+	if len(r.src) > 0 {
+		raw, _ = ctx.get2(ctx.srcPath(r), r.subset)
+		if ctx.Err != nil {
+			err = ctx.Err
+			return
+		}
+	}
+	// Apply modifiers.
+	if n := len(r.mod); n > 0 {
+		_ = r.mod[n-1]
+		for i := 0; i < n; i++ {
+			m := &r.mod[i]
+			// Collect arguments to buffer.
+			ctx.bufA = ctx.bufA[:0]
+			if k := len(m.arg); k > 0 {
+				_ = m.arg[k-1]
+				for j := 0; j < k; j++ {
+					a := m.arg[j]
+					if a.global {
+						ctx.bufA = append(ctx.bufA, GetGlobal(byteconv.B2S(a.val)))
+					} else if a.static {
+						ctx.bufA = append(ctx.bufA, &a.val)
+					} else {
+						val := ctx.get(a.val, a.subset)
+						ctx.bufA = append(ctx.bufA, val)
 					}
 				}
-				ctx.bufX = raw
-				// Call the modifier func.
-				ctx.Err = m.fn(ctx, &ctx.bufX, ctx.bufX, ctx.bufA)
-				if ctx.Err != nil {
-					break
-				}
-				raw = ctx.bufX
 			}
+			ctx.bufX = raw
+			// Call the modifier func.
+			ctx.Err = m.fn(ctx, &ctx.bufX, ctx.bufX, ctx.bufA)
+			if ctx.Err != nil {
+				return
+			}
+			raw = ctx.bufX
 		}
-		if ctx.Err != nil {
-			return
-		}
-		// Assign to destination.
-		err = ctx.set2(r.dsta, raw, r.ins)
 	}
 	return
 }
 
-func (ctx *Ctx) cmpLC(lc lc, path []byte, cond op, right []byte) bool {
-	ctx.Err = nil
-	if ctx.chQB {
-		path = ctx.replaceQB(path)
+// Check if loop control signal (break, continue, ...) addressed to one of the outer loops.
+//
+// In that case the signal stays pending to pass it to the outer loop (see followRule()), otherwise the signal is consumed
+// by the current loop.
+func (ctx *Ctx) loopSignalOuter(sig error) bool {
+	if ctx.brkD > 1 {
+		ctx.brkD--
+		ctx.brkS = sig
+		return true
 	}
+	ctx.brkD = 0
+	return false
+}
 
-	ctx.bufS = tokenize(ctx.bufS[:0], byteconv.B2S(path))
+func (ctx *Ctx) cmpLC(lc lc, path []byte, cond op, right []byte) bool {
+	ctx.Err = nil
+	ctx.bufS = ctx.splitPath(ctx.bufS[:0], path, false)
 	if len(ctx.bufS) == 0 {
 		return false
 	}
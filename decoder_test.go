@@ -1,9 +1,14 @@
 package decoder
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
+	"github.com/koykov/inspector"
 	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/inspector/testobj_ins"
+	"github.com/koykov/jsonvector"
 )
 
 func TestDecoder(t *testing.T) {
@@ -14,6 +19,311 @@ func TestDecoder(t *testing.T) {
 
 	t.Run("loop_range", func(t *testing.T) { testDecoder(t, "src", scenarioNop) })
 	t.Run("loop_counter", func(t *testing.T) { testDecoder(t, "src", scenarioLoop1) })
+	t.Run("loop_label_break", func(t *testing.T) { testDecoder(t, "src", scenarioLoopLabelBreak) })
+	t.Run("loop_label_continue", func(t *testing.T) { testDecoder(t, "src", scenarioLoopLabelContinue) })
+	t.Run("path_dynamic", func(t *testing.T) { testDecoder(t, "src", scenarioPathDynamic) })
+	t.Run("path_dynamic_unresolved", func(t *testing.T) {
+		// Inspector fails to get "tags.x", so the index uses as literal key and the error mustn't break next rules.
+		tree, err := Parse([]byte("obj.Name = jso.list[tags.x].a\nobj.Id = jso.identifier"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("path_dynamic_unresolved", tree)
+		ctx := NewCtx()
+		obj := &testobj.TestObject{}
+		ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+		ctx.Set("tags", []string{"a"}, inspector.StringsInspector{})
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(jsonSrc["src"])
+		ctx.SetVector("jso", vec)
+		if err = Decode("path_dynamic_unresolved", ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertB(t, "Name", obj.Name, nil)
+		assertS(t, "Id", obj.Id, "xf44e")
+	})
+	t.Run("push", func(t *testing.T) { testDecoder(t, "src", scenarioPush) })
+	t.Run("push_unregistered", func(t *testing.T) {
+		// TestStruct has no own inspector, items are filling through inspector of TestObject1.
+		tree, err := Parse([]byte("for _, v := range jso.list {\n  with obj.StructSlice[+] as it {\n    it.S = v.a\n  }\n  with obj.StructPtrSlice[+] as it {\n    it.S = v.a\n    it.I = jso.person.status\n  }\n}\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("push_unregistered", tree)
+		ctx := NewCtx()
+		obj := &testobj.TestObject1{StructSlice: make([]testobj.TestStruct, 0, 8)}
+		ctx.Set("obj", obj, testobj_ins.TestObject1Inspector{})
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(jsonSrc["src"])
+		ctx.SetVector("jso", vec)
+		if err = Decode("push_unregistered", ctx); err != nil {
+			t.Fatal(err)
+		}
+		if len(obj.StructSlice) != 3 || obj.StructSlice[2].S != "d" {
+			t.Errorf("StructSlice test failed: %v", obj.StructSlice)
+		}
+		if len(obj.StructPtrSlice) != 3 || obj.StructPtrSlice[1].S != "c" || obj.StructPtrSlice[1].I != 67 {
+			t.Errorf("StructPtrSlice test failed: %v", obj.StructPtrSlice)
+		}
+	})
+	t.Run("automap", func(t *testing.T) { testDecoder(t, "src", scenarioAutomap) })
+	t.Run("automap_strategy", func(t *testing.T) { testDecoder(t, "automap", scenarioAutomapStrategy) })
+	t.Run("automap_fold", func(t *testing.T) {
+		tree, err := Parse([]byte("automap(obj, jso)"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("automap_fold", tree)
+		decode := func(src string) *testobj.TestObject {
+			ctx := NewCtx()
+			obj := &testobj.TestObject{}
+			ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+			vec := jsonvector.NewVector()
+			_ = vec.ParseStr(src)
+			ctx.SetVector("jso", vec)
+			if err := Decode("automap_fold", ctx); err != nil {
+				t.Fatal(err)
+			}
+			return obj
+		}
+		obj := decode(`{"NAME":"foo","status":1}`)
+		assertB(t, "Name", obj.Name, []byte("foo"))
+		var key any
+		fields := tree.nodes[0].am.plans[amPair{dst: reflect.TypeOf(obj)}].fields
+		for i := 0; i < len(fields); i++ {
+			if fields[i].dst == "Name" {
+				key = fields[i].key.Load()
+			}
+		}
+		if key != "NAME" {
+			t.Errorf("matched key must be cached, got %v", key)
+		}
+		// Key of another case must be found as well.
+		obj = decode(`{"name":"bar","STATUS":2}`)
+		assertB(t, "Name", obj.Name, []byte("bar"))
+		assertI32(t, "Status", obj.Status, 2)
+	})
+	t.Run("decode", func(t *testing.T) { testDecoder(t, "src", scenarioDecode) })
+	t.Run("extends", func(t *testing.T) { testDecoder(t, "src", scenarioExtends) })
+	t.Run("extends_rebuild", func(t *testing.T) {
+		register := func(key, body string) {
+			tree, err := Parse([]byte(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			RegisterDecoderKey(key, tree)
+		}
+		register("extends_rebuild/child", "extends \"extends_rebuild/base\"\nobj.Name = jso.person.full_name")
+		ctx := NewCtx()
+		obj := &testobj.TestObject{}
+		ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(jsonSrc["src"])
+		ctx.SetVector("jso", vec)
+		if err := Decode("extends_rebuild/child", ctx); !errors.Is(err, ErrParentNotFound) {
+			t.Errorf("expected error %s, got %v", ErrParentNotFound, err)
+		}
+
+		register("extends_rebuild/base", "obj.Id = jso.identifier")
+		if err := Decode("extends_rebuild/child", ctx); err != nil {
+			t.Error(err)
+		}
+		assertS(t, "Id", obj.Id, "xf44e")
+		assertB(t, "Name", obj.Name, []byte("Marquis Warren"))
+
+		// Re-register parent, child must see new rules.
+		register("extends_rebuild/base", "obj.Status = jso.person.status")
+		obj.Clear()
+		if err := Decode("extends_rebuild/child", ctx); err != nil {
+			t.Error(err)
+		}
+		assertS(t, "Id", obj.Id, "")
+		assertI32(t, "Status", obj.Status, 67)
+		assertB(t, "Name", obj.Name, []byte("Marquis Warren"))
+	})
+	t.Run("extends_loop", func(t *testing.T) {
+		for _, x := range [][2]string{{"extends_loop/a", "extends_loop/b"}, {"extends_loop/b", "extends_loop/a"}} {
+			tree, _ := Parse([]byte("extends \"" + x[1] + "\"\nobj.Id = jso.identifier"))
+			RegisterDecoderKey(x[0], tree)
+		}
+		ctx := NewCtx()
+		if err := Decode("extends_loop/a", ctx); !errors.Is(err, ErrInheritanceLoop) {
+			t.Errorf("expected error %s, got %v", ErrInheritanceLoop, err)
+		}
+	})
+	t.Run("extends_scope", func(t *testing.T) {
+		register := func(key, body string) {
+			tree, err := Parse([]byte(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			RegisterDecoderKey(key, tree)
+		}
+		decode := func(key string) *testobj.TestObject {
+			ctx := NewCtx()
+			obj := &testobj.TestObject{}
+			ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+			vec := jsonvector.NewVector()
+			_ = vec.Parse(jsonSrc["src"])
+			ctx.SetVector("jso", vec)
+			if err := Decode(key, ctx); err != nil {
+				t.Fatal(err)
+			}
+			return obj
+		}
+		register("extends_scope/base", "const Limit = 3\nfunc lbl(x) {\n  return x\n}\nobj.Name = lbl(\"parent\")\n")
+
+		// Functions and constants of parent are available in child.
+		register("extends_scope/use", "extends \"extends_scope/base\"\nobj.Id = lbl(jso.identifier)\nobj.Status = Limit\n")
+		obj := decode("extends_scope/use")
+		assertS(t, "Id", obj.Id, "xf44e")
+		assertI32(t, "Status", obj.Status, 3)
+		assertB(t, "Name", obj.Name, []byte("parent"))
+
+		// Function of child overrides parent's one in parent's rules.
+		register("extends_scope/override", "extends \"extends_scope/base\"\nfunc lbl(x) {\n  return \"child\"\n}\n")
+		obj = decode("extends_scope/override")
+		assertB(t, "Name", obj.Name, []byte("child"))
+		obj = decode("extends_scope/base")
+		assertB(t, "Name", obj.Name, []byte("parent"))
+	})
+	t.Run("udf", func(t *testing.T) { testDecoder(t, "src", scenarioUDF) })
+	t.Run("const", func(t *testing.T) { testDecoder(t, "src", scenarioConst) })
+	t.Run("preproc", func(t *testing.T) { testDecoder(t, "src", scenarioPreproc) })
+	t.Run("preproc_flags", func(t *testing.T) {
+		decode := func(key string, flags ...string) *testobj.TestObject {
+			ctx := NewCtx()
+			obj := &testobj.TestObject{}
+			ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+			vec := jsonvector.NewVector()
+			_ = vec.Parse(jsonSrc["src"])
+			ctx.SetVector("jso", vec)
+			for _, f := range flags {
+				ctx.SetFlag(f, true)
+			}
+			if err := Decode(key, ctx); err != nil {
+				t.Error(err)
+			}
+			return obj
+		}
+		// Flags of the context.
+		obj := decode("decoder/preproc", "new_pricing", "names")
+		assertF64(t, "Cost", obj.Cost, 45.90421)
+		assertI32(t, "Status", obj.Status, 1)
+		assertB(t, "Name", obj.Name, []byte("Marquis Warren"))
+
+		// Flags passed to parser, flags of the context must be ignored.
+		tree, err := ParseFile("testdata/decoder/preproc.dec", Flags{"new_pricing": true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range tree.nodes {
+			if n.typ == typeFlag {
+				t.Fatal("preprocessor directive must be resolved at parse time")
+			}
+		}
+		RegisterDecoderKey("preproc_flags/static", tree)
+		obj = decode("preproc_flags/static", "names")
+		assertF64(t, "Cost", obj.Cost, 45.90421)
+		assertI32(t, "Status", obj.Status, 1)
+		assertB(t, "Name", obj.Name, nil)
+	})
+	t.Run("lazy", func(t *testing.T) {
+		tree, err := Parse([]byte("obj.Id = jso.identifier|testns::lazySuffix(\"-x\")"), ModeLazy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("lazy/suffix", tree)
+		ctx := NewCtx()
+		obj := &testobj.TestObject{}
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(jsonSrc["src"])
+		ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+		ctx.SetVector("jso", vec)
+		_ = Decode("lazy/suffix", ctx)
+		if !errors.Is(ctx.Err, ErrModNotFound) {
+			t.Errorf("expected error %s, got %v", ErrModNotFound, ctx.Err)
+		}
+
+		// Register modifier after parsing.
+		RegisterModFnNS("testns", "lazySuffix", "", func(ctx *Ctx, buf *any, val any, args []any) error {
+			ctx.BufAcc.StakeOut().WriteX(val).WriteX(args[0])
+			*buf = ctx.BufAcc.StakedString()
+			return nil
+		})
+		ctx.Reset()
+		ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+		ctx.SetVector("jso", vec)
+		if err = Decode("lazy/suffix", ctx); err != nil || ctx.Err != nil {
+			t.Error(err)
+		}
+		assertS(t, "Id", obj.Id, "xf44e-x")
+	})
+	t.Run("rebind", func(t *testing.T) {
+		tree, err := Parse([]byte("if testns::lateVIP(jso.person.status) {\n  obj.Status = jso.person.rank|default(testns::lateStatus)\n}\n"), ModeLazy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("rebind/vip", tree)
+		ctx := NewCtx()
+		obj := &testobj.TestObject{}
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(jsonSrc["src"])
+		ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+		ctx.SetVector("jso", vec)
+		if err = Decode("rebind/vip", ctx); !errors.Is(err, ErrCondHlpNotFound) {
+			t.Errorf("expected error %s, got %v", ErrCondHlpNotFound, err)
+		}
+		old := decDB.getKey("rebind/vip")
+
+		// Register helper and global after parsing.
+		RegisterCondFnNS("testns", "lateVIP", func(_ *Ctx, args []any) bool { return args[0] != nil })
+		RegisterGlobalNS("testns", "lateStatus", "", int32(7))
+		if err = Decode("rebind/vip", ctx); err != nil {
+			t.Error(err)
+		}
+		assertI32(t, "Status", obj.Status, 7)
+		if dec := decDB.getKey("rebind/vip"); dec == old || old.tree.nodes[0].condFn != nil {
+			t.Error("rebinding must not modify decoders in use")
+		}
+	})
+	t.Run("rebind_import", func(t *testing.T) {
+		lib, err := Parse([]byte("func rank() {\n  return jso.person.rank|default(testns::lateRank)\n}\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("rebind/lib", lib)
+		tree, err := Parse([]byte("import \"rebind/lib\"\nobj.Status = rank()\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("rebind/import", tree)
+		ctx := NewCtx()
+		obj := &testobj.TestObject{}
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(jsonSrc["src"])
+		ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+		ctx.SetVector("jso", vec)
+		if err = Decode("rebind/import", ctx); err != nil {
+			t.Error(err)
+		}
+		assertI32(t, "Status", obj.Status, 0)
+
+		// Imported function must see the global registered after parsing.
+		RegisterGlobalNS("testns", "lateRank", "", int32(9))
+		if err = Decode("rebind/import", ctx); err != nil {
+			t.Error(err)
+		}
+		assertI32(t, "Status", obj.Status, 9)
+	})
+	t.Run("sub_recursion", func(t *testing.T) {
+		ctx := NewCtx()
+		ctx.Set("obj", &testobj.TestObject{}, testobj_ins.TestObjectInspector{})
+		ctx.SetVector("jso", jsonvector.NewVector())
+		if err := Decode("decoder/sub_recursion", ctx); !errors.Is(err, ErrDecoderRecursion) {
+			t.Errorf("expected error %s, got %v", ErrDecoderRecursion, err)
+		}
+	})
 
 	t.Run("cond", func(t *testing.T) { testDecoder(t, "src", scenarioCond) })
 	t.Run("cond_else", func(t *testing.T) { testDecoder(t, "src", scenarioCond1) })
@@ -22,6 +332,10 @@ func TestDecoder(t *testing.T) {
 
 	t.Run("switch", func(t *testing.T) { testDecoder(t, "src", scenarioSwitch) })
 	t.Run("switch_no_cond", func(t *testing.T) { testDecoder(t, "src", scenarioSwitch) })
+
+	t.Run("return", func(t *testing.T) { testDecoder(t, "src", scenarioReturn) })
+	t.Run("return_if", func(t *testing.T) { testDecoder(t, "src", scenarioReturnIf) })
+	t.Run("return_loop", func(t *testing.T) { testDecoder(t, "src", scenarioReturnLoop) })
 }
 
 func testDecoder(t *testing.T, jsonKey string, assertFn func(t testing.TB, obj *testobj.TestObject)) {
@@ -38,6 +352,42 @@ func BenchmarkDecoder(b *testing.B) {
 
 	b.Run("loop_range", func(b *testing.B) { benchDecoder(b, "src", scenarioNop) })
 	b.Run("loop_counter", func(b *testing.B) { benchDecoder(b, "src", scenarioLoop1) })
+	b.Run("loop_label_break", func(b *testing.B) { benchDecoder(b, "src", scenarioLoopLabelBreak) })
+	b.Run("loop_label_continue", func(b *testing.B) { benchDecoder(b, "src", scenarioLoopLabelContinue) })
+	b.Run("path_dynamic", func(b *testing.B) { benchDecoder(b, "src", scenarioPathDynamic) })
+	b.Run("push", func(b *testing.B) { benchDecoder(b, "src", scenarioPush) })
+	b.Run("with_ptr", func(b *testing.B) {
+		// Items of pointers slice must reuse pointers left in capacity.
+		tree, err := Parse([]byte("for _, v := range jso.list {\n  with obj.StructPtrSlice[+] as it {\n    it.S = v.a\n    it.I = jso.person.status\n  }\n}\n"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		RegisterDecoderKey("bench/with_ptr", tree)
+		ctx := NewCtx()
+		obj := &testobj.TestObject1{}
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(jsonSrc["src"])
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx.Set("obj", obj, testobj_ins.TestObject1Inspector{})
+			ctx.SetVector("jso", vec)
+			if err = Decode("bench/with_ptr", ctx); err != nil {
+				b.Fatal(err)
+			}
+			if len(obj.StructPtrSlice) != 3 || obj.StructPtrSlice[1].S != "c" || obj.StructPtrSlice[1].I != 67 {
+				b.Fatalf("StructPtrSlice test failed: %v", obj.StructPtrSlice)
+			}
+			obj.StructPtrSlice = obj.StructPtrSlice[:0]
+			ctx.Reset()
+		}
+	})
+	b.Run("automap", func(b *testing.B) { benchDecoder(b, "src", scenarioAutomap) })
+	b.Run("automap_strategy", func(b *testing.B) { benchDecoder(b, "automap", scenarioAutomapStrategy) })
+	b.Run("extends", func(b *testing.B) { benchDecoder(b, "src", scenarioExtends) })
+	b.Run("udf", func(b *testing.B) { benchDecoder(b, "src", scenarioUDF) })
+	b.Run("const", func(b *testing.B) { benchDecoder(b, "src", scenarioConst) })
+	b.Run("preproc", func(b *testing.B) { benchDecoder(b, "src", scenarioPreproc) })
+	b.Run("decode", func(b *testing.B) { benchDecoder(b, "src", scenarioDecode) })
 
 	b.Run("cond", func(b *testing.B) { benchDecoder(b, "src", scenarioCond) })
 	b.Run("cond_else", func(b *testing.B) { benchDecoder(b, "src", scenarioCond1) })
@@ -47,6 +397,9 @@ func BenchmarkDecoder(b *testing.B) {
 
 	b.Run("switch", func(b *testing.B) { benchDecoder(b, "src", scenarioSwitch) })
 	b.Run("switch_no_cond", func(b *testing.B) { benchDecoder(b, "src", scenarioSwitch) })
+
+	b.Run("return", func(b *testing.B) { benchDecoder(b, "src", scenarioReturn) })
+	b.Run("return_if", func(b *testing.B) { benchDecoder(b, "src", scenarioReturnIf) })
 }
 
 func benchDecoder(b *testing.B, jsonKey string, assertFn func(t testing.TB, obj *testobj.TestObject)) {
@@ -102,6 +455,97 @@ func scenarioLoop1(t testing.TB, obj *testobj.TestObject) {
 	assertI32(t, "Status", obj.Status, 50)
 }
 
+func scenarioLoopLabelBreak(t testing.TB, obj *testobj.TestObject) {
+	assertI32(t, "Status", obj.Status, 3)
+	assertU64(t, "Ustate", obj.Ustate, 0)
+}
+
+func scenarioLoopLabelContinue(t testing.TB, obj *testobj.TestObject) {
+	assertI32(t, "Status", obj.Status, 4)
+	assertU64(t, "Ustate", obj.Ustate, 0)
+}
+
+func scenarioPathDynamic(t testing.TB, obj *testobj.TestObject) {
+	assertI32(t, "Flags[b]", obj.Flags["b"], 0)
+	assertI32(t, "Flags[c]", obj.Flags["c"], 1)
+	assertI32(t, "Flags[d]", obj.Flags["d"], 2)
+	perm := obj.Permission
+	assertBl(t, "Permission[67]", (*perm)[67], true)
+	assertS(t, "Id", obj.Id, "c")
+	assertB(t, "Name", obj.Name, []byte("d"))
+}
+
+func scenarioPush(t testing.TB, obj *testobj.TestObject) {
+	assertI32(t, "len(Finance.History)", int32(len(obj.Finance.History)), 4)
+	assertI64(t, "Finance.History[0].DateUnix", obj.Finance.History[0].DateUnix, 1)
+	assertB(t, "Finance.History[0].Comment", obj.Finance.History[0].Comment, []byte("xf44e"))
+	assertI64(t, "Finance.History[1].DateUnix", obj.Finance.History[1].DateUnix, 2)
+	assertF64(t, "Finance.History[1].Cost", obj.Finance.History[1].Cost, 45.90421)
+	assertB(t, "Finance.History[1].Comment", obj.Finance.History[1].Comment, []byte("b"))
+	assertB(t, "Finance.History[2].Comment", obj.Finance.History[2].Comment, []byte("c"))
+	assertB(t, "Finance.History[3].Comment", obj.Finance.History[3].Comment, []byte("d"))
+}
+
+func scenarioAutomap(t testing.TB, obj *testobj.TestObject) {
+	assertS(t, "Id", obj.Id, "xf44e")
+	assertB(t, "Name", obj.Name, []byte("Marquis Warren"))
+	assertI32(t, "Status", obj.Status, 67)
+	assertF64(t, "Cost", obj.Cost, 45.90421)
+	assertF64(t, "Finance.Balance", obj.Finance.Balance, 164.5962)
+	assertBl(t, "Finance.AllowBuy", obj.Finance.AllowBuy, true)
+}
+
+func scenarioAutomapStrategy(t testing.TB, obj *testobj.TestObject) {
+	assertF64(t, "Finance.MoneyIn", obj.Finance.MoneyIn, 1.5)
+	assertBl(t, "Finance.AllowBuy", obj.Finance.AllowBuy, true)
+	assertF64(t, "Finance.Balance", obj.Finance.Balance, 0)
+	assertF64(t, "Cost", obj.Cost, 4.5)
+}
+
+func scenarioDecode(t testing.TB, obj *testobj.TestObject) {
+	assertS(t, "Id", obj.Id, "xf44e")
+	assertF64(t, "Finance.Balance", obj.Finance.Balance, 164.5962)
+	assertBl(t, "Finance.AllowBuy", obj.Finance.AllowBuy, true)
+	assertF64(t, "Finance.MoneyIn", obj.Finance.MoneyIn, 0)
+	assertI32(t, "len(Finance.History)", int32(len(obj.Finance.History)), 3)
+	assertI64(t, "Finance.History[0].DateUnix", obj.Finance.History[0].DateUnix, 7)
+	assertB(t, "Finance.History[0].Comment", obj.Finance.History[0].Comment, []byte("b"))
+	assertB(t, "Finance.History[2].Comment", obj.Finance.History[2].Comment, []byte("d"))
+	assertF64(t, "Cost", obj.Cost, 45.90421)
+}
+
+func scenarioExtends(t testing.TB, obj *testobj.TestObject) {
+	assertS(t, "Id", obj.Id, "xf44e")
+	assertB(t, "Name", obj.Name, []byte("Marquis Warren"))
+	assertI32(t, "Status", obj.Status, 67)
+	assertF64(t, "Cost", obj.Cost, 45.90421)
+}
+
+func scenarioUDF(t testing.TB, obj *testobj.TestObject) {
+	assertB(t, "Name", obj.Name, []byte("Marquis Warren"))
+	assertS(t, "Id", obj.Id, "other")
+	assertF64(t, "Cost", obj.Cost, 45.90421)
+	assertI32(t, "Status", obj.Status, 1)
+	assertU64(t, "Ustate", obj.Ustate, 2)
+	assertF64(t, "Finance.Balance", obj.Finance.Balance, 164.5962)
+	assertBl(t, "Finance.AllowBuy", obj.Finance.AllowBuy, true)
+	assertF64(t, "Finance.MoneyIn", obj.Finance.MoneyIn, 1.5)
+}
+
+func scenarioConst(t testing.TB, obj *testobj.TestObject) {
+	assertB(t, "Name", obj.Name, []byte("active"))
+	assertI32(t, "Status", obj.Status, 4)
+	assertU64(t, "Ustate", obj.Ustate, 2)
+	assertS(t, "Id", obj.Id, "N/D")
+}
+
+func scenarioPreproc(t testing.TB, obj *testobj.TestObject) {
+	assertS(t, "Id", obj.Id, "xf44e")
+	assertF64(t, "Cost", obj.Cost, 0)
+	assertI32(t, "Status", obj.Status, 0)
+	assertB(t, "Name", obj.Name, nil)
+}
+
 func scenarioCond(t testing.TB, obj *testobj.TestObject) {
 	assertU64(t, "Ustate", obj.Ustate, 17)
 }
@@ -121,3 +565,17 @@ func scenarioCondOK1(t testing.TB, obj *testobj.TestObject) {
 func scenarioSwitch(t testing.TB, obj *testobj.TestObject) {
 	assertI32(t, "Status", obj.Status, 2)
 }
+
+func scenarioReturn(t testing.TB, obj *testobj.TestObject) {
+	assertS(t, "Id", obj.Id, "xf44e")
+	assertB(t, "Name", obj.Name, nil)
+}
+
+func scenarioReturnIf(t testing.TB, obj *testobj.TestObject) {
+	assertI32(t, "Status", obj.Status, 5)
+}
+
+func scenarioReturnLoop(t testing.TB, obj *testobj.TestObject) {
+	assertI32(t, "Status", obj.Status, 1)
+	assertB(t, "Name", obj.Name, nil)
+}
@@ -149,6 +149,8 @@ type docgen struct {
 	name, alias, typ, desc, note, example, ins string
 
 	params []docgenParam
+	// Signature to check calls (see WithSignature()).
+	sig *Signature
 }
 
 func (t *docgen) WithDescription(desc string) *docgen {
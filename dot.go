@@ -0,0 +1,209 @@
+package decoder
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/koykov/bytebuf"
+)
+
+// DOT renders control flow of the tree in Graphviz DOT format.
+//
+// Statements are boxes, conditions (if, condition-OK, switch and flag blocks) are diamonds with labeled edges of the
+// branches and loops are hexagons with back edges from the end of the body. Break, lazybreak and continue statements
+// lead to exit and to the header of the corresponding loop, return statement leads to the end of the function.
+// User-defined functions and macros are rendered as separate clusters.
+//
+// Use dot utility to get an image, eg: "dot -Tsvg tree.dot > tree.svg".
+func (t *Tree) DOT() []byte {
+	a := t.AST()
+	g := dotGraph{}
+	g.buf.WriteString("digraph decoder {\n").
+		WriteString(fmtIndent).WriteString("node [shape=box, fontname=\"monospace\"];\n").
+		WriteString(fmtIndent).WriteString("edge [fontname=\"monospace\"];\n")
+	for i := 0; i < len(a.Funcs); i++ {
+		f := &a.Funcs[i]
+		typ := "func"
+		if f.Macro {
+			typ = "macro"
+		}
+		g.buf.WriteString(fmtIndent).WriteString("subgraph \"cluster_").WriteString(f.Name).WriteString("\" {\n")
+		g.indent = fmtIndent + fmtIndent
+		g.buf.WriteString(g.indent).WriteString("label=")
+		dotQuote(&g.buf, typ+" "+f.Name+"("+strings.Join(f.Params, ", ")+")")
+		g.buf.WriteString(";\n")
+		g.graph(f.Body)
+		g.buf.WriteString(fmtIndent).WriteString("}\n")
+	}
+	g.indent = fmtIndent
+	g.graph(a.Stmts)
+	g.buf.WriteString("}\n")
+	return g.buf.Bytes()
+}
+
+// DOT graph builder.
+type dotGraph struct {
+	buf    bytebuf.Chain
+	indent string
+	// Nodes counter.
+	c int
+	// Stack of the loops around current statement.
+	loops []dotLoop
+	// End node of current graph.
+	end string
+}
+
+type dotLoop struct {
+	label string
+	head  string
+	// Edges leading out of the loop by break statements.
+	exits []dotEdge
+}
+
+// Edge that waits for the next statement.
+type dotEdge struct {
+	from, label string
+}
+
+// Render list of statements between start and end nodes.
+func (g *dotGraph) graph(list []Stmt) {
+	start := g.node("start", "oval")
+	g.end = g.node("end", "oval")
+	out := g.stmts(list, []dotEdge{{from: start}})
+	g.connect(out, g.end)
+}
+
+// Render list of statements and return edges leading out of the last statement.
+func (g *dotGraph) stmts(list []Stmt, in []dotEdge) []dotEdge {
+	for i := 0; i < len(list); i++ {
+		in = g.stmt(&list[i], in)
+	}
+	return in
+}
+
+func (g *dotGraph) stmt(s *Stmt, in []dotEdge) []dotEdge {
+	var buf bytebuf.Chain
+	switch {
+	case s.Kind == StmtFlag:
+		id := g.node(`flag("`+s.Flag+`")`, "diamond")
+		g.connect(in, id)
+		out := g.stmts(s.Then, []dotEdge{{id, "true"}})
+		return append(out, g.stmts(s.Else, []dotEdge{{id, "false"}})...)
+	case s.Kind == StmtIf && !s.Ternary:
+		srcHead(&buf, s)
+		id := g.node(strings.TrimPrefix(buf.String(), "if "), "diamond")
+		g.connect(in, id)
+		out := g.stmts(s.Then, []dotEdge{{id, "true"}})
+		return append(out, g.stmts(s.Else, []dotEdge{{id, "false"}})...)
+	case s.Kind == StmtSwitch:
+		srcHead(&buf, s)
+		id := g.node(buf.String(), "diamond")
+		g.connect(in, id)
+		var out []dotEdge
+		var dflt bool
+		for i := 0; i < len(s.Body); i++ {
+			c := &s.Body[i]
+			buf.Reset()
+			srcHead(&buf, c)
+			dflt = dflt || c.Kind == StmtDefault
+			out = append(out, g.stmts(c.Body, []dotEdge{{id, buf.StringCopy()}})...)
+		}
+		if !dflt {
+			out = append(out, dotEdge{id, "default"})
+		}
+		return out
+	case s.Kind == StmtLoopRange || s.Kind == StmtLoopCount:
+		srcHead(&buf, s)
+		id := g.node(buf.String(), "hexagon")
+		g.connect(in, id)
+		var label string
+		if s.Loop != nil {
+			label = s.Loop.Label
+		}
+		g.loops = append(g.loops, dotLoop{label: label, head: id})
+		body := g.stmts(s.Body, []dotEdge{{id, "next"}})
+		g.connect(body, id)
+		l := g.loops[len(g.loops)-1]
+		g.loops = g.loops[:len(g.loops)-1]
+		return append([]dotEdge{{id, "done"}}, l.exits...)
+	case s.Kind == StmtWith:
+		srcHead(&buf, s)
+		id := g.node(buf.String(), "box")
+		g.connect(in, id)
+		return g.stmts(s.Body, []dotEdge{{from: id}})
+	default:
+		srcLine(&buf, s)
+		id := g.node(buf.String(), "box")
+		g.connect(in, id)
+		switch s.Kind {
+		case StmtReturn:
+			g.connect([]dotEdge{{from: id}}, g.end)
+			return nil
+		case StmtBreak, StmtLazyBreak, StmtContinue:
+			l := g.loop(s)
+			if l == nil {
+				break
+			}
+			if s.Kind == StmtContinue {
+				g.connect([]dotEdge{{from: id}}, l.head)
+			} else {
+				l.exits = append(l.exits, dotEdge{from: id})
+			}
+			return nil
+		}
+		return []dotEdge{{from: id}}
+	}
+}
+
+// Get loop controlled by break/lazybreak/continue statement.
+func (g *dotGraph) loop(s *Stmt) *dotLoop {
+	if len(s.Label) > 0 {
+		for i := len(g.loops) - 1; i >= 0; i-- {
+			if g.loops[i].label == s.Label {
+				return &g.loops[i]
+			}
+		}
+		return nil
+	}
+	if i := len(g.loops) - 1 - s.Depth; i >= 0 && i < len(g.loops) {
+		return &g.loops[i]
+	}
+	return nil
+}
+
+// Write new node and return its ID.
+func (g *dotGraph) node(label, shape string) string {
+	g.c++
+	id := "n" + strconv.Itoa(g.c)
+	g.buf.WriteString(g.indent).WriteString(id).WriteString(" [label=")
+	dotQuote(&g.buf, label)
+	if shape != "box" {
+		g.buf.WriteString(", shape=").WriteString(shape)
+	}
+	g.buf.WriteString("];\n")
+	return id
+}
+
+// Write edges from all pending edges to the node.
+func (g *dotGraph) connect(in []dotEdge, to string) {
+	for _, e := range in {
+		g.buf.WriteString(g.indent).WriteString(e.from).WriteString(" -> ").WriteString(to)
+		if len(e.label) > 0 {
+			g.buf.WriteString(" [label=")
+			dotQuote(&g.buf, e.label)
+			g.buf.WriteByte(']')
+		}
+		g.buf.WriteString(";\n")
+	}
+}
+
+func dotQuote(buf *bytebuf.Chain, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(s[i])
+	}
+	buf.WriteByte('"')
+}
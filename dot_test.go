@@ -0,0 +1,27 @@
+package decoder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDOT(t *testing.T) {
+	files, _ := filepath.Glob("testdata/dot/*.dec")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".dec")
+		t.Run(name, func(t *testing.T) {
+			src, _ := os.ReadFile(file)
+			expect, _ := os.ReadFile(strings.Replace(file, ".dec", ".dot", 1))
+			tree, err := Parse(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r := tree.DOT(); !bytes.Equal(r, expect) {
+				t.Errorf("dot mismatch, need:\n%s\ngot:\n%s", expect, r)
+			}
+		})
+	}
+}
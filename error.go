@@ -19,9 +19,48 @@ var (
 	ErrBreakLoop     = errors.New("break loop")
 	ErrLBreakLoop    = errors.New("lazybreak loop")
 	ErrContLoop      = errors.New("continue loop")
+	ErrReturn        = errors.New("return")
+	ErrLoopCtlNoLoop = errors.New("break/continue outside of loop")
 
-	ErrSenselessCond   = errors.New("comparison of two static args")
-	ErrCondHlpNotFound = errors.New("condition helper not found")
+	ErrUnknownLoopLabel = errors.New("unknown loop label")
+	ErrDupLoopLabel     = errors.New("loop label already defined")
+
+	ErrWithNoSlice   = errors.New("with block requires a slice destination")
+	ErrDeleteNoMap   = errors.New("delete requires a map")
+	ErrDeleteKeyType = errors.New("unsupported map key type")
+	ErrAutomapOpt    = errors.New("malformed automap option")
+
+	ErrDecoderRecursion = errors.New("recursive decoder call")
+	ErrExtendsNotTop    = errors.New("extends must be the first statement of decoder")
+	ErrParentNotFound   = errors.New("parent decoder not found")
+	ErrInheritanceLoop  = errors.New("inheritance loop")
+	ErrFuncNotTop       = errors.New("functions and imports must be declared at the top level")
+	ErrFuncRedeclared   = errors.New("function already declared")
+	ErrLibraryNotFound  = errors.New("library not found")
+	ErrReturnValue      = errors.New("return with value outside of function")
+	ErrConstMalformed   = errors.New("malformed constant declaration")
+	ErrConstNoStatic    = errors.New("constant value must be static")
+	ErrConstRedeclared  = errors.New("constant already declared")
+	ErrTplMalformed     = errors.New("malformed template placeholder")
+	ErrTplVarNotFound   = errors.New("template variable not found")
+	ErrTplVarValue      = errors.New("template variable value isn't allowed")
+	ErrUnexpectedDir    = errors.New("unexpected preprocessor directive")
+
+	ErrSenselessCond    = errors.New("comparison of two static args")
+	ErrCondHlpNotFound  = errors.New("condition helper not found")
+	ErrModNotFound      = errors.New("modifier not found")
+	ErrGetterNotFound   = errors.New("getter not found")
+	ErrCallbackNotFound = errors.New("callback not found")
+	ErrSignature        = errors.New("arguments don't match function signature")
+	ErrMalformedPath    = errors.New("malformed path")
+	ErrMalformedValue   = errors.New("malformed value")
+	ErrVersion          = errors.New("unsupported format version")
+	ErrCorruptedData    = errors.New("corrupted data")
+
+	ErrPathNotFound = errors.New("path not found")
+	ErrPathReadOnly = errors.New("path isn't writable")
+	ErrTypeMismatch = errors.New("type mismatch")
+	ErrVarUnbound   = errors.New("unbound variable")
 
 	ErrUnknownPool = errors.New("unknown pool")
 
@@ -0,0 +1,290 @@
+package decoder
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/koykov/decoder/internal/source"
+)
+
+// Indentation of nested rules.
+const fmtIndent = "  "
+
+// Format re-emits decoder source in canonical form.
+//
+// Every rule places on its own line with indentation of two spaces per nesting level. Spaces around assignments and
+// comparison operators are normalized, modifiers are chained without spaces and called by their names instead of
+// aliases (eg: "x|def(1)" -> "x|default(1)"). Comments and single blank lines are kept.
+func Format(src []byte) ([]byte, error) {
+	var (
+		buf   bytes.Buffer
+		depth int
+		// Flags of opened blocks, true means switch block.
+		sw []bool
+		// Inside of constants block.
+		cb    bool
+		blank bool
+	)
+	line := func(d int, text string) {
+		if blank && buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		blank = false
+		for i := 0; i < d; i++ {
+			buf.WriteString(fmtIndent)
+		}
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+	}
+	stmts := source.Split(src)
+	for i := 0; i < len(stmts); i++ {
+		st := &stmts[i]
+		switch st.Kind {
+		case source.Blank:
+			blank = true
+		case source.Comment, source.DirIf, source.DirElse, source.DirEndif:
+			// Preprocessor directives don't affect indentation.
+			d := depth
+			if cb {
+				d++
+			}
+			line(d, st.Text)
+		case source.Open:
+			line(depth, fmtStmt(st.Text))
+			depth++
+			sw = append(sw, strings.HasPrefix(st.Text, "switch"))
+		case source.Else:
+			if depth == 0 {
+				return nil, ErrUnexpectedClose
+			}
+			line(depth-1, "} else {")
+		case source.Case:
+			d := depth
+			if len(sw) > 0 && sw[len(sw)-1] {
+				// Cases have the same indentation as switch.
+				d--
+			}
+			line(d, fmtStmt(st.Text))
+		case source.Close:
+			if len(sw) == 0 {
+				return nil, ErrUnexpectedClose
+			}
+			depth--
+			sw = sw[:len(sw)-1]
+			line(depth, "}")
+		default:
+			text := fmtStmt(st.Text)
+			switch {
+			case cb && text == ")":
+				cb = false
+				line(depth, text)
+			case cb:
+				line(depth+1, text)
+			default:
+				cb = text == "const ("
+				line(depth, text)
+			}
+		}
+	}
+	if len(sw) > 0 {
+		return nil, ErrUnbalancedCtl
+	}
+	return buf.Bytes(), nil
+}
+
+// Kind of token in the rule.
+type fmtTkn int
+
+const (
+	tknWord fmtTkn = iota
+	tknStr
+	tknOp
+	tknColon
+	tknComma
+	tknSemicolon
+	tknLParen
+	tknRParen
+	tknPipe
+	tknIncDec
+	tknBrace
+	tknComment
+)
+
+type fmtToken struct {
+	typ fmtTkn
+	val string
+}
+
+// Format single rule.
+func fmtStmt(text string) string {
+	tokens := fmtTokenize(text)
+	var (
+		buf     strings.Builder
+		ternary bool
+		depth   int
+	)
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].typ == tknOp && tokens[i].val == "?" {
+			ternary = true
+		}
+	}
+	for i := 0; i < len(tokens); i++ {
+		t := &tokens[i]
+		if t.typ == tknWord && i > 0 && tokens[i-1].typ == tknPipe {
+			// Modifier call, replace alias with the name.
+			if name := GetModName(t.val); len(name) > 0 {
+				t.val = name
+			}
+		}
+		if i > 0 && fmtSpace(&tokens[i-1], t, ternary && depth == 0) {
+			buf.WriteByte(' ')
+		}
+		switch t.typ {
+		case tknLParen:
+			depth++
+		case tknRParen:
+			depth--
+		}
+		buf.WriteString(t.val)
+	}
+	return buf.String()
+}
+
+// Check if tokens a and b should be separated with space.
+func fmtSpace(a, b *fmtToken, ternary bool) bool {
+	switch {
+	case a.typ == tknLParen || a.typ == tknPipe:
+		return false
+	case b.typ == tknRParen || b.typ == tknPipe || b.typ == tknComma || b.typ == tknSemicolon || b.typ == tknIncDec:
+		return false
+	case b.typ == tknColon:
+		// Ternary colon is an operator, other ones (labels, cases, options) are suffixes.
+		return ternary
+	case b.typ == tknLParen:
+		return a.typ != tknWord || fmtKeyword(a.val)
+	case b.typ == tknWord && (b.val[0] == '.' || b.val[0] == '['):
+		// Type assertion after call, eg: "new(x).(x)".
+		return a.typ != tknRParen
+	default:
+		return true
+	}
+}
+
+func fmtKeyword(s string) bool {
+	switch s {
+	case "if", "for", "switch", "case", "return", "const", "range", "as":
+		return true
+	}
+	return false
+}
+
+// Split rule to tokens.
+func fmtTokenize(text string) []fmtToken {
+	var r []fmtToken
+	add := func(typ fmtTkn, val string) {
+		r = append(r, fmtToken{typ: typ, val: val})
+	}
+	// Check if minus at position i is a sign of number.
+	sign := func(i int) bool {
+		if i+1 >= len(text) || text[i+1] < '0' || text[i+1] > '9' {
+			return false
+		}
+		if len(r) == 0 {
+			return true
+		}
+		switch r[len(r)-1].typ {
+		case tknOp, tknColon, tknComma, tknLParen, tknSemicolon:
+			return true
+		}
+		return false
+	}
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"' || c == '\'' || c == '`':
+			j := strings.IndexByte(text[i+1:], c)
+			if j == -1 {
+				add(tknStr, text[i:])
+				return r
+			}
+			add(tknStr, text[i:i+j+2])
+			i += j + 2
+		case c == '/' && i+1 < len(text) && text[i+1] == '/':
+			// Trailing comment, eg: in constants block.
+			add(tknComment, text[i:])
+			return r
+		case c == '(':
+			add(tknLParen, "(")
+			i++
+		case c == ')':
+			add(tknRParen, ")")
+			i++
+		case c == ',':
+			add(tknComma, ",")
+			i++
+		case c == ';':
+			add(tknSemicolon, ";")
+			i++
+		case c == '|':
+			add(tknPipe, "|")
+			i++
+		case c == '{' || c == '}':
+			add(tknBrace, string(c))
+			i++
+		case strings.HasPrefix(text[i:], "++") || strings.HasPrefix(text[i:], "--"):
+			add(tknIncDec, text[i:i+2])
+			i += 2
+		case c == ':' && !strings.HasPrefix(text[i:], ":="):
+			add(tknColon, ":")
+			i++
+		case c == '-' && sign(i):
+			j := fmtWordEnd(text, i+1)
+			add(tknWord, text[i:j])
+			i = j
+		case strings.IndexByte("=!<>:?+-/%", c) != -1 && !(c == '!' && !strings.HasPrefix(text[i:], "!=")):
+			j := i + 1
+			for j < len(text) && strings.IndexByte("=<>", text[j]) != -1 {
+				j++
+			}
+			add(tknOp, text[i:j])
+			i = j
+		default:
+			j := fmtWordEnd(text, i+1)
+			add(tknWord, text[i:j])
+			i = j
+		}
+	}
+	return r
+}
+
+// Get end of word (path, number, name of function, etc) started before position i.
+//
+// Words may contain namespaces (eg: "testns::check"), indexes (eg: "items[i]") and subsets (eg: "src.{a|b}").
+func fmtWordEnd(text string, i int) int {
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ':' && strings.HasPrefix(text[i:], "::"):
+			i += 2
+		case c == '[':
+			j := indexQB([]byte(text), i)
+			if j == -1 {
+				return len(text)
+			}
+			i = j + 1
+		case c == '{' && text[i-1] == '.':
+			j := strings.IndexByte(text[i:], '}')
+			if j == -1 {
+				return len(text)
+			}
+			i += j + 1
+		case isIdentChar(c) || c == '.' || c == '@' || c == '*' || c == '&' || c == '!':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
@@ -0,0 +1,65 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	files, _ := filepath.Glob("testdata/format/*.dec")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".dec")
+		t.Run(name, func(t *testing.T) {
+			src, _ := os.ReadFile(file)
+			expect, _ := os.ReadFile(strings.Replace(file, ".dec", ".txt", 1))
+			r, err := Format(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(r, expect) {
+				t.Errorf("format mismatch, need:\n%s\ngot:\n%s", expect, r)
+			}
+			// Formatting must be idempotent.
+			r1, _ := Format(r)
+			if !bytes.Equal(r, r1) {
+				t.Errorf("repeated format mismatch:\n%s", r1)
+			}
+		})
+	}
+	t.Run("equivalence", func(t *testing.T) {
+		// Formatted source must be parsed to the same tree.
+		files, _ := filepath.Glob("testdata/parser/*.dec")
+		for _, file := range files {
+			src, _ := os.ReadFile(file)
+			tree, err := Parse(src, ModeLax)
+			if err != nil {
+				t.Fatalf("%s: %s", file, err)
+			}
+			r, err := Format(src)
+			if err != nil {
+				t.Errorf("%s: %s", file, err)
+				continue
+			}
+			tree1, err := Parse(r, ModeLax)
+			if err != nil {
+				t.Errorf("%s: %s", file, err)
+				continue
+			}
+			if !bytes.Equal(tree.HumanReadable(), tree1.HumanReadable()) {
+				t.Errorf("%s: tree mismatch after format:\n%s", file, r)
+			}
+		}
+	})
+	t.Run("unbalanced", func(t *testing.T) {
+		if _, err := Format([]byte("if x == 1 {\n  obj.Id = 1\n")); !errors.Is(err, ErrUnbalancedCtl) {
+			t.Errorf("expected error %s, got %v", ErrUnbalancedCtl, err)
+		}
+		if _, err := Format([]byte("obj.Id = 1\n}\n")); !errors.Is(err, ErrUnexpectedClose) {
+			t.Errorf("expected error %s, got %v", ErrUnexpectedClose, err)
+		}
+	})
+}
@@ -20,6 +20,8 @@ var (
 
 // RegisterGetterFn registers new getter callback to the registry.
 func RegisterGetterFn(name, alias string, cb GetterFn) *GetterFnTuple {
+	regMux.Lock()
+	defer regMux.Unlock()
 	if idx, ok := getterRegistry[alias]; ok && idx >= 0 && idx < len(getterBuf) {
 		return &getterBuf[idx]
 	}
@@ -35,6 +37,7 @@ func RegisterGetterFn(name, alias string, cb GetterFn) *GetterFnTuple {
 	if len(alias) > 0 {
 		getterRegistry[alias] = idx
 	}
+	nextRegGen()
 	return &getterBuf[idx]
 }
 
@@ -49,6 +52,8 @@ func RegisterGetterFnNS(namespace, name, alias string, cb GetterFn) *GetterFnTup
 
 // GetGetterFn returns getter callback function from the registry.
 func GetGetterFn(name string) GetterFn {
+	regMux.RLock()
+	defer regMux.RUnlock()
 	if idx, ok := getterRegistry[name]; ok && idx >= 0 && idx < len(getterBuf) {
 		return getterBuf[idx].fn
 	}
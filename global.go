@@ -15,8 +15,10 @@ var (
 
 // RegisterGlobal registers new global variable.
 //
-// Caution! Globals registered after template parsing will take no effect.
+// Globals registered after parsing binds to already registered decoders on the next decoding (see Rebind()).
 func RegisterGlobal(name, alias string, val Global) *GlobalTuple {
+	regMux.Lock()
+	defer regMux.Unlock()
 	if idx, ok := globIdx[name]; ok && idx >= 0 && idx < len(globBuf) {
 		return &globBuf[idx]
 	}
@@ -29,6 +31,7 @@ func RegisterGlobal(name, alias string, val Global) *GlobalTuple {
 	if len(alias) > 0 {
 		globIdx[alias] = idx
 	}
+	nextRegGen()
 	return &globBuf[idx]
 }
 
@@ -46,6 +49,8 @@ func RegisterGlobalNS(namespace, name, alias string, val Global) *GlobalTuple {
 
 // GetGlobal returns global variable by given name.
 func GetGlobal(name string) Global {
+	regMux.RLock()
+	defer regMux.RUnlock()
 	if idx, ok := globIdx[name]; ok && idx >= 0 && idx < len(globBuf) {
 		return globBuf[idx].val
 	}
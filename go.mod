@@ -6,6 +6,7 @@ require (
 	github.com/koykov/bytealg v1.0.7
 	github.com/koykov/bytebuf v1.1.1
 	github.com/koykov/byteconv v1.0.1
+	github.com/koykov/byteseq v1.0.2
 	github.com/koykov/clock v1.1.6
 	github.com/koykov/inspector v1.4.11
 	github.com/koykov/jsonvector v1.2.6
@@ -18,7 +19,6 @@ require (
 require (
 	github.com/koykov/bitset v1.0.0 // indirect
 	github.com/koykov/byteptr v1.0.0 // indirect
-	github.com/koykov/byteseq v1.0.2 // indirect
 	github.com/koykov/entry v1.0.2 // indirect
 	github.com/koykov/indirect v1.0.1 // indirect
 	github.com/koykov/openrt v0.0.0-20240728195600-ccc3242946f9 // indirect
@@ -0,0 +1,170 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Build tree of decoder according its parent (see extends statement).
+//
+// Returns original tree and error if parent isn't registered yet or inheritance chain contains a loop.
+func (db *db) inheritLF(key string, tree *Tree) (*Tree, error) {
+	if len(tree.parent) == 0 {
+		return tree, nil
+	}
+	// Check the whole chain of parents.
+	for i, pkey := 0, tree.parent; len(pkey) > 0; i++ {
+		if pkey == key || i > len(db.buf) {
+			return tree, fmt.Errorf("%w: '%s'", ErrInheritanceLoop, key)
+		}
+		idx, ok := db.idxKey[pkey]
+		if !ok || idx < 0 || idx >= len(db.buf) {
+			return tree, fmt.Errorf("%w: '%s'", ErrParentNotFound, pkey)
+		}
+		pkey = db.buf[idx].orig.parent
+	}
+	parent := db.buf[db.idxKey[tree.parent]]
+	if parent.err != nil {
+		return tree, parent.err
+	}
+	return mergeTree(parent.tree, tree), nil
+}
+
+// Rebuild all decoders inherited from decoder with given key.
+func (db *db) rebuildLF(key string, depth int) {
+	if depth > len(db.buf) {
+		// Inheritance loop, all decoders in it already marked as failed.
+		return
+	}
+	for i := 0; i < len(db.buf); i++ {
+		dec := db.buf[i]
+		if dec.orig.parent != key {
+			continue
+		}
+		// Decoder objects are immutable since they may be in use, so make a new one.
+		cpy := Decoder{
+			ID:   dec.ID,
+			Key:  dec.Key,
+			orig: dec.orig,
+		}
+		cpy.tree, cpy.err = db.inheritLF(dec.Key, dec.orig)
+		db.buf[i] = &cpy
+		db.rebuildLF(dec.Key, depth+1)
+	}
+}
+
+// Merge rules of child tree to parent's rules.
+//
+// Child's rule replaces parent's rule with the same destination path, all other child's rules appends to the end.
+// Only root rules are matched, rules nested in blocks (conditions, loops, with, ...) aren't compared, thus child's block
+// appends after parent's block even if they are the same.
+//
+// Imports, user-defined functions and constants of both trees are merged as well, child's entries win on conflict.
+// Parent's rules are rebound to the merged functions, thus functions overridden by child apply to them too.
+func mergeTree(parent, child *Tree) *Tree {
+	udfs := mergeUDFs(parent.udfs, child.udfs)
+	pnodes := parent.nodes
+	if len(child.udfs) > 0 {
+		// Parent's rules must call functions overridden by child.
+		pnodes = rebindNodes(pnodes, udfs, nil)
+	}
+	nodes := make(Ruleset, len(pnodes), len(pnodes)+len(child.nodes))
+	copy(nodes, pnodes)
+	var replaced []bool
+	for i := 0; i < len(child.nodes); i++ {
+		c := &child.nodes[i]
+		j := -1
+		if isOverridable(c) {
+			for k := 0; k < len(parent.nodes); k++ {
+				if (replaced == nil || !replaced[k]) && isOverridable(&nodes[k]) && bytes.Equal(nodes[k].dst, c.dst) {
+					j = k
+					break
+				}
+			}
+		}
+		if j == -1 {
+			nodes = append(nodes, *c)
+			continue
+		}
+		nodes[j] = *c
+		if replaced == nil {
+			replaced = make([]bool, len(parent.nodes))
+		}
+		replaced[j] = true
+	}
+	return &Tree{
+		nodes:   nodes,
+		hsum:    child.hsum,
+		parent:  child.parent,
+		imports: mergeImports(parent.imports, child.imports),
+		udfs:    udfs,
+		consts:  mergeConsts(parent.consts, child.consts),
+		gen:     child.gen,
+	}
+}
+
+// Merge imports of parent and child trees without duplicates.
+func mergeImports(parent, child []string) []string {
+	if len(parent) == 0 {
+		return child
+	}
+	r := append([]string(nil), parent...)
+loop:
+	for i := 0; i < len(child); i++ {
+		for j := 0; j < len(parent); j++ {
+			if parent[j] == child[i] {
+				continue loop
+			}
+		}
+		r = append(r, child[i])
+	}
+	return r
+}
+
+// Merge user-defined functions of parent and child trees.
+//
+// Child's function replaces parent's function with the same name, other child's functions appends to the end.
+func mergeUDFs(parent, child []*udf) []*udf {
+	if len(parent) == 0 {
+		return child
+	}
+	r := append([]*udf(nil), parent...)
+	for i := 0; i < len(child); i++ {
+		f := child[i]
+		j := -1
+		for k := 0; k < len(parent); k++ {
+			if bytes.Equal(parent[k].name, f.name) {
+				j = k
+				break
+			}
+		}
+		if j == -1 {
+			r = append(r, f)
+			continue
+		}
+		r[j] = f
+	}
+	return r
+}
+
+// Merge constants of parent and child trees, child's constants have priority.
+func mergeConsts(parent, child map[string][]byte) map[string][]byte {
+	if len(parent) == 0 {
+		return child
+	}
+	r := make(map[string][]byte, len(parent)+len(child))
+	for k, v := range parent {
+		r[k] = v
+	}
+	for k, v := range child {
+		r[k] = v
+	}
+	return r
+}
+
+// Check if node may override (or be overridden by) other node with the same destination.
+//
+// Only plain assignments counts, appending to slices (eg: "dst.Tags[] = src.tag") always adds new values.
+func isOverridable(r *node) bool {
+	return r.typ == typeOperator && len(r.dst) > 0 && !r.push
+}
@@ -11,29 +11,35 @@ func init() {
 		WithParam("arg any", "").
 		WithDescription("Modifier `default` returns the passed `arg` if the preceding value is undefined or empty, otherwise the value of the variable.").
 		WithExample(`obj.Name = jso.person.name|default(jso.person.full_name)
-obj.Status = jso.person.state|default(1)`)
+obj.Status = jso.person.state|default(1)`).
+		WithSignature(Signature{Min: 1, Max: 1})
 	RegisterModFn("ifThen", "if", modIfThen).
 		WithDescription("Modifier `ifThen` passes `arg` only if preceding condition is true.").
 		WithParam("arg any", "").
-		WithExample(`obj.Name = jso.finance.is_active|ifThen("Rich men")`)
+		WithExample(`obj.Name = jso.finance.is_active|ifThen("Rich men")`).
+		WithSignature(Signature{Min: 1, Max: 1})
 	RegisterModFn("ifThenElse", "ifel", modIfThenElse).
 		WithDescription("Modifier `ifTheElse` passes `arg0` if preceding condition is true or `arg1` otherwise.").
 		WithParam("arg0 any", "").
 		WithParam("arg1 any", "").
-		WithExample(`obj.Name = jso.finance.is_active|ifThenElse("Rich men", "Poor men")`)
+		WithExample(`obj.Name = jso.finance.is_active|ifThenElse("Rich men", "Poor men")`).
+		WithSignature(Signature{Min: 2, Max: 2})
 	RegisterModFn("new", "", modNew).
 		WithDescription("Make new instance of given type and return it. This function makes an allocation, use `bufferize` to alloc-free instantiation.").
 		WithParam("name type", "name of type (literal, not string - see example section)").
-		WithExample(`var x = new(TestObject) // typeof(x) == *TestObject`)
+		WithExample(`var x = new(TestObject) // typeof(x) == *TestObject`).
+		WithSignature(Signature{Min: 1, Max: 1, Args: []ArgKind{ArgType}})
 	RegisterModFn("bufferize", "", modBufferize).
 		WithDescription("Return instance of given type. This function bufferizes instantiation, thus is alloc-free. Default `new` function also is available, but it produces an allocation.").
 		WithParam("name type", "name of type (literal, not string - see example section)").
-		WithExample(`var x = bufferize(TestObject) // typeof(x) == *TestObject`)
+		WithExample(`var x = bufferize(TestObject) // typeof(x) == *TestObject`).
+		WithSignature(Signature{Min: 1, Max: 1, Args: []ArgKind{ArgType}})
 	RegisterModFn("append", "", modAppend).
 		WithDescription("Append value to the end of a slice").
 		WithParam("slice array", "name of array (slice) variable or field").
 		WithParam("value type", "value to add to the slice").
-		WithExample(`news.Tags = append(news.Tags, "foobar")`)
+		WithExample(`news.Tags = append(news.Tags, "foobar")`).
+		WithSignature(Signature{Min: 2, Max: 2})
 	RegisterModFnNS("bar", "baz", "", func(_ *Ctx, _ *any, _ any, _ []any) error { return nil }).
 		WithDescription("Testing stuff: don't use in production.")
 
@@ -42,16 +48,19 @@ obj.Status = jso.person.state|default(1)`)
 		WithDescription("Modifier `fmt::format` formats according to a format specifier and returns the resulting string.").
 		WithParam("format string", "").
 		WithParam("args ...any", "").
-		WithExample("obj.StringField = fmt::format(\"Welcome %s\", user.Name)")
+		WithExample("obj.StringField = fmt::format(\"Welcome %s\", user.Name)").
+		WithSignature(Signature{Min: 1, Variadic: true})
 
 	// Register time modifiers.
 	RegisterModFnNS("time", "now", "", modNow).
-		WithDescription("Returns the current local time.")
+		WithDescription("Returns the current local time.").
+		WithSignature(Signature{Max: 1})
 	RegisterModFnNS("time", "format", "date", modDate).
 		WithParam("layout string", "See https://github.com/koykov/clock#format for possible patterns").
 		WithDescription("Modifier `time::format` returns a textual representation of the time value formatted according given layout.").
 		WithExample(`lvalue = date|time::date("%d %b %y %H:%M %z") // 05 Feb 09 07:00 +0200
-lvalue = date|time::date("%b %e %H:%M:%S.%N") // Feb  5 07:00:57.012345600`)
+lvalue = date|time::date("%b %e %H:%M:%S.%N") // Feb  5 07:00:57.012345600`).
+		WithSignature(Signature{Max: 2})
 	RegisterModFnNS("time", "add", "date_modify", modDateAdd).
 		WithParam("duration string", "Textual representation of duration you want to add to the datetime. Possible units:\n"+
 			"  * `nsec`, `ns`\n"+
@@ -71,38 +80,53 @@ lvalue = date|time::date("%b %e %H:%M:%S.%N") // Feb  5 07:00:57.012345600`)
 lvalue = date|time::add("+1 min")|time::date(time::StampNano)		// Jan 21 20:05:26.000000555
 lvalue = date|time::add("+1 minute")|time::date(time::StampNano)	// Jan 21 20:05:26.000000555
 lvalue = date|time::add("+1 minutes")|time::date(time::StampNano)	// Jan 21 20:05:26.000000555
-`)
+`).
+		WithSignature(Signature{Min: 1, Max: 1})
 
 	// Register builtin getter callbacks.
 	RegisterGetterFn("crc32", "", getterCrc32).
 		WithParam("args ...any", "Arguments to concatenate.").
-		WithDescription("Concatenate `args` and calculate crc32 IEEE checksum of result.")
+		WithDescription("Concatenate `args` and calculate crc32 IEEE checksum of result.").
+		WithSignature(Signature{Min: 1, Variadic: true})
 	RegisterGetterFn("strToInt", "atoi", getterAtoi).
 		WithParam("arg string", "Argument to convert.").
-		WithDescription("Convert `arg` to `int` value if possible.")
+		WithDescription("Convert `arg` to `int` value if possible.").
+		WithSignature(Signature{Min: 1, Max: 1})
 	RegisterGetterFn("strToUint", "atou", getterAtou).
 		WithParam("arg string", "Argument to convert.").
-		WithDescription("Convert `arg` to `unsigned int` value if possible.")
+		WithDescription("Convert `arg` to `unsigned int` value if possible.").
+		WithSignature(Signature{Min: 1, Max: 1})
 	RegisterGetterFn("strToFloat", "atof", getterAtof).
 		WithParam("arg string", "Argument to convert.").
-		WithDescription("Convert `arg` to `float` value if possible.")
+		WithDescription("Convert `arg` to `float` value if possible.").
+		WithSignature(Signature{Min: 1, Max: 1})
 	RegisterGetterFn("strToBool", "atob", getterAtob).
 		WithParam("arg string", "Argument to convert.").
-		WithDescription("Convert `arg` to `bool` value if possible.")
+		WithDescription("Convert `arg` to `bool` value if possible.").
+		WithSignature(Signature{Min: 1, Max: 1})
 	RegisterGetterFn("intToStr", "itoa", getterItoa).
 		WithParam("arg int", "Argument to convert.").
-		WithDescription("Convert `arg` to string value.")
+		WithDescription("Convert `arg` to string value.").
+		WithSignature(Signature{Min: 1, Max: 1})
 	RegisterGetterFn("uintToStr", "utoa", getterUtoa).
 		WithParam("arg int", "Argument to convert.").
-		WithDescription("Convert `arg` to string value.")
+		WithDescription("Convert `arg` to string value.").
+		WithSignature(Signature{Min: 1, Max: 1})
 	RegisterGetterFn("appendTestHistory", "", getterAppendTestHistory).
 		WithDescription("Testing stuff: don't use in production.")
 
 	// Register builtin callbacks.
-	RegisterCallbackFn("reset", "", cbReset).
-		WithDescription("Reset variable of field.").
+	RegisterCallbackFn("reset", "clear", cbReset).
+		WithDescription("Reset variable of field. Maps are cleared (all keys are removed).").
 		WithParam("arg path", "Path to variable/field to reset.").
-		WithExample("reset(user.Related[0].Age)")
+		WithExample("reset(user.Related[0].Age)").
+		WithSignature(Signature{Min: 1, Max: 1})
+	RegisterCallbackFn("delete", "", cbDelete).
+		WithDescription("Remove key from map.").
+		WithParam("arg path", "Path to map.").
+		WithParam("key any", "Key to remove.").
+		WithExample(`delete(user.Headers, "x-internal")`).
+		WithSignature(Signature{Min: 2, Max: 2})
 	RegisterCallbackFnNS("fmt", "print", "", cbPrint).
 		WithParam("args ...any", "Arguments to print.").
 		WithDescription("Print args to console.")
@@ -0,0 +1,137 @@
+// Package source splits decoder source to statements with their positions.
+//
+// Statements are split the same way as decoder parser does: every line may contain several statements separated by
+// semicolons, opening curly brackets and closing curly brackets. It's a base for tools that need to work with source
+// itself rather than with parsed tree (linter, formatter, ...).
+package source
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Kind of the statement.
+type Kind int
+
+const (
+	// Rule is a regular statement: assignment, callback, control statement, etc.
+	Rule Kind = iota
+	// Open is a header of block, eg: "if x == 1 {" or "for i := range list {".
+	Open
+	// Close is a closing bracket of block.
+	Close
+	// Else divides branches of condition: "} else {".
+	Else
+	// Case is a switch case or default label, eg: "case 1:".
+	Case
+	// Comment is a line comment ("// ..." or "# ...").
+	Comment
+	// DirIf is a preprocessor directive "#if flag(...)".
+	DirIf
+	// DirElse is a preprocessor directive "#else".
+	DirElse
+	// DirEndif is a preprocessor directive "#endif".
+	DirEndif
+	// Blank is an empty line.
+	Blank
+)
+
+// Stmt describes one statement of the source.
+type Stmt struct {
+	Kind Kind
+	// Text of the statement without surrounding spaces.
+	Text string
+	// Line and column of the statement beginning, starting from 1.
+	Line, Col int
+}
+
+var (
+	reElse = regexp.MustCompile(`^}\s*else\s*{$`)
+	reDir  = regexp.MustCompile(`^#(if|else|endif)\b`)
+	reCase = regexp.MustCompile(`^(case\s.*|default\s*):$`)
+)
+
+// Split splits source to statements.
+func Split(src []byte) []Stmt {
+	var r []Stmt
+	lines := bytes.Split(src, []byte("\n"))
+	if n := len(lines); n > 0 && len(bytes.TrimSpace(lines[n-1])) == 0 {
+		// Trailing newline doesn't produce blank line.
+		lines = lines[:n-1]
+	}
+	for i, line := range lines {
+		line = bytes.TrimRight(line, "\r")
+		ln := i + 1
+		if len(bytes.TrimSpace(line)) == 0 {
+			r = append(r, Stmt{Kind: Blank, Line: ln, Col: 1})
+			continue
+		}
+		var o int
+		for o < len(line) {
+			// Skip formatting, see parser.skipFmt().
+			for o < len(line) && (line[o] == ' ' || line[o] == '\t' || line[o] == ';') {
+				o++
+			}
+			if o == len(line) {
+				break
+			}
+			rest := line[o:]
+			st := Stmt{Line: ln, Col: o + 1}
+			var n int
+			switch {
+			case bytes.HasPrefix(rest, []byte("//")):
+				st.Kind, n = Comment, len(rest)
+			case rest[0] == '#':
+				st.Kind, n = Comment, len(rest)
+				if m := reDir.FindSubmatch(rest); m != nil {
+					switch string(m[1]) {
+					case "if":
+						st.Kind = DirIf
+					case "else":
+						st.Kind = DirElse
+					default:
+						st.Kind = DirEndif
+					}
+				}
+			case rest[0] == '}' && !reElse.Match(bytes.TrimSpace(rest[:next(rest)])):
+				st.Kind, n = Close, 1
+			default:
+				n = next(rest)
+			}
+			st.Text = string(bytes.TrimSpace(rest[:n]))
+			if st.Kind == Rule {
+				st.Kind = kindOf(st.Text)
+			}
+			r = append(r, st)
+			o += n
+		}
+	}
+	return r
+}
+
+// Get length of the next statement in the rest of line, see parser.nextCtl().
+func next(rest []byte) int {
+	if j := bytes.IndexByte(rest, '{'); j > 0 && rest[j-1] != '.' {
+		return j + 1
+	}
+	if j := bytes.IndexByte(rest, ';'); j > 0 && !bytes.HasPrefix(rest, []byte("for")) {
+		return j
+	}
+	if j := bytes.IndexByte(rest, '}'); j > 0 && bytes.Index(rest, []byte(".{")) == -1 {
+		return j
+	}
+	return len(rest)
+}
+
+func kindOf(text string) Kind {
+	switch {
+	case reElse.MatchString(text):
+		return Else
+	case len(text) > 0 && text[len(text)-1] == '{':
+		return Open
+	case reCase.MatchString(text):
+		return Case
+	default:
+		return Rule
+	}
+}
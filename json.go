@@ -0,0 +1,377 @@
+package decoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONVersion is a version of JSON schema of the tree.
+//
+// Schema is a versioned AST (see AST type): {"version": 1, "extends": ..., "imports": [...], "consts": {...},
+// "funcs": [...], "stmts": [...]}. Each statement is an object with mandatory "kind" field (see StmtKind.String for
+// possible values) and optional fields named as lowerCamelCase fields of Stmt. Empty fields are omitted. Kinds of
+// arguments are strings as well (see ArgKind.String).
+//
+// Version increments on incompatible changes of the schema, new optional fields don't change it.
+const JSONVersion = 1
+
+type treeJSON struct {
+	Version int `json:"version"`
+	*AST
+}
+
+// MarshalJSON encodes the tree to JSON using versioned AST schema (see JSONVersion).
+func (t *Tree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(treeJSON{Version: JSONVersion, AST: t.AST()})
+}
+
+// UnmarshalJSON builds the tree from JSON made by MarshalJSON.
+//
+// Tree is rendering to the source and parsing in strict mode, so all functions must be registered. Use ParseJSON to
+// specify parse options.
+func (t *Tree) UnmarshalJSON(data []byte) error {
+	tree, err := ParseJSON(data)
+	if err != nil {
+		return err
+	}
+	*t = *tree
+	return nil
+}
+
+// ParseJSON builds the tree from JSON made by Tree.MarshalJSON.
+//
+// Only raw values are required: path tokens, dynamic and global flags and positions are ignored and calculates
+// again during parsing. Raw values are validated before, so JSON can't inject extra statements to the tree.
+func ParseJSON(data []byte, opts ...ParseOption) (*Tree, error) {
+	var x treeJSON
+	if err := json.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+	if x.Version != JSONVersion {
+		return nil, fmt.Errorf("%w: %d", ErrVersion, x.Version)
+	}
+	if x.AST == nil {
+		x.AST = &AST{}
+	}
+	if err := x.AST.validate(); err != nil {
+		return nil, err
+	}
+	return Parse(x.AST.source(), opts...)
+}
+
+// Check raw values of AST before rendering to the source.
+func (a *AST) validate() error {
+	if len(a.Extends) > 0 && !validKey(a.Extends) {
+		return fmt.Errorf("%w: parent key '%s'", ErrMalformedValue, a.Extends)
+	}
+	for _, lib := range a.Imports {
+		if !validKey(lib) {
+			return fmt.Errorf("%w: library key '%s'", ErrMalformedValue, lib)
+		}
+	}
+	for k, v := range a.Consts {
+		if !validName(k) || !isStaticRE.MatchString(v) || !validLit(v) {
+			return fmt.Errorf("%w: constant '%s' = '%s'", ErrMalformedValue, k, v)
+		}
+	}
+	for i := 0; i < len(a.Funcs); i++ {
+		f := &a.Funcs[i]
+		if !validName(f.Name) {
+			return fmt.Errorf("%w: function name '%s'", ErrMalformedValue, f.Name)
+		}
+		for _, param := range f.Params {
+			if !validName(param) {
+				return fmt.Errorf("%w: parameter '%s' of function '%s'", ErrMalformedValue, param, f.Name)
+			}
+		}
+		if err := validateStmts(f.Body); err != nil {
+			return err
+		}
+	}
+	return validateStmts(a.Stmts)
+}
+
+func validateStmts(list []Stmt) error {
+	for i := 0; i < len(list); i++ {
+		if err := validateStmt(&list[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateStmt(s *Stmt) error {
+	if len(s.Dst.Raw) > 0 && !validPath(s.Dst.Raw) {
+		return fmt.Errorf("%w: '%s'", ErrMalformedPath, s.Dst.Raw)
+	}
+	if err := validateExpr(&s.Src); err != nil {
+		return err
+	}
+	if s.Call != nil {
+		if err := validateCall(s.Call); err != nil {
+			return err
+		}
+	}
+	if c := s.Cond; c != nil {
+		if err := validateArg(&c.Left); err != nil {
+			return err
+		}
+		if err := validateArg(&c.Right); err != nil {
+			return err
+		}
+		if len(c.Op) > 0 {
+			if op_ := (&parser{}).parseOp([]byte(c.Op)); op_ < opEq || op_ > opLtq {
+				return fmt.Errorf("%w: unknown operator '%s'", ErrMalformedValue, c.Op)
+			}
+		}
+		if c.Helper != nil {
+			if err := validateCall(c.Helper); err != nil {
+				return err
+			}
+		}
+		if !validOptName(c.Var) || !validOptName(c.VarOK) || (len(c.Type) > 0 && !validPath(c.Type)) {
+			return fmt.Errorf("%w: condition variables '%s, %s' as '%s'", ErrMalformedValue, c.Var, c.VarOK, c.Type)
+		}
+	}
+	if l := s.Loop; l != nil {
+		if !validOptName(l.Label) || !validOptName(l.Key) || !validOptName(l.Val) || !validOptName(l.Counter) ||
+			(len(l.Init) > 0 && !validPath(l.Init)) || (len(l.Limit) > 0 && !validPath(l.Limit)) {
+			return fmt.Errorf("%w: loop properties %+v", ErrMalformedValue, *l)
+		}
+		if op_ := (&parser{}).parseOp([]byte(l.CondOp)); len(l.CondOp) > 0 && (op_ < opEq || op_ > opLtq) {
+			return fmt.Errorf("%w: unknown loop operator '%s'", ErrMalformedValue, l.CondOp)
+		}
+		if op_ := (&parser{}).parseOp([]byte(l.CountOp)); len(l.CountOp) > 0 && op_ != opInc && op_ != opDec {
+			return fmt.Errorf("%w: unknown loop operator '%s'", ErrMalformedValue, l.CountOp)
+		}
+	}
+	if !validOptName(s.Label) || !validOptName(s.Var) {
+		return fmt.Errorf("%w: name '%s%s'", ErrMalformedValue, s.Label, s.Var)
+	}
+	if (len(s.Decoder) > 0 && !validKey(s.Decoder)) || (len(s.Flag) > 0 && !validKey(s.Flag)) {
+		return fmt.Errorf("%w: key '%s%s'", ErrMalformedValue, s.Decoder, s.Flag)
+	}
+	if am := s.Automap; am != nil {
+		for _, f := range am.Except {
+			if !validPath(f) {
+				return fmt.Errorf("%w: automap field '%s'", ErrMalformedValue, f)
+			}
+		}
+		for _, r := range am.Rename {
+			if !validPath(r[0]) || !validPath(r[1]) {
+				return fmt.Errorf("%w: automap rename '%s: %s'", ErrMalformedValue, r[0], r[1])
+			}
+		}
+		if !validOptName(am.Strategy) {
+			return fmt.Errorf("%w: automap strategy '%s'", ErrMalformedValue, am.Strategy)
+		}
+	}
+	if err := validateStmts(s.Then); err != nil {
+		return err
+	}
+	if err := validateStmts(s.Else); err != nil {
+		return err
+	}
+	return validateStmts(s.Body)
+}
+
+func validateExpr(e *Expr) error {
+	// Branches of ternary operator keep static values as paths.
+	if p := e.Path.Raw; len(p) > 0 && !validPath(p) && !(isStaticRE.MatchString(p) && validLit(p)) {
+		return fmt.Errorf("%w: '%s'", ErrMalformedPath, e.Path.Raw)
+	}
+	if e.Static && !validLit(e.Value) {
+		return fmt.Errorf("%w: static value '%s'", ErrMalformedValue, e.Value)
+	}
+	if err := validateSubset(e.Subset); err != nil {
+		return err
+	}
+	for i := 0; i < len(e.Mods); i++ {
+		if err := validateCall(&e.Mods[i]); err != nil {
+			return err
+		}
+	}
+	if len(e.Type) > 0 && !validPath(e.Type) {
+		return fmt.Errorf("%w: type '%s'", ErrMalformedValue, e.Type)
+	}
+	return nil
+}
+
+func validateCall(c *Call) error {
+	if !validPath(c.Name) {
+		return fmt.Errorf("%w: function name '%s'", ErrMalformedValue, c.Name)
+	}
+	for i := 0; i < len(c.Args); i++ {
+		if err := validateArg(&c.Args[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateArg(a *Arg) error {
+	switch {
+	case a.Static && !validLit(a.Value):
+		return fmt.Errorf("%w: static value '%s'", ErrMalformedValue, a.Value)
+	case !a.Static && len(a.Value) > 0 && !validPath(a.Value):
+		return fmt.Errorf("%w: '%s'", ErrMalformedPath, a.Value)
+	}
+	return validateSubset(a.Subset)
+}
+
+func validateSubset(keys []string) error {
+	for _, k := range keys {
+		if !validPath(k) {
+			return fmt.Errorf("%w: subset key '%s'", ErrMalformedValue, k)
+		}
+	}
+	return nil
+}
+
+// Check if s is empty or may be used as a name.
+func validOptName(s string) bool {
+	return len(s) == 0 || validName(s)
+}
+
+// Check if s may be written in double quotes, eg: key of decoder or flag.
+func validKey(s string) bool {
+	return len(s) > 0 && !strings.ContainsAny(s, "\"\r\n;{}")
+}
+
+// Check if static value may be written to the source as a single value.
+//
+// Parser splits rules by line breaks and semicolons and looks for blocks by curly brackets even inside of quotes,
+// and value with quotes of all kinds can't be quoted at all.
+func validLit(s string) bool {
+	return !strings.ContainsAny(s, "\r\n;{}") &&
+		!(strings.IndexByte(s, '"') != -1 && strings.IndexByte(s, '\'') != -1 && strings.IndexByte(s, '`') != -1)
+}
+
+// Statement with optional destination and source, see Stmt.MarshalJSON.
+type stmtJSON struct {
+	Kind StmtKind `json:"kind"`
+	Dst  *VarPath `json:"dst,omitempty"`
+	Src  *Expr    `json:"src,omitempty"`
+	*stmtAlias
+}
+
+type stmtAlias Stmt
+
+// MarshalJSON writes kind first and omits empty destination and source.
+func (s Stmt) MarshalJSON() ([]byte, error) {
+	x := stmtJSON{Kind: s.Kind, stmtAlias: (*stmtAlias)(&s)}
+	if len(s.Dst.Raw) > 0 {
+		x.Dst = &s.Dst
+	}
+	if s.Src.Static || len(s.Src.Path.Raw) > 0 || len(s.Src.Mods) > 0 || len(s.Src.Type) > 0 {
+		x.Src = &s.Src
+	}
+	return json.Marshal(x)
+}
+
+func (s *Stmt) UnmarshalJSON(data []byte) error {
+	x := stmtJSON{stmtAlias: (*stmtAlias)(s)}
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	s.Kind = x.Kind
+	if x.Dst != nil {
+		s.Dst = *x.Dst
+	}
+	if x.Src != nil {
+		s.Src = *x.Src
+	}
+	return nil
+}
+
+// Expression with optional path, see Expr.MarshalJSON.
+type exprJSON struct {
+	Path *VarPath `json:"path,omitempty"`
+	*exprAlias
+}
+
+type exprAlias Expr
+
+// MarshalJSON omits empty path.
+func (e Expr) MarshalJSON() ([]byte, error) {
+	x := exprJSON{exprAlias: (*exprAlias)(&e)}
+	if len(e.Path.Raw) > 0 {
+		x.Path = &e.Path
+	}
+	return json.Marshal(x)
+}
+
+func (e *Expr) UnmarshalJSON(data []byte) error {
+	x := exprJSON{exprAlias: (*exprAlias)(e)}
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	if x.Path != nil {
+		e.Path = *x.Path
+	}
+	return nil
+}
+
+// Condition with optional sides, see Cond.MarshalJSON.
+type condJSON struct {
+	Left  *Arg `json:"left,omitempty"`
+	Right *Arg `json:"right,omitempty"`
+	*condAlias
+}
+
+type condAlias Cond
+
+// MarshalJSON omits empty sides of comparison, eg: in conditions with helpers.
+func (c Cond) MarshalJSON() ([]byte, error) {
+	x := condJSON{condAlias: (*condAlias)(&c)}
+	if c.Left.Static || len(c.Left.Value) > 0 {
+		x.Left = &c.Left
+	}
+	if c.Right.Static || len(c.Right.Value) > 0 {
+		x.Right = &c.Right
+	}
+	return json.Marshal(x)
+}
+
+func (c *Cond) UnmarshalJSON(data []byte) error {
+	x := condJSON{condAlias: (*condAlias)(c)}
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	if x.Left != nil {
+		c.Left = *x.Left
+	}
+	if x.Right != nil {
+		c.Right = *x.Right
+	}
+	return nil
+}
+
+func (k StmtKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *StmtKind) UnmarshalText(p []byte) error {
+	for i := StmtAssign; i <= StmtFlag; i++ {
+		if i.String() == string(p) {
+			*k = i
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown statement kind '%s'", ErrMalformedValue, p)
+}
+
+func (k ArgKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ArgKind) UnmarshalText(p []byte) error {
+	for i := ArgAny; i <= ArgType; i++ {
+		if i.String() == string(p) {
+			*k = i
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown argument kind '%s'", ErrMalformedValue, p)
+}
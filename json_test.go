@@ -0,0 +1,99 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	files, _ := filepath.Glob("testdata/marshal/*.dec")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".dec")
+		t.Run(name, func(t *testing.T) {
+			src, _ := os.ReadFile(file)
+			expect, _ := os.ReadFile(strings.Replace(file, ".dec", ".json", 1))
+			tree, err := Parse(src, ModeLax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := json.Marshal(tree)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			_ = json.Indent(&buf, r, "", "  ")
+			buf.WriteByte('\n')
+			if !bytes.Equal(buf.Bytes(), expect) {
+				t.Errorf("json mismatch, need:\n%s\ngot:\n%s", expect, buf.Bytes())
+			}
+			tree1, err := ParseJSON(expect, ModeLax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tree.HumanReadable(), tree1.HumanReadable()) {
+				t.Errorf("tree mismatch, need:\n%s\ngot:\n%s", tree.HumanReadable(), tree1.HumanReadable())
+			}
+		})
+	}
+	t.Run("roundtrip", func(t *testing.T) {
+		var files []string
+		for _, dir := range []string{"parser", "decoder", "mod", "getter", "fmt", "datetime"} {
+			list, _ := filepath.Glob("testdata/" + dir + "/*.dec")
+			files = append(files, list...)
+		}
+		for _, file := range files {
+			src, _ := os.ReadFile(file)
+			tree, err := Parse(src, ModeLax)
+			if err != nil {
+				t.Fatalf("%s: %s", file, err)
+			}
+			r, err := tree.MarshalJSON()
+			if err != nil {
+				t.Errorf("%s: %s", file, err)
+				continue
+			}
+			tree1, err := ParseJSON(r, ModeLax)
+			if err != nil {
+				t.Errorf("%s: %s\n%s", file, err, r)
+				continue
+			}
+			if !bytes.Equal(tree.HumanReadable(), tree1.HumanReadable()) {
+				t.Errorf("%s: tree mismatch, json:\n%s\nneed:\n%s\ngot:\n%s", file, r, tree.HumanReadable(), tree1.HumanReadable())
+			}
+		}
+	})
+	t.Run("version", func(t *testing.T) {
+		var tree Tree
+		if err := json.Unmarshal([]byte(`{"version":100500}`), &tree); !errors.Is(err, ErrVersion) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("kind", func(t *testing.T) {
+		var tree Tree
+		err := json.Unmarshal([]byte(`{"version":1,"stmts":[{"kind":"goto"}]}`), &tree)
+		if !errors.Is(err, ErrMalformedValue) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("injection", func(t *testing.T) {
+		// Raw values must not be rendered to extra statements.
+		for _, src := range []string{
+			`{"version":1,"stmts":[{"kind":"assign","dst":{"raw":"obj.Cost"},"src":{"static":true,"value":"1\"\nobj.Name = jso.secret\nobj.Cost = \"2"}}]}`,
+			`{"version":1,"stmts":[{"kind":"assign","dst":{"raw":"obj.Cost"},"src":{"static":true,"value":"1'\"; obj.Name = jso.secret; obj.Cost = ` + "`" + `2"}}]}`,
+			`{"version":1,"stmts":[{"kind":"assign","dst":{"raw":"obj.Name = jso.secret\nobj.Cost"},"src":{"static":true,"value":"1"}}]}`,
+			`{"version":1,"stmts":[{"kind":"assign","dst":{"raw":"obj.Cost"},"src":{"path":{"raw":"jso.cost\nobj.Name = jso.secret"}}}]}`,
+			`{"version":1,"stmts":[{"kind":"use","decoder":"x\"\nobj.Name = jso.secret\nuse \"y"}]}`,
+			`{"version":1,"consts":{"X":"1\nobj.Name = jso.secret"}}`,
+		} {
+			if _, err := ParseJSON([]byte(src), ModeLax); !errors.Is(err, ErrMalformedValue) &&
+				!errors.Is(err, ErrMalformedPath) {
+				t.Errorf("unexpected error: %v\n%s", err, src)
+			}
+		}
+	})
+}
@@ -0,0 +1,365 @@
+package decoder
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/koykov/bytebuf"
+)
+
+type LineageFormat string
+
+const (
+	LineageFormatCSV  LineageFormat = "csv"
+	LineageFormatJSON LineageFormat = "json"
+)
+
+// LineageEntry describes everything that can contribute to one destination path.
+type LineageEntry struct {
+	// Destination path. Dynamic indexes are replaced with "[]", eg: "obj.Flags[]".
+	Dst string `json:"dst"`
+	// Source paths, including arguments of modifiers and getters and dynamic indexes of paths.
+	Sources []string `json:"sources,omitempty"`
+	// Modifiers applied to the sources.
+	Mods []string `json:"mods,omitempty"`
+	// Getters and condition-OK helpers the value gets from.
+	Getters []string `json:"getters,omitempty"`
+	// Conditions required to assign the value, conditions of else branches are wrapped to "!(...)".
+	Conds []string `json:"conds,omitempty"`
+	// Sub-decoders filling the destination.
+	Decoders []string `json:"decoders,omitempty"`
+}
+
+// LineageReport is a list of destinations in order of the first assignment.
+type LineageReport []LineageEntry
+
+// Lineage builds data lineage report of the tree: which source paths feed which destination paths.
+//
+// Local variables (loop, with and condition-OK variables) are replaced with paths they refer to, eg: "v.name" inside
+// of "for _, v := range src.items" becomes "src.items[].name". Callbacks, sub-decoders included by use statement and
+// bodies of user-defined functions aren't followed, modifiers made by functions appear in the report by names.
+func Lineage(tree *Tree) LineageReport {
+	l := lineage{idx: make(map[string]int)}
+	l.stmts(tree.AST().Stmts)
+	return l.report
+}
+
+// Write writes report to w in given format.
+//
+// CSV has a header and a row per destination, lists are joined with "; ".
+func (r LineageReport) Write(w io.Writer, format LineageFormat) error {
+	switch format {
+	case LineageFormatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"dst", "sources", "mods", "getters", "conds", "decoders"})
+		for i := 0; i < len(r); i++ {
+			e := &r[i]
+			_ = cw.Write([]string{e.Dst, strings.Join(e.Sources, "; "), strings.Join(e.Mods, "; "),
+				strings.Join(e.Getters, "; "), strings.Join(e.Conds, "; "), strings.Join(e.Decoders, "; ")})
+		}
+		cw.Flush()
+		return cw.Error()
+	case LineageFormatJSON:
+		if r == nil {
+			// Write empty list instead of null.
+			r = LineageReport{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(r)
+	}
+	return fmt.Errorf("unknown format: %s", format)
+}
+
+// Lineage builder.
+type lineage struct {
+	report LineageReport
+	// Index of destinations in the report.
+	idx map[string]int
+	// Stack of local variables, inner variables go last.
+	vars []lineageVar
+	// Stack of conditions around current statement.
+	conds []string
+}
+
+// Local variable.
+type lineageVar struct {
+	name string
+	// Paths the variable refers to. Nil for variables that doesn't depend on sources (eg: counter of loop).
+	paths []string
+	// Variable refers to values of paths, thus sub-paths of the variable are sub-paths of them (loop and with variables).
+	// Otherwise, variable is a result of helper call.
+	exact bool
+	// Helper made the variable.
+	getter string
+}
+
+func (l *lineage) stmts(list []Stmt) {
+	for i := 0; i < len(list); i++ {
+		l.stmt(&list[i])
+	}
+}
+
+func (l *lineage) stmt(s *Stmt) {
+	switch s.Kind {
+	case StmtAssign:
+		e := l.entry(s.Dst.Raw, s.Push)
+		if s.Call != nil {
+			l.getter(e, s.Call)
+		} else {
+			l.expr(e, &s.Src)
+		}
+	case StmtIf:
+		var buf bytebuf.Chain
+		srcHead(&buf, s)
+		cond := strings.TrimPrefix(buf.StringCopy(), "if ")
+		var n int
+		if c := s.Cond; c != nil && len(c.Var) > 0 {
+			v := lineageVar{name: c.Var}
+			if c.Helper != nil {
+				v.getter = c.Helper.Name
+				v.paths = l.args(c.Helper.Args)
+			}
+			l.vars = append(l.vars, v)
+			n++
+		}
+		l.branches(cond, s.Then, s.Else)
+		l.vars = l.vars[:len(l.vars)-n]
+	case StmtFlag:
+		l.branches(`flag("`+s.Flag+`")`, s.Then, s.Else)
+	case StmtSwitch:
+		var buf bytebuf.Chain
+		srcHead(&buf, s)
+		head := buf.StringCopy()
+		for i := 0; i < len(s.Body); i++ {
+			c := &s.Body[i]
+			buf.Reset()
+			srcHead(&buf, c)
+			l.conds = append(l.conds, head+": "+buf.StringCopy())
+			l.stmts(c.Body)
+			l.conds = l.conds[:len(l.conds)-1]
+		}
+	case StmtLoopRange, StmtLoopCount:
+		var n int
+		if lp := s.Loop; lp != nil {
+			if s.Kind == StmtLoopRange {
+				paths := l.resolve(s.Src.Path.Raw)
+				for i := 0; i < len(paths); i++ {
+					paths[i] += "[]"
+				}
+				for _, name := range []string{lp.Key, lp.Val} {
+					if len(name) > 0 && name != "_" {
+						l.vars = append(l.vars, lineageVar{name: name, paths: paths, exact: true})
+						n++
+					}
+				}
+			} else if len(lp.Counter) > 0 {
+				l.vars = append(l.vars, lineageVar{name: lp.Counter})
+				n++
+			}
+		}
+		l.stmts(s.Body)
+		l.vars = l.vars[:len(l.vars)-n]
+	case StmtWith:
+		dst, _ := lineagePath(s.Dst.Raw)
+		l.vars = append(l.vars, lineageVar{name: s.Var, paths: l.resolveDst(dst + "[]"), exact: true})
+		l.stmts(s.Body)
+		l.vars = l.vars[:len(l.vars)-1]
+	case StmtAutomap:
+		e := l.entry(s.Dst.Raw, false)
+		e.Sources = lineageAdd(e.Sources, l.resolve(s.Src.Path.Raw)...)
+		e.Mods = lineageAdd(e.Mods, "automap")
+	case StmtDecode:
+		e := l.entry(s.Dst.Raw, false)
+		e.Sources = lineageAdd(e.Sources, l.resolve(s.Src.Path.Raw)...)
+		e.Decoders = lineageAdd(e.Decoders, s.Decoder)
+	}
+}
+
+// Walk through branches of condition.
+func (l *lineage) branches(cond string, then, els []Stmt) {
+	l.conds = append(l.conds, cond)
+	l.stmts(then)
+	l.conds[len(l.conds)-1] = "!(" + cond + ")"
+	l.stmts(els)
+	l.conds = l.conds[:len(l.conds)-1]
+}
+
+// Get entry of the destination and add current conditions to it.
+func (l *lineage) entry(raw string, push bool) *LineageEntry {
+	dst, idx := lineagePath(raw)
+	if push {
+		dst += "[]"
+	}
+	if dsts := l.resolveDst(dst); len(dsts) > 0 {
+		dst = dsts[0]
+	}
+	i, ok := l.idx[dst]
+	if !ok {
+		l.report = append(l.report, LineageEntry{Dst: dst})
+		i = len(l.report) - 1
+		l.idx[dst] = i
+	}
+	e := &l.report[i]
+	for _, x := range idx {
+		l.source(e, x)
+	}
+	e.Conds = lineageAdd(e.Conds, l.conds...)
+	return e
+}
+
+// Add value expression to the entry.
+func (l *lineage) expr(e *LineageEntry, x *Expr) {
+	if x.Static {
+		return
+	}
+	if len(x.Path.Raw) > 0 {
+		if len(x.Subset) > 0 {
+			for _, key := range x.Subset {
+				l.source(e, x.Path.Raw+"."+key)
+			}
+		} else {
+			l.source(e, x.Path.Raw)
+		}
+	}
+	for i := 0; i < len(x.Mods); i++ {
+		m := &x.Mods[i]
+		e.Mods = lineageAdd(e.Mods, m.Name)
+		e.Sources = lineageAdd(e.Sources, l.args(m.Args)...)
+	}
+}
+
+func (l *lineage) getter(e *LineageEntry, c *Call) {
+	e.Getters = lineageAdd(e.Getters, c.Name)
+	e.Sources = lineageAdd(e.Sources, l.args(c.Args)...)
+}
+
+// Add source path to the entry, with paths of dynamic indexes.
+func (l *lineage) source(e *LineageEntry, raw string) {
+	path, idx := lineagePath(raw)
+	for _, x := range idx {
+		l.source(e, x)
+	}
+	if v := l.lookup(path); v != nil && len(v.getter) > 0 {
+		e.Getters = lineageAdd(e.Getters, v.getter)
+	}
+	e.Sources = lineageAdd(e.Sources, l.resolve(path)...)
+}
+
+// Get resolved paths of non-static arguments.
+func (l *lineage) args(args []Arg) (r []string) {
+	for i := 0; i < len(args); i++ {
+		a := &args[i]
+		if a.Static {
+			continue
+		}
+		if len(a.Subset) > 0 {
+			for _, key := range a.Subset {
+				r = lineageAdd(r, l.resolve(a.Value+"."+key)...)
+			}
+			continue
+		}
+		r = lineageAdd(r, l.resolve(a.Value)...)
+	}
+	return
+}
+
+// Replace local variable in the path with paths it refers to.
+func (l *lineage) resolve(path string) []string {
+	path, _ = lineagePath(path)
+	v := l.lookup(path)
+	if v == nil {
+		if len(path) == 0 {
+			return nil
+		}
+		return []string{path}
+	}
+	if !v.exact {
+		return append([]string(nil), v.paths...)
+	}
+	rest := path[len(v.name):]
+	r := make([]string, 0, len(v.paths))
+	for _, p := range v.paths {
+		r = append(r, p+rest)
+	}
+	return r
+}
+
+// Resolve destination path, it may refer to with variable only.
+func (l *lineage) resolveDst(path string) []string {
+	if v := l.lookup(path); v != nil && v.exact {
+		return l.resolve(path)
+	}
+	return []string{path}
+}
+
+// Find local variable used as root of the path.
+func (l *lineage) lookup(path string) *lineageVar {
+	root := path
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		root = path[:i]
+	}
+	for i := len(l.vars) - 1; i >= 0; i-- {
+		if l.vars[i].name == root {
+			return &l.vars[i]
+		}
+	}
+	return nil
+}
+
+// Replace dynamic indexes of the path with "[]" and return them separately, eg: "obj.Flags[h.key]" ->
+// ("obj.Flags[]", ["h.key"]). Static indexes keep as is.
+func lineagePath(raw string) (string, []string) {
+	if strings.IndexByte(raw, '[') == -1 {
+		return raw, nil
+	}
+	var (
+		buf strings.Builder
+		idx []string
+	)
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '[' {
+			buf.WriteByte(raw[i])
+			continue
+		}
+		// Find closing bracket considering nesting.
+		depth, j := 1, i+1
+		for ; j < len(raw) && depth > 0; j++ {
+			switch raw[j] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+			}
+		}
+		x := strings.TrimSpace(raw[i+1 : j-1])
+		if len(x) > 0 && !isStaticRE.MatchString(x) && !(x[0] == '"' || x[0] == '\'') {
+			idx = append(idx, x)
+			x = ""
+		}
+		buf.WriteByte('[')
+		buf.WriteString(x)
+		buf.WriteByte(']')
+		i = j - 1
+	}
+	return buf.String(), idx
+}
+
+// Append values missing in the list.
+func lineageAdd(list []string, vals ...string) []string {
+	for _, v := range vals {
+		var ok bool
+		for _, x := range list {
+			if ok = x == v; ok {
+				break
+			}
+		}
+		if !ok && len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
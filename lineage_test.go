@@ -0,0 +1,33 @@
+package decoder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineage(t *testing.T) {
+	files, _ := filepath.Glob("testdata/lineage/*.dec")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".dec")
+		src, _ := os.ReadFile(file)
+		for _, format := range []LineageFormat{LineageFormatCSV, LineageFormatJSON} {
+			t.Run(name+"/"+string(format), func(t *testing.T) {
+				expect, _ := os.ReadFile(strings.Replace(file, ".dec", "."+string(format), 1))
+				tree, err := Parse(src)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var buf bytes.Buffer
+				if err = Lineage(tree).Write(&buf, format); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(buf.Bytes(), expect) {
+					t.Errorf("lineage mismatch, need:\n%s\ngot:\n%s", expect, buf.Bytes())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,426 @@
+// Package lint reports common mistakes in decoder sources.
+//
+// Linter works with source itself rather than with parsed tree, thus it doesn't require registered functions,
+// libraries and parent decoders and may be run over decoders repository in CI (see cmd/declint).
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/koykov/decoder"
+	"github.com/koykov/decoder/internal/source"
+)
+
+// Code identifies kind of the finding.
+type Code string
+
+const (
+	// CodeUnusedVar reports variable declared using "var" or "ctx." that is never read.
+	CodeUnusedVar Code = "DL001"
+	// CodeOverwritten reports assignment overwritten later without being read.
+	CodeOverwritten Code = "DL002"
+	// CodeUnreachable reports rule after unconditional break, continue or return.
+	CodeUnreachable Code = "DL003"
+	// CodeSenselessCond reports comparison of two static values.
+	CodeSenselessCond Code = "DL004"
+	// CodeEmptyBranch reports branch of condition without rules.
+	CodeEmptyBranch Code = "DL005"
+	// CodeShadowedVar reports loop variable that shadows variable of outer loop or function param.
+	CodeShadowedVar Code = "DL006"
+	// CodeDeprecatedAlias reports modifier called using its alias instead of canonical name.
+	CodeDeprecatedAlias Code = "DL007"
+)
+
+// Finding describes one problem found in the source.
+type Finding struct {
+	Code Code
+	// Position of the problem, starting from 1.
+	Line, Col int
+	Message   string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%d:%d: %s %s", f.Line, f.Col, f.Code, f.Message)
+}
+
+var (
+	reAssign    = regexp.MustCompile(`^(var\s+)?([\w.\[\]"'\-]+)\s*=\s*([^=].*)$`)
+	reDecl      = regexp.MustCompile(`^(?:var\s+|ctx\.|context\.)(\w+)\s*=[^=]`)
+	reFlow      = regexp.MustCompile(`^(break|lazybreak|continue|return)\b`)
+	reCtlIf     = regexp.MustCompile(`^(?:return|break|lazybreak|continue)\s*\w*\s+if\s+(.+)$`)
+	reCond      = regexp.MustCompile(`^if\s+([^;]+?)\s*{$`)
+	reTernary   = regexp.MustCompile(`=\s*([^?]+?)\s*\?`)
+	reCmp       = regexp.MustCompile(`^(.+?)\s*(==|!=|>=|<=|>|<)\s*(.+)$`)
+	reStatic    = regexp.MustCompile(`^(-?\d+(\.\d+)?|true|false|nil|"[^"]*"|'[^']*')$`)
+	reMod       = regexp.MustCompile(`\|\s*([\w:]+)`)
+	reLoopRange = regexp.MustCompile(`^(?:\w+\s*:\s*)?for\s+([^:=]+?)\s*:?=\s*range\b`)
+	reLoopCount = regexp.MustCompile(`^(?:\w+\s*:\s*)?for\s+(\w+)\s*:?=`)
+	reFunc      = regexp.MustCompile(`^(?:func|macro)\s+\w+\s*\(([^)]*)\)`)
+	reSubDec    = regexp.MustCompile(`^(use|decode|extends|import)\b`)
+	reDynIdx    = regexp.MustCompile(`\[[^\]]*[^\d\]][^\]]*]`)
+)
+
+// Lint checks the source and returns findings ordered by position.
+func Lint(src []byte) []Finding {
+	stmts := source.Split(src)
+	l := linter{}
+	l.checkStmts(stmts)
+	l.checkUnused(stmts)
+	root := build(stmts)
+	l.walk(root.br[0], nil)
+	sort.SliceStable(l.r, func(i, j int) bool {
+		if l.r[i].Line != l.r[j].Line {
+			return l.r[i].Line < l.r[j].Line
+		}
+		return l.r[i].Col < l.r[j].Col
+	})
+	return l.r
+}
+
+type linter struct {
+	r []Finding
+}
+
+func (l *linter) report(code Code, st *source.Stmt, col int, format string, args ...any) {
+	l.r = append(l.r, Finding{Code: code, Line: st.Line, Col: st.Col + col, Message: fmt.Sprintf(format, args...)})
+}
+
+// Statement of the source with its nested statements.
+type item struct {
+	st *source.Stmt
+	// Heads of the branches, eg: condition and else statements or cases of switch.
+	heads []*source.Stmt
+	br    [][]*item
+}
+
+// Build tree of statements.
+func build(stmts []source.Stmt) *item {
+	root := &item{br: [][]*item{nil}}
+	stack := []*item{root}
+	for i := 0; i < len(stmts); i++ {
+		st := &stmts[i]
+		cur := stack[len(stack)-1]
+		switch st.Kind {
+		case source.Rule:
+			cur.br[len(cur.br)-1] = append(cur.br[len(cur.br)-1], &item{st: st})
+		case source.Open, source.DirIf:
+			blk := &item{st: st, heads: []*source.Stmt{st}, br: [][]*item{nil}}
+			cur.br[len(cur.br)-1] = append(cur.br[len(cur.br)-1], blk)
+			stack = append(stack, blk)
+		case source.Else, source.DirElse, source.Case:
+			if cur != root {
+				cur.heads = append(cur.heads, st)
+				cur.br = append(cur.br, nil)
+			}
+		case source.Close, source.DirEndif:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return root
+}
+
+// Check single statements: senseless comparisons and deprecated modifier aliases.
+func (l *linter) checkStmts(stmts []source.Stmt) {
+	for i := 0; i < len(stmts); i++ {
+		st := &stmts[i]
+		if st.Kind == source.Comment || st.Kind == source.Blank {
+			continue
+		}
+		var expr string
+		if m := reCond.FindStringSubmatch(st.Text); m != nil {
+			expr = m[1]
+		} else if m = reCtlIf.FindStringSubmatch(st.Text); m != nil {
+			expr = m[1]
+		} else if reAssign.MatchString(st.Text) {
+			if m = reTernary.FindStringSubmatch(st.Text); m != nil {
+				expr = m[1]
+			}
+		}
+		if m := reCmp.FindStringSubmatch(expr); m != nil && reStatic.MatchString(m[1]) && reStatic.MatchString(m[3]) {
+			l.report(CodeSenselessCond, st, 0, "comparison of two static values '%s'", expr)
+		}
+
+		text := stripQuotes(st.Text)
+		for _, m := range reMod.FindAllStringSubmatchIndex(text, -1) {
+			name := text[m[2]:m[3]]
+			if canon := decoder.GetModName(name); len(canon) > 0 && canon != name {
+				l.report(CodeDeprecatedAlias, st, m[2], "modifier alias '%s' is deprecated, use '%s'", name, canon)
+			}
+		}
+	}
+}
+
+// Check variables declared but never read.
+//
+// Sub-decoders and parent decoders may read variables of the context, so check is skipped if source contains them.
+func (l *linter) checkUnused(stmts []source.Stmt) {
+	type decl struct {
+		name string
+		st   *source.Stmt
+	}
+	var decls []decl
+	for i := 0; i < len(stmts); i++ {
+		st := &stmts[i]
+		if st.Kind != source.Rule {
+			continue
+		}
+		if reSubDec.MatchString(st.Text) {
+			return
+		}
+		if m := reDecl.FindStringSubmatch(st.Text); m != nil {
+			decls = append(decls, decl{name: m[1], st: st})
+		}
+	}
+	used := make(map[string]bool)
+	for i := 0; i < len(stmts); i++ {
+		st := &stmts[i]
+		if st.Kind == source.Comment || st.Kind == source.Blank {
+			continue
+		}
+		text := st.Text
+		if reDecl.MatchString(text) {
+			// Declaration itself isn't reading.
+			text = reAssign.FindStringSubmatch(text)[3]
+		}
+		text = stripQuotes(text)
+		for j := 0; j < len(decls); j++ {
+			if !used[decls[j].name] && uses(text, decls[j].name) {
+				used[decls[j].name] = true
+			}
+		}
+	}
+	reported := make(map[string]bool)
+	for i := 0; i < len(decls); i++ {
+		d := decls[i]
+		if !used[d.name] && !reported[d.name] {
+			reported[d.name] = true
+			l.report(CodeUnusedVar, d.st, 0, "variable '%s' is declared but never used", d.name)
+		}
+	}
+}
+
+// Walk over branch of statements and check control flow and assignments.
+//
+// Scope contains names of variables of outer loops and function params.
+func (l *linter) walk(items []*item, scope []string) {
+	type write struct {
+		dst string
+		st  *source.Stmt
+	}
+	var (
+		pending    []write
+		flow, dead bool
+	)
+	forget := func(text string) {
+		text = stripQuotes(text)
+		for i := 0; i < len(pending); i++ {
+			dst := pending[i].dst
+			if reads(text, dst) || strings.HasPrefix(dst, "ctx.") && reads(text, dst[len("ctx."):]) {
+				pending = append(pending[:i], pending[i+1:]...)
+				i--
+			}
+		}
+	}
+	for _, it := range items {
+		if flow && !dead {
+			// Report only the first unreachable rule in the branch.
+			l.report(CodeUnreachable, it.st, 0, "unreachable rule")
+			dead = true
+		}
+		text := it.st.Text
+		if it.br == nil {
+			if reFlow.MatchString(text) {
+				flow = flow || !reCtlIf.MatchString(text) && !strings.HasPrefix(text, "lazybreak")
+				// Written values may be used after exit, so forget them.
+				pending = pending[:0]
+				continue
+			}
+			m := reAssign.FindStringSubmatch(text)
+			if m == nil {
+				// Callbacks, sub-decoders and automap may read anything.
+				pending = pending[:0]
+				continue
+			}
+			dst := m[2]
+			forget(m[3])
+			if dyn := reDynIdx.FindAllString(dst, -1); len(dyn) > 0 || strings.HasSuffix(dst, "[]") {
+				// Dynamic destinations aren't tracked.
+				forget(strings.Join(dyn, " "))
+				continue
+			}
+			if len(m[1]) > 0 {
+				dst = "ctx." + dst
+			} else if strings.HasPrefix(dst, "context.") {
+				dst = "ctx." + dst[len("context."):]
+			}
+			for i := 0; i < len(pending); i++ {
+				if pending[i].dst == dst {
+					l.report(CodeOverwritten, pending[i].st, 0, "value assigned to '%s' is overwritten at line %d without being read",
+						dst, it.st.Line)
+					pending = append(pending[:i], pending[i+1:]...)
+					break
+				}
+			}
+			pending = append(pending, write{dst: dst, st: it.st})
+			continue
+		}
+
+		// Block statement.
+		l.checkBlock(it, scope)
+		var sub strings.Builder
+		flat(&sub, it)
+		if reFlowAny.MatchString(sub.String()) {
+			pending = pending[:0]
+		} else {
+			forget(sub.String())
+		}
+	}
+}
+
+var reFlowAny = regexp.MustCompile(`(?m)^(break|lazybreak|continue|return)\b`)
+
+// Check block statement and walk over its branches.
+func (l *linter) checkBlock(it *item, scope []string) {
+	head := it.st.Text
+	if strings.HasPrefix(head, "if ") || it.st.Kind == source.DirIf {
+		for i := 0; i < len(it.br); i++ {
+			if len(it.br[i]) == 0 {
+				l.report(CodeEmptyBranch, it.heads[i], 0, "empty branch")
+			}
+		}
+	}
+	var names []string
+	if m := reLoopRange.FindStringSubmatch(head); m != nil {
+		names = strings.Split(m[1], ",")
+	} else if m = reLoopCount.FindStringSubmatch(head); m != nil {
+		names = []string{m[1]}
+	} else if m = reFunc.FindStringSubmatch(head); m != nil {
+		names = strings.Split(m[1], ",")
+		// Function has own scope.
+		scope = nil
+	}
+	if len(names) > 0 {
+		inner := append([]string(nil), scope...)
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if len(name) == 0 || name == "_" {
+				continue
+			}
+			if !strings.HasPrefix(head, "func") && !strings.HasPrefix(head, "macro") {
+				for _, outer := range scope {
+					if outer == name {
+						l.report(CodeShadowedVar, it.st, 0, "loop variable '%s' shadows outer variable", name)
+					}
+				}
+			}
+			inner = append(inner, name)
+		}
+		scope = inner
+	}
+	for i := 0; i < len(it.br); i++ {
+		l.walk(it.br[i], scope)
+	}
+}
+
+// Write text of all statements of the block to buf, one per line.
+func flat(buf *strings.Builder, it *item) {
+	for i := 0; i < len(it.heads); i++ {
+		buf.WriteString(it.heads[i].Text)
+		buf.WriteByte('\n')
+	}
+	if it.br == nil {
+		buf.WriteString(it.st.Text)
+		buf.WriteByte('\n')
+	}
+	for i := 0; i < len(it.br); i++ {
+		for j := 0; j < len(it.br[i]); j++ {
+			flat(buf, it.br[i][j])
+		}
+	}
+}
+
+// Check if text reads path or its parent or child.
+func reads(text, path string) bool {
+	for i := len(path); i > 0; i-- {
+		if i < len(path) && path[i] != '.' && path[i] != '[' {
+			continue
+		}
+		prefix := path[:i]
+		for o := 0; ; {
+			j := strings.Index(text[o:], prefix)
+			if j == -1 {
+				break
+			}
+			j += o
+			o = j + 1
+			if j > 0 && (isWord(text[j-1]) || text[j-1] == '.') {
+				continue
+			}
+			k := j + len(prefix)
+			if k == len(text) {
+				return true
+			}
+			if isWord(text[k]) {
+				continue
+			}
+			if i < len(path) && (text[k] == '.' || text[k] == '[') {
+				// Sibling of the path, eg: "obj.Finance.MoneyIn" for "obj.Finance.Balance".
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Check if text uses variable name.
+func uses(text, name string) bool {
+	for o := 0; ; {
+		j := strings.Index(text[o:], name)
+		if j == -1 {
+			return false
+		}
+		j += o
+		o = j + 1
+		if k := j + len(name); k < len(text) && isWord(text[k]) {
+			continue
+		}
+		if j == 0 || (!isWord(text[j-1]) && text[j-1] != '.') {
+			return true
+		}
+		for _, pfx := range []string{"ctx.", "context."} {
+			if p := j - len(pfx); p >= 0 && text[p:j] == pfx && (p == 0 || !isWord(text[p-1]) && text[p-1] != '.') {
+				return true
+			}
+		}
+	}
+}
+
+// Replace contents of quoted strings with spaces keeping positions.
+func stripQuotes(text string) string {
+	if strings.IndexAny(text, "\"'`") == -1 {
+		return text
+	}
+	b := []byte(text)
+	var q byte
+	for i := 0; i < len(b); i++ {
+		switch {
+		case q != 0 && b[i] == q:
+			q = 0
+		case q != 0:
+			b[i] = ' '
+		case b[i] == '"' || b[i] == '\'' || b[i] == '`':
+			q = b[i]
+		}
+	}
+	return string(b)
+}
+
+func isWord(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,27 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	files, _ := filepath.Glob("testdata/*.dec")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".dec")
+		t.Run(name, func(t *testing.T) {
+			src, _ := os.ReadFile(file)
+			expect, _ := os.ReadFile(strings.Replace(file, ".dec", ".txt", 1))
+			var buf strings.Builder
+			for _, f := range Lint(src) {
+				buf.WriteString(f.String())
+				buf.WriteByte('\n')
+			}
+			if buf.String() != string(expect) {
+				t.Errorf("findings mismatch, need:\n%s\ngot:\n%s", expect, buf.String())
+			}
+		})
+	}
+}
@@ -31,6 +31,8 @@ var (
 
 // RegisterModFn registers new modifier function.
 func RegisterModFn(name, alias string, mod ModFn) *ModFnTuple {
+	regMux.Lock()
+	defer regMux.Unlock()
 	if idx, ok := modRegistry[name]; ok && idx >= 0 && idx < len(modBuf) {
 		return &modBuf[idx]
 	}
@@ -46,6 +48,7 @@ func RegisterModFn(name, alias string, mod ModFn) *ModFnTuple {
 	if len(alias) > 0 {
 		modRegistry[alias] = idx
 	}
+	nextRegGen()
 	return &modBuf[idx]
 }
 
@@ -58,8 +61,22 @@ func RegisterModFnNS(namespace, name, alias string, mod ModFn) *ModFnTuple {
 	return RegisterModFn(name, alias, mod)
 }
 
+// GetModName returns canonical name of the modifier registered under given name or alias.
+//
+// Returns empty string if modifier isn't registered.
+func GetModName(name string) string {
+	regMux.RLock()
+	defer regMux.RUnlock()
+	if idx, ok := modRegistry[name]; ok && idx >= 0 && idx < len(modBuf) {
+		return modBuf[idx].name
+	}
+	return ""
+}
+
 // GetModFn returns modifier from the registry.
 func GetModFn(name string) ModFn {
+	regMux.RLock()
+	defer regMux.RUnlock()
 	if idx, ok := modRegistry[name]; ok && idx >= 0 && idx < len(modBuf) {
 		return modBuf[idx].fn
 	}
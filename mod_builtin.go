@@ -228,7 +228,7 @@ func modAppend(ctx *Ctx, buf *any, _ any, args []any) error {
 		return nil // cannot check path
 	}
 
-	ctx.bufS = tokenize(ctx.bufS[:0], byteconv.B2S(path))
+	ctx.bufS = ctx.splitPath(ctx.bufS[:0], path, false)
 	if len(ctx.bufS) == 0 {
 		return nil
 	}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/inspector/testobj_ins"
 )
 
 func TestMod(t *testing.T) {
@@ -12,6 +13,8 @@ func TestMod(t *testing.T) {
 	t.Run("ifThenElse", func(t *testing.T) { testMod(t, "src", scenarioModIfThenElse) })
 	t.Run("append", func(t *testing.T) { testMod(t, "src", scenarioModAppend) })
 	t.Run("reset", func(t *testing.T) { testMod3(t, "src", scenarioModReset) })
+	t.Run("delete", func(t *testing.T) { testMod(t, "src", scenarioModDelete) })
+	t.Run("clear", func(t *testing.T) { testMod(t, "src", scenarioModClear) })
 }
 
 func testMod(t *testing.T, jsonKey string, assertFn func(t testing.TB, obj *testobj.TestObject)) {
@@ -38,6 +41,41 @@ func BenchmarkMod(b *testing.B) {
 	b.Run("ifThenElse", func(b *testing.B) { benchMod(b, "src", scenarioModIfThenElse, false) })
 	b.Run("append", func(b *testing.B) { benchMod(b, "src", scenarioModAppend, false) })
 	b.Run("reset", func(b *testing.B) { benchMod3(b, "src", scenarioModReset, true) })
+	b.Run("delete", func(b *testing.B) { benchMod(b, "src", scenarioModDelete, false) })
+	b.Run("clear", func(b *testing.B) { benchMod(b, "src", scenarioModClear, false) })
+}
+
+func BenchmarkMapDelete(b *testing.B) {
+	ctx := NewCtx()
+	obj := &testobj.TestObject{Flags: testobj.TestFlag{}, Permission: &testobj.TestPermission{}}
+	ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+	flags, perm := []byte("obj.Flags"), []byte("obj.Permission")
+	key, id := []byte("x"), int32(1)
+	args := make([]any, 2)
+	fn := func() {
+		obj.Flags["x"] = 1
+		(*obj.Permission)[1] = true
+		args[0], args[1] = &flags, &key
+		if err := cbDelete(ctx, args); err != nil {
+			b.Fatal(err)
+		}
+		args[0], args[1] = &perm, &id
+		if err := cbDelete(ctx, args); err != nil {
+			b.Fatal(err)
+		}
+		ctx.bufKB = ctx.bufKB[:0]
+	}
+	fn()
+	if len(obj.Flags) != 0 || len(*obj.Permission) != 0 {
+		b.Fatal("keys must be deleted")
+	}
+	if n := testing.AllocsPerRun(100, fn); n > 0 {
+		b.Errorf("delete must not allocate, got %v allocs", n)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		fn()
+	}
 }
 
 func benchMod(b *testing.B, jsonKey string, assertFn func(t testing.TB, obj *testobj.TestObject), noClear bool) {
@@ -101,3 +139,21 @@ func scenarioModReset(t testing.TB, obj *testobj.TestObject) {
 	x := obj.Finance.History[0]
 	assertF64(t, "Cost", x.Cost, 0)
 }
+
+func scenarioModDelete(t testing.TB, obj *testobj.TestObject) {
+	_, ok := obj.Flags["x-internal"]
+	assertBl(t, "Flags[x-internal]", ok, false)
+	_, ok = obj.Flags["c"]
+	assertBl(t, "Flags[c]", ok, false)
+	assertI32(t, "Flags[b]", obj.Flags["b"], 67)
+	assertI32(t, "Flags[d]", obj.Flags["d"], 67)
+	perm := obj.Permission
+	_, ok = (*perm)[1]
+	assertBl(t, "Permission[1]", ok, false)
+	assertBl(t, "Permission[2]", (*perm)[2], true)
+}
+
+func scenarioModClear(t testing.TB, obj *testobj.TestObject) {
+	assertI32(t, "len(Flags)", int32(len(obj.Flags)), 1)
+	assertI32(t, "Flags[c]", obj.Flags["c"], 3)
+}
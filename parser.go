@@ -6,6 +6,7 @@ import (
 	"hash/crc64"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 
 	"github.com/koykov/bytealg"
@@ -17,6 +18,28 @@ type parser struct {
 
 	// Decoder body to parse.
 	body []byte
+	// Stack of labels of loops the parser is inside.
+	lbl [][]byte
+	// Key of parent decoder (see extends statement).
+	ext []byte
+	// User-defined function currently parsing.
+	fn *udf
+	// Declared and imported user-defined functions.
+	udfs, imp []*udf
+	// User-defined functions of parent decoder, may be overridden by own functions.
+	inh []*udf
+	// Keys of imported libraries.
+	libs []string
+	// Available (own and imported) constants and own constants.
+	consts, own map[string][]byte
+	// Source spans of root nodes, collects only if not nil.
+	spans *[][2]int
+	// Offsets of lines beginnings in the body, see pos().
+	lines []int
+	// Feature flags to resolve preprocessor directives at parse time, nil means resolving at decode time.
+	flags Flags
+	// Unknown functions handling mode.
+	mode ParseMode
 }
 
 var (
@@ -25,17 +48,19 @@ var (
 	vline    = []byte("|")
 	space    = []byte(" ")
 	comma    = []byte(",")
+	colon    = []byte(":")
 	uscore   = []byte("_")
 	dot      = []byte(".")
 	empty    = []byte("")
 	qbO      = []byte("[")
 	qbC      = []byte("]")
+	qbE      = []byte("[]")
 	noFmt    = []byte(" \t\n\r")
 	quotes   = []byte("\"'`")
 	comment  = []byte("//")
 	loopBrk  = []byte("break")
 	loopLBrk = []byte("lazybreak")
-	loopCont = []byte("continue")
+	ret      = []byte("return")
 	condLen  = []byte("len")
 	condCap  = []byte("cap")
 	_, _     = nl, noFmt
@@ -44,7 +69,8 @@ var (
 	replNew     = []byte("new(\"$1\").($1)")
 	replBuf     = []byte("bufferize(\"$1\").($1)")
 	replAppend  = []byte("append(\"$1\", $2)")
-	replReset   = []byte("reset(\"$1\")")
+	replReset   = []byte("$1(\"$2\")")
+	replDelete  = []byte("delete(\"$1\", $2)")
 
 	// Operation constants.
 	opEq_  = []byte("==")
@@ -65,23 +91,40 @@ var (
 	reReplNew    = regexp.MustCompile(`new\(([^)]+)\)`)
 	reReplBuf    = regexp.MustCompile(`bufferize\(([^)]+)\)`)
 	reReplAppend = regexp.MustCompile(`append\(\s*["']*([^,"']+)["']*\s*,\s*(.*)\)`)
-	reReplReset  = regexp.MustCompile(`reset\(\s*["']*([^,"')]+)["']*\)`)
+	reReplReset  = regexp.MustCompile(`(reset|clear)\(\s*["']*([^,"')]+)["']*\)`)
+	reReplDelete = regexp.MustCompile(`delete\(\s*["']*([^,"']+)["']*\s*,\s*(.*)\)`)
 
-	reAssignV2V = regexp.MustCompile(`(?i)([\w\d\\.\[\]]+)\s*=\s*(.*)`)
-	reAssignF2V = regexp.MustCompile(`(?i)([\w\d\\.\[\]]+)\s*=\s*([^(|]+)\(([^)]*)\)`)
+	reAssignV2V = regexp.MustCompile(`(?i)([\w\d\\.\[\]"'\-]+)\s*=\s*(.*)`)
+	reAssignF2V = regexp.MustCompile(`(?i)([\w\d\\.\[\]"'\-]+)\s*=\s*([^(|]+)\(([^)]*)\)`)
 	reFunction  = regexp.MustCompile(`([^(]+)\(([^)]*)\)`)
 	reMod       = regexp.MustCompile(`([^(]+)\(*([^)]*)\)*`)
 	reSet       = regexp.MustCompile(`(.*)\.{([^}]+)}`)
 
-	reTernary         = regexp.MustCompile(`(?i)([\w\d\\.\[\]]+)\s*=\s*(.*)(==|!=|>=|<=|>|<)(.*)\s*\?\s*([^:]+):(.*)`)
-	reTernaryHelper   = regexp.MustCompile(`(?i)([\w\d\\.\[\]]+)\s*=\s*([^(]+)\(*([^)]*)\)\s*\?\s*([^:]+):(.*)`)
-	reTernaryCondExpr = regexp.MustCompile(`(?i)[\w\d\\.\[\]]+\s*=\s*(.*)\s*(==|!=|>=|<=|>|<)([^?]+)`)
+	reTernary         = regexp.MustCompile(`(?i)([\w\d\\.\[\]"'\-]+)\s*=\s*(.*)(==|!=|>=|<=|>|<)(.*)\s*\?\s*([^:]+):(.*)`)
+	reTernaryHelper   = regexp.MustCompile(`(?i)([\w\d\\.\[\]"'\-]+)\s*=\s*([^(]+)\(*([^)]*)\)\s*\?\s*([^:]+):(.*)`)
+	reTernaryCondExpr = regexp.MustCompile(`(?i)[\w\d\\.\[\]"'\-]+\s*=\s*(.*)\s*(==|!=|>=|<=|>|<)([^?]+)`)
 
 	reLoop      = regexp.MustCompile(`for .*`)
 	reLoopRange = regexp.MustCompile(`for ([^:]+)\s*:*=\s*range\s*([^\s]*)\s*\{` + "")
 	reLoopCount = regexp.MustCompile(`for (\w*)\s*:*=\s*(\w+)\s*;\s*\w+\s*(<|<=|>|>=|!=)+\s*([^;]+)\s*;\s*\w*(--|\+\+)+\s*\{`)
-	reLoopBrk   = regexp.MustCompile(`break (\d+)`)
-	reLoopLBrk  = regexp.MustCompile(`lazybreak (\d+)`)
+	reLoopLabel = regexp.MustCompile(`^(\w+)\s*:\s*for `)
+	reLoopCtl   = regexp.MustCompile(`^(break|lazybreak|continue)(?:\s+(\w+))?\s*$`)
+	reLoopDepth = regexp.MustCompile(`^\d+$`)
+	reCtlIf     = regexp.MustCompile(`^(return|break|lazybreak|continue)\s*(\w*)\s+if\s+(.+)$`)
+
+	reWith = regexp.MustCompile(`^with\s+([\w\d\\.\[\]]+)\[\+]\s+as\s+(\w+)\s*{`)
+
+	reAutomap    = regexp.MustCompile(`^automap\(\s*([^,\s]+)\s*,\s*([^)\s]+)\s*\)(.*)$`)
+	reAutomapOpt = regexp.MustCompile(`(except|rename|strategy)\(([^)]*)\)`)
+
+	reDecode = regexp.MustCompile(`^decode\(\s*["']([^"']+)["']\s*,\s*([^,\s]+)\s*,\s*([^)\s]+)\s*\)$`)
+	reUse    = regexp.MustCompile(`^use\s+["']([^"']+)["']$`)
+
+	reExtends = regexp.MustCompile(`^extends\s+["']([^"']+)["']$`)
+
+	reFunc      = regexp.MustCompile(`^(func|macro)\s+(\w+)\s*\(([^)]*)\)\s*{`)
+	reImport    = regexp.MustCompile(`^import\s+["']([^"']+)["']$`)
+	reReturnVal = regexp.MustCompile(`^return\s+(.+)$`)
 
 	reCond        = regexp.MustCompile(`if .*`)
 	reCondExpr    = regexp.MustCompile(`if (.*)(==|!=|>=|<=|>|<)(.*)\s*{`)
@@ -105,23 +148,47 @@ var (
 )
 
 // Parse parses the decoder rules.
-func Parse(src []byte) (*Tree, error) {
+//
+// Unknown functions are reported as errors unless other mode is passed (see ParseMode). If flags are passed,
+// preprocessor directives (see "#if flag(...)") resolves at parse time, otherwise at decode time using flags of the
+// context.
+func Parse(src []byte, opts ...ParseOption) (*Tree, error) {
 	p := &parser{body: src}
-	hsum := crc64.Checksum(p.body, crc64Tab)
-	if tree := decDB.getTreeByHash(hsum); tree != nil {
-		return tree, nil
+	for i := 0; i < len(opts); i++ {
+		opts[i].apply(p)
 	}
+	if p.flags == nil {
+		hsum := crc64.Checksum(p.body, crc64Tab)
+		if tree := decDB.getTreeByHash(hsum); tree != nil {
+			return tree, nil
+		}
+	}
+
+	return p.tree()
+}
 
+// Parse the body and build the tree.
+func (p *parser) tree() (*Tree, error) {
+	var err error
+	gen := regGeneration()
+	if p.body, err = p.substConsts(p.body); err != nil {
+		return nil, err
+	}
 	t := p.targetSnapshot()
 	nodes, _, err := p.parse(nil, nil, 0, t)
 	return &Tree{
-		nodes: nodes,
-		hsum:  0,
+		nodes:   nodes,
+		hsum:    0,
+		parent:  string(p.ext),
+		imports: p.libs,
+		udfs:    p.udfs,
+		consts:  p.own,
+		gen:     gen,
 	}, err
 }
 
 // ParseFile parses the file.
-func ParseFile(fileName string) (tree *Tree, err error) {
+func ParseFile(fileName string, opts ...ParseOption) (tree *Tree, err error) {
 	_, err = os.Stat(fileName)
 	if os.IsNotExist(err) {
 		return
@@ -131,7 +198,7 @@ func ParseFile(fileName string) (tree *Tree, err error) {
 	if err != nil {
 		return
 	}
-	return Parse(raw)
+	return Parse(raw, opts...)
 }
 
 func (p *parser) parse(dst []node, root *node, offset int, t *target) ([]node, int, error) {
@@ -148,9 +215,21 @@ func (p *parser) parse(dst []node, root *node, offset int, t *target) ([]node, i
 			continue
 		}
 		r := node{typ: typeOperator}
+		n, pos := len(dst), offset
 		if dst, offset, up, err = p.processCtl(dst, root, &r, ctl, offset); err != nil {
 			return dst, offset, err
 		}
+		for i := n; i < len(dst); i++ {
+			if dst[i].line == 0 {
+				dst[i].line, dst[i].col = p.pos(pos)
+			}
+		}
+		if p.spans != nil && root == nil {
+			// Keep source spans of root nodes (see Template).
+			for i := n; i < len(dst); i++ {
+				*p.spans = append(*p.spans, [2]int{pos, offset})
+			}
+		}
 		if up {
 			break
 		}
@@ -161,6 +240,20 @@ func (p *parser) parse(dst []node, root *node, offset int, t *target) ([]node, i
 	return dst, offset, err
 }
 
+// Get line and column of the offset in the body, both starting from 1.
+func (p *parser) pos(offset int) (line, col int) {
+	if p.lines == nil {
+		p.lines = append(p.lines, 0)
+		for i := 0; i < len(p.body); i++ {
+			if p.body[i] == '\n' {
+				p.lines = append(p.lines, i+1)
+			}
+		}
+	}
+	i := sort.SearchInts(p.lines, offset+1) - 1
+	return i + 1, offset - p.lines[i] + 1
+}
+
 func (p *parser) nextCtl(offset int) ([]byte, int, bool) {
 	var eof bool
 	if offset, eof = p.skipFmt(offset); eof {
@@ -185,10 +278,88 @@ func (p *parser) nextCtl(offset int) ([]byte, int, bool) {
 
 func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) ([]node, int, bool, error) {
 	var err error
+	if ctl[0] == '#' && reDir.Match(ctl) {
+		return p.processDir(dst, root, ctl, offset)
+	}
 	if ctl[0] == '#' || bytes.HasPrefix(ctl, comment) {
 		offset += len(ctl)
 		return dst, offset, false, nil
 	}
+	if m := reExtends.FindSubmatch(ctl); m != nil {
+		// Inheritance caught, eg: "extends "base_user"".
+		if root != nil || len(dst) > 0 || len(p.ext) > 0 {
+			return dst, offset, false, fmt.Errorf("%w at offset %d", ErrExtendsNotTop, offset)
+		}
+		p.ext = m[1]
+		if parent := decDB.getKey(byteconv.B2S(m[1])); parent != nil && parent.tree != nil {
+			// Functions of parent (including inherited ones) are available in the child.
+			p.inh = parent.tree.udfs
+		}
+		offset += len(ctl)
+		return dst, offset, false, nil
+	}
+	if m := reImport.FindSubmatch(ctl); m != nil {
+		// Library import caught, eg: "import "lib/phones"".
+		if root != nil {
+			return dst, offset, false, fmt.Errorf("%w at offset %d", ErrFuncNotTop, offset)
+		}
+		lib := decDB.getKey(byteconv.B2S(m[1]))
+		if lib == nil {
+			return dst, offset, false, fmt.Errorf("%w: '%s' at offset %d", ErrLibraryNotFound, m[1], offset)
+		}
+		p.imp = append(p.imp, lib.orig.udfs...)
+		p.libs = append(p.libs, string(m[1]))
+		offset += len(ctl)
+		return dst, offset, false, nil
+	}
+	if m := reFunc.FindSubmatch(ctl); m != nil {
+		// User-defined function caught, eg: "func normPhone(x) {" or "macro setMoney(dst, src) {".
+		if root != nil {
+			return dst, offset, false, fmt.Errorf("%w at offset %d", ErrFuncNotTop, offset)
+		}
+		if findUDF(p.udfs, p.imp, m[2]) != nil {
+			// Functions of parent may be overridden.
+			return dst, offset, false, fmt.Errorf("%w: '%s' at offset %d", ErrFuncRedeclared, m[2], offset)
+		}
+		f := &udf{name: m[2], macro: m[1][0] == 'm', params: extractParams(m[3])}
+		t := p.targetSnapshot()
+		p.openBlock(typeFunc)
+		p.fn = f
+
+		offset += len(ctl)
+		f.body, offset, err = p.parse(nil, &node{typ: typeFunc}, offset, t)
+		p.fn = nil
+		if err != nil {
+			return dst, offset, false, err
+		}
+		// Register function after parsing of the body, thus recursive calls aren't possible.
+		p.udfs = append(p.udfs, f)
+		return dst, offset, false, nil
+	}
+	if m := reCtlIf.FindSubmatch(ctl); m != nil {
+		// Conditional control statement caught, eg: "return if x == 1".
+		// Convert it to condition node with the only statement in true branch.
+		head := make([]byte, 0, len(m[3])+5)
+		head = append(append(append(head, "if "...), m[3]...), " {"...)
+		if err = p.parseCondHead(r, head, offset); err != nil {
+			return dst, offset, false, err
+		}
+		// Cut off condition suffix, eg: "break 2 if x == 1" -> "break 2".
+		stmt := node{typ: typeOperator}
+		ctl1 := bytealg.Trim(ctl[:len(ctl)-len(m[3])], space)
+		ctl1 = bytealg.Trim(ctl1[:len(ctl1)-2], space)
+		var ok bool
+		if ok, err = p.processCtlStmt(&stmt, ctl1, offset); err != nil {
+			return dst, offset, false, err
+		}
+		if !ok {
+			return dst, offset, false, fmt.Errorf("unknown node '%s' at position %d", string(ctl), offset)
+		}
+		r.child = append(r.child, node{typ: typeCondTrue, child: []node{stmt}})
+		dst = append(dst, *r)
+		offset += len(ctl)
+		return dst, offset, false, err
+	}
 	if reLoop.Match(ctl) {
 		if m := reLoopRange.FindSubmatch(ctl); m != nil {
 			r.typ = typeLoopRange
@@ -215,11 +386,70 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 		} else {
 			return dst, offset, false, fmt.Errorf("couldn't parse loop control structure '%s' at offset %d", string(ctl), offset)
 		}
+		if m := reLoopLabel.FindSubmatch(ctl); m != nil {
+			// Labeled loop caught, eg: "outer: for ...".
+			for i := 0; i < len(p.lbl); i++ {
+				if bytes.Equal(p.lbl[i], m[1]) {
+					return dst, offset, false, fmt.Errorf("%w: '%s' at offset %d", ErrDupLoopLabel, m[1], offset)
+				}
+			}
+			r.loopLbl = m[1]
+		}
 		t := p.targetSnapshot()
-		p.cl++
+		p.openBlock(r.typ)
+		p.pushLabel(r.loopLbl)
+
+		offset += len(ctl)
+		r.child, offset, err = p.parse(r.child, r, offset, t)
+		p.popLabel()
+		if err != nil {
+			return dst, offset, false, err
+		}
+		dst = append(dst, *r)
+		return dst, offset, false, err
+	}
+
+	if m := reDecode.FindSubmatch(ctl); m != nil {
+		// Sub-decoder call caught, eg: "decode("address", dst.Address, src.shipping)".
+		r.typ = typeDecode
+		r.subKey, r.dst, r.src = m[1], m[2], m[3]
+		r.tokenizePaths()
+		dst = append(dst, *r)
+		offset += len(ctl)
+		return dst, offset, false, err
+	}
+	if m := reUse.FindSubmatch(ctl); m != nil {
+		// Sub-decoder include caught, eg: "use "common_headers"".
+		r.typ = typeUse
+		r.subKey = m[1]
+		dst = append(dst, *r)
+		offset += len(ctl)
+		return dst, offset, false, err
+	}
+	if m := reAutomap.FindSubmatch(ctl); m != nil {
+		// Automap statement caught, eg: "automap(dst.User, src.user) except(Id)".
+		r.typ = typeAutomap
+		r.dst, r.src = m[1], m[2]
+		r.tokenizePaths()
+		if r.am, err = parseAutomapOpts(m[3]); err != nil {
+			return dst, offset, false, fmt.Errorf("%w at offset %d", err, offset)
+		}
+		dst = append(dst, *r)
+		offset += len(ctl)
+		return dst, offset, false, err
+	}
+	if m := reWith.FindSubmatch(ctl); m != nil {
+		// With block caught, eg: "with dst.Items[+] as it {".
+		r.typ = typeWith
+		r.dst, r.withVar = m[1], m[2]
+		r.tokenizePaths()
+
+		t := p.targetSnapshot()
+		p.openBlock(typeWith)
 
 		offset += len(ctl)
-		if r.child, offset, err = p.parse(r.child, r, offset, t); err != nil {
+		r.child, offset, err = p.parse(r.child, r, offset, t)
+		if err != nil {
 			return dst, offset, false, err
 		}
 		dst = append(dst, *r)
@@ -238,26 +468,21 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 		}
 		r.condOKL, r.condOKR = m[1], m[2]
 		r.condHlp, r.condHlpArg = m[3], extractArgs(m[4])
+		if err = p.bindCondHlp(r, r.condHlp, r.condHlpArg, offset); err != nil {
+			return dst, offset, false, err
+		}
 		if len(m[5]) > 0 {
 			r.condIns = m[5]
 		}
 		r.condL, r.condR, r.condStaticL, r.condStaticR, r.condOp = p.parseCondExpr(reCondExprOK, ctl)
 
 		t := p.targetSnapshot()
-		p.cc++
+		p.openBlock(typeCondOK)
 
 		var subNodes []node
 		offset += len(ctl)
 		subNodes, offset, err = p.parse(subNodes, &node{typ: typeCondOK}, offset, t)
-		split := splitNodes(subNodes)
-		if len(split) > 0 {
-			nodeTrue := node{typ: typeCondTrue, child: split[0]}
-			r.child = append(r.child, nodeTrue)
-		}
-		if len(split) > 1 {
-			nodeFalse := node{typ: typeCondFalse, child: split[1]}
-			r.child = append(r.child, nodeFalse)
-		}
+		condBranches(r, subNodes)
 
 		dst = append(dst, *r)
 		return dst, offset, false, err
@@ -276,7 +501,7 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 	if m := reSwitch.FindSubmatch(ctl); m != nil {
 		// Create new target, increase switch counter and dive deeper.
 		t := p.targetSnapshot()
-		p.cs++
+		p.openBlock(typeSwitch)
 
 		r.typ = typeSwitch
 		if len(m) > 0 {
@@ -294,6 +519,9 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 		r.typ = typeCase
 		r.caseHlp = m[1]
 		r.caseHlpArg = extractArgs(m[2])
+		if err = p.bindCondHlp(r, r.caseHlp, r.caseHlpArg, offset); err != nil {
+			return dst, offset, false, err
+		}
 		dst = append(dst, *r)
 		offset = offset + len(ctl)
 		return dst, offset, false, err
@@ -316,52 +544,9 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 
 	if ctl[0] == '}' {
 		offset++
-		switch root.typ {
-		case typeLoopCount, typeLoopRange:
-			p.cl--
-		case typeCond, typeCondOK, typeElse, typeDiv:
-			p.cc--
-		case typeSwitch:
-			p.cs--
-		default:
-			err = ErrUnexpectedClose
-		}
-		return dst, offset, true, err
-	}
-	if reLoopLBrk.Match(ctl) {
-		r.typ = typeLBreak
-		m := reLoopLBrk.FindSubmatch(ctl)
-		if i, _ := strconv.ParseInt(byteconv.B2S(m[1]), 10, 64); i > 0 {
-			r.loopBrkD = int(i)
-		}
-		dst = append(dst, *r)
-		offset += len(ctl)
-		return dst, offset, false, err
-	}
-	if bytes.Equal(ctl, loopLBrk) {
-		r.typ = typeLBreak
-		dst = append(dst, *r)
-		offset += len(ctl)
-		return dst, offset, false, err
-	}
-	if reLoopBrk.Match(ctl) {
-		r.typ = typeBreak
-		m := reLoopBrk.FindSubmatch(ctl)
-		if i, _ := strconv.ParseInt(byteconv.B2S(m[1]), 10, 64); i > 0 {
-			r.loopBrkD = int(i)
-		}
-		dst = append(dst, *r)
-		offset += len(ctl)
-		return dst, offset, false, err
+		return dst, offset, true, p.closeBlock(root.typ)
 	}
-	if bytes.Equal(ctl, loopBrk) {
-		r.typ = typeBreak
-		dst = append(dst, *r)
-		offset += len(ctl)
-		return dst, offset, false, err
-	}
-	if bytes.Equal(ctl, loopCont) {
-		r.typ = typeContinue
+	if ok, err := p.processCtlStmt(r, ctl, offset); ok || err != nil {
 		dst = append(dst, *r)
 		offset += len(ctl)
 		return dst, offset, false, err
@@ -385,13 +570,14 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 		}
 		// Check static/variable.
 		if r.static = isStatic(r.src); r.static {
-			r.src = bytealg.Trim(r.src, quotes)
+			r.src = unquote(r.src)
 		} else {
-			r.src, r.mod = extractMods(r.src)
+			if r.src, r.mod, err = p.extractMods(r.src, offset); err != nil {
+				return dst, offset, false, err
+			}
 			r.src, r.subset = extractSet(r.src)
 		}
-		r.dsta = tokenize(r.dsta, byteconv.B2S(r.dst))
-		r.srca = tokenize(r.srca, byteconv.B2S(r.src))
+		r.tokenizePaths()
 		dst = append(dst, *r)
 		offset += len(ctl)
 		return dst, offset, false, err
@@ -404,16 +590,10 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 			r.condL, r.condR, r.condStaticL, r.condStaticR, r.condOp = p.parseCondExpr(reTernaryCondExpr, ctl)
 
 			raw, subset := extractSet(bytealg.Trim(m[5], space))
-			nodeTrue := node{typ: typeCondTrue, child: []node{{typ: typeOperator, dst: m[1], src: raw, subset: subset}}}
-			nodeTrue.dsta = tokenize(nodeTrue.dsta, byteconv.B2S(m[1]))
-			nodeTrue.srca = tokenize(nodeTrue.srca, byteconv.B2S(raw))
-			r.child = append(r.child, nodeTrue)
+			r.child = append(r.child, ternaryBranch(typeCondTrue, m[1], raw, subset))
 
 			raw, subset = extractSet(bytealg.Trim(m[6], space))
-			nodeFalse := node{typ: typeCondFalse, child: []node{{typ: typeOperator, dst: m[1], src: raw, subset: subset}}}
-			nodeFalse.dsta = tokenize(nodeTrue.dsta, byteconv.B2S(m[1]))
-			nodeFalse.srca = tokenize(nodeTrue.srca, byteconv.B2S(raw))
-			r.child = append(r.child, nodeFalse)
+			r.child = append(r.child, ternaryBranch(typeCondFalse, m[1], raw, subset))
 		} else if m = reTernaryHelper.FindSubmatch(ctl); m != nil {
 			r.typ = typeCond
 			r.condHlp = m[2]
@@ -423,51 +603,65 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 				r.condLC = lcLen
 			case bytes.Equal(r.condHlp, condCap):
 				r.condLC = lcCap
+			default:
+				if err = p.bindCondHlp(r, r.condHlp, r.condHlpArg, offset); err != nil {
+					return dst, offset, false, err
+				}
 			}
 
 			raw, subset := extractSet(bytealg.Trim(m[4], space))
-			nodeTrue := node{typ: typeCondTrue, child: []node{{typ: typeOperator, dst: m[1], src: raw, subset: subset}}}
-			nodeTrue.dsta = tokenize(nodeTrue.dsta, byteconv.B2S(m[1]))
-			nodeTrue.srca = tokenize(nodeTrue.srca, byteconv.B2S(raw))
-			r.child = append(r.child, nodeTrue)
+			r.child = append(r.child, ternaryBranch(typeCondTrue, m[1], raw, subset))
 
 			raw, subset = extractSet(bytealg.Trim(m[5], space))
-			nodeFalse := node{typ: typeCondFalse, child: []node{{typ: typeOperator, dst: m[1], src: raw, subset: subset}}}
-			nodeFalse.srca = tokenize(nodeFalse.srca, byteconv.B2S(m[1]))
-			nodeFalse.dsta = tokenize(nodeFalse.dsta, byteconv.B2S(raw))
-			r.child = append(r.child, nodeFalse)
+			r.child = append(r.child, ternaryBranch(typeCondFalse, m[1], raw, subset))
 		} else if m = reAssignF2V.FindSubmatch(ctl); m != nil {
 			// Func-to-var expression caught.
 			r.dst = m[1]
 			r.src = m[2]
 			// Parse getter callback.
 			fn := GetGetterFn(byteconv.B2S(m[2]))
+			f := p.getUDF(m[2])
+			if fn == nil && f != nil && !f.macro {
+				// User-defined function.
+				fn = f.getter()
+			}
+			if fn == nil && f == nil && GetModFn(byteconv.B2S(m[2])) == nil {
+				// Neither getter nor modifier found.
+				if fn, err = p.unknownGetter(m[2], offset); err != nil {
+					return dst, offset, false, err
+				}
+			}
 			if fn != nil {
 				r.getter = fn
 				r.arg = extractArgs(m[3])
+				if err = p.checkSig(getGetterSig(m[2]), "getter", m[2], r.arg, offset); err != nil {
+					return dst, offset, false, err
+				}
 			} else {
 				// Getter func not found, so try to fallback to mod func.
 				m = reAssignV2V.FindSubmatch(ctl)
-				r.src, r.mod = extractMods(m[2])
+				if r.src, r.mod, err = p.extractMods(m[2], offset); err != nil {
+					return dst, offset, false, err
+				}
 				r.src, r.subset = extractSet(r.src)
 			}
 			if r.getter == nil && len(r.mod) == 0 {
 				err = fmt.Errorf("unknown getter nor modifier function '%s' at offset %d", m[2], offset)
 				return dst, offset, false, err
 			}
-			r.dsta = tokenize(r.dsta, byteconv.B2S(r.dst))
-			r.srca = tokenize(r.srca, byteconv.B2S(r.src))
+			r.tokenizePaths()
 		} else if m = reAssignV2V.FindSubmatch(ctl); m != nil {
 			// Var-to-var ...
 			r.dst = m[1]
 			if r.static = isStatic(m[2]); r.static {
-				r.src = bytealg.Trim(m[2], quotes)
+				r.src = unquote(m[2])
 			} else {
-				r.src, r.mod = extractMods(m[2])
+				if r.src, r.mod, err = p.extractMods(m[2], offset); err != nil {
+					return dst, offset, false, err
+				}
 				r.src, r.subset = extractSet(r.src)
 			}
-			r.dsta = tokenize(r.dsta, byteconv.B2S(r.dst))
-			r.srca = tokenize(r.srca, byteconv.B2S(r.src))
+			r.tokenizePaths()
 		}
 		dst = append(dst, *r)
 		offset += len(ctl)
@@ -475,18 +669,31 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 	}
 	if reFunction.Match(ctl) {
 		ctl1 := reReplReset.ReplaceAll(ctl, replReset)
+		ctl1 = reReplDelete.ReplaceAll(ctl1, replDelete)
 		m := reFunction.FindSubmatch(ctl1)
 		// Function expression caught.
 		r.src = m[1]
-		r.srca = tokenize(r.srca, byteconv.B2S(r.src))
+		r.srca, r.srcDyn = tokenizePath(r.srca, r.src)
 		// Parse callback.
 		fn := GetCallbackFn(byteconv.B2S(m[1]))
+		if f := p.getUDF(m[1]); fn == nil && f != nil && f.macro {
+			// User-defined macro.
+			fn = f.callback()
+		}
+		if fn == nil {
+			if fn, err = p.unknownCallback(m[1], offset); err != nil {
+				return dst, offset, false, err
+			}
+		}
 		if fn == nil {
 			err = fmt.Errorf("unknown callback function '%s' at offset %d", m[1], offset)
 			return dst, offset, false, err
 		}
 		r.callback = fn
 		r.arg = extractArgs2(m[2], reReplReset.Match(ctl))
+		if err = p.checkSig(getCallbackSig(m[1]), "callback", m[1], r.arg, offset); err != nil {
+			return dst, offset, false, err
+		}
 
 		dst = append(dst, *r)
 		offset += len(ctl)
@@ -498,62 +705,113 @@ func (p *parser) processCtl(dst []node, root, r *node, ctl []byte, offset int) (
 func (p *parser) processCond(nodes []node, root *node, ctl []byte, offset int) ([]node, int, error) {
 	var (
 		subNodes []node
-		split    [][]node
 		err      error
 		pos      = offset
 	)
+	if err = p.parseCondHead(root, ctl, pos); err != nil {
+		return nodes, pos, err
+	}
+
+	// Create new target, increase condition counter and dive deeper.
+	t := p.targetSnapshot()
+	p.openBlock(typeCond)
+
+	subNodes, offset, err = p.parse(subNodes, &node{typ: typeCond}, pos+len(ctl), t)
+	condBranches(root, subNodes)
+	nodes = append(nodes, *root)
+	return nodes, offset, err
+}
+
+// Parse condition header (helper or comparison expression) to the root node.
+func (p *parser) parseCondHead(root *node, ctl []byte, offset int) error {
+	root.typ = typeCond
 	// Check complexity of the condition first.
 	if reCondComplex.Match(ctl) {
 		// Check if condition may be handled by the condition helper.
-		if m := reCondHelper.FindSubmatch(ctl); m != nil {
-			root.typ = typeCond
-			root.condHlp = m[1]
-			root.condHlpArg = extractArgs(m[2])
-			switch {
-			case bytes.Equal(root.condHlp, condLen):
-				root.condLC = lcLen
-			case bytes.Equal(root.condHlp, condCap):
-				root.condLC = lcCap
-			}
-
-			t := p.targetSnapshot()
-			p.cc++
-			subNodes, offset, err = p.parse(subNodes, root, pos+len(ctl), t)
-			split = splitNodes(subNodes)
-
-			if len(split) > 0 {
-				nodeTrue := node{typ: typeCondTrue, child: split[0]}
-				root.child = append(root.child, nodeTrue)
-			}
-			if len(split) > 1 {
-				nodeFalse := node{typ: typeCondFalse, child: split[1]}
-				root.child = append(root.child, nodeFalse)
+		m := reCondHelper.FindSubmatch(ctl)
+		if m == nil {
+			return fmt.Errorf("too complex condition '%s' at offset %d", ctl, offset)
+		}
+		root.condHlp = m[1]
+		root.condHlpArg = extractArgs(m[2])
+		switch {
+		case bytes.Equal(root.condHlp, condLen):
+			root.condLC = lcLen
+		case bytes.Equal(root.condHlp, condCap):
+			root.condLC = lcCap
+		default:
+			if err := p.bindCondHlp(root, root.condHlp, root.condHlpArg, offset); err != nil {
+				return err
 			}
-			nodes = append(nodes, *root)
-			return nodes, offset, err
 		}
-		return nodes, pos, fmt.Errorf("too complex condition '%s' at offset %d", ctl, pos)
+		return nil
 	}
-	root.typ = typeCond
 	root.condL, root.condR, root.condStaticL, root.condStaticR, root.condOp = p.parseCondExpr(reCondExpr, ctl)
+	return nil
+}
 
-	// Create new target, increase condition counter and dive deeper.
-	t := p.targetSnapshot()
-	p.cc++
-
-	subNodes, offset, err = p.parse(subNodes, &node{typ: typeCond}, pos+len(ctl), t)
-	split = splitNodes(subNodes)
-
-	if len(split) > 0 {
-		nodeTrue := node{typ: typeCondTrue, child: split[0]}
-		root.child = append(root.child, nodeTrue)
+// Parse control statements (break, lazybreak, continue and return).
+//
+// Returns true if ctl is a control statement.
+func (p *parser) processCtlStmt(r *node, ctl []byte, offset int) (bool, error) {
+	if bytes.Equal(ctl, ret) {
+		r.typ = typeReturn
+		return true, nil
 	}
-	if len(split) > 1 {
-		nodeFalse := node{typ: typeCondFalse, child: split[1]}
-		root.child = append(root.child, nodeFalse)
+	if m := reReturnVal.FindSubmatch(ctl); m != nil {
+		// Return value of user-defined function, eg: "return x|default("N/D")".
+		r.typ = typeReturn
+		if p.fn == nil || p.fn.macro {
+			return true, fmt.Errorf("%w: '%s' at offset %d", ErrReturnValue, ctl, offset)
+		}
+		if r.static = isStatic(m[1]); r.static {
+			r.src = unquote(m[1])
+		} else {
+			var err error
+			if r.src, r.mod, err = p.extractMods(m[1], offset); err != nil {
+				return true, err
+			}
+			r.src, r.subset = extractSet(r.src)
+		}
+		r.tokenizePaths()
+		return true, nil
 	}
-	nodes = append(nodes, *root)
-	return nodes, offset, err
+	m := reLoopCtl.FindSubmatch(ctl)
+	if m == nil {
+		return false, nil
+	}
+	switch {
+	case bytes.Equal(m[1], loopBrk):
+		r.typ = typeBreak
+	case bytes.Equal(m[1], loopLBrk):
+		r.typ = typeLBreak
+	default:
+		r.typ = typeContinue
+	}
+	if p.cl == 0 {
+		return true, fmt.Errorf("%w: '%s' at offset %d", ErrLoopCtlNoLoop, ctl, offset)
+	}
+	if len(m[2]) == 0 {
+		return true, nil
+	}
+	if reLoopDepth.Match(m[2]) {
+		// Legacy numeric depth, eg: "break 2".
+		i, _ := strconv.ParseInt(byteconv.B2S(m[2]), 10, 64)
+		if int(i) > len(p.lbl) {
+			return true, fmt.Errorf("%w: '%s' at offset %d", ErrLoopCtlNoLoop, ctl, offset)
+		}
+		r.loopBrkD = int(i)
+		return true, nil
+	}
+	// Resolve label to the depth of corresponding loop.
+	for i := len(p.lbl) - 1; i >= 0; i-- {
+		if bytes.Equal(p.lbl[i], m[2]) {
+			r.loopLbl = m[2]
+			r.loopBrkD = len(p.lbl) - i
+			return true, nil
+		}
+	}
+	return true, fmt.Errorf("%w: '%s' at offset %d", ErrUnknownLoopLabel, m[2], offset)
 }
 
 // Parse condition to left/right parts and condition operator.
@@ -573,10 +831,10 @@ func (p *parser) parseCondExpr(re *regexp.Regexp, expr []byte) (l, r []byte, sl,
 			op = p.parseOp(m[2])
 		}
 		if len(l) > 0 {
-			l = bytealg.Trim(l, quotes)
+			l = unquote(l)
 		}
 		if len(r) > 0 {
-			r = bytealg.Trim(r, quotes)
+			r = unquote(r)
 		}
 	}
 	return
@@ -632,6 +890,62 @@ func (p *parser) parseOp(src []byte) op {
 	return op_
 }
 
+// Make true and false branches of the condition from sub-nodes separated by divider node.
+func condBranches(root *node, sub []node) {
+	split := splitNodes(sub)
+	if len(split) > 0 {
+		root.child = append(root.child, node{typ: typeCondTrue, child: split[0]})
+	}
+	if len(split) > 1 {
+		root.child = append(root.child, node{typ: typeCondFalse, child: split[1]})
+	}
+}
+
+// Increase counter of blocks of given type on block opening.
+func (p *parser) openBlock(typ rtype) {
+	switch typ {
+	case typeLoopCount, typeLoopRange:
+		p.cl++
+	case typeCond, typeCondOK, typeElse, typeDiv:
+		p.cc++
+	case typeSwitch:
+		p.cs++
+	case typeWith:
+		p.cw++
+	case typeFunc:
+		p.cf++
+	}
+}
+
+// Decrease counter of blocks of given type on closing brace.
+func (p *parser) closeBlock(typ rtype) error {
+	switch typ {
+	case typeLoopCount, typeLoopRange:
+		p.cl--
+	case typeCond, typeCondOK, typeElse, typeDiv:
+		p.cc--
+	case typeSwitch:
+		p.cs--
+	case typeWith:
+		p.cw--
+	case typeFunc:
+		p.cf--
+	default:
+		return ErrUnexpectedClose
+	}
+	return nil
+}
+
+// Push label of the loop (may be empty) on loop opening.
+func (p *parser) pushLabel(lbl []byte) {
+	p.lbl = append(p.lbl, lbl)
+}
+
+// Pop label of the loop after loop closing.
+func (p *parser) popLabel() {
+	p.lbl = p.lbl[:len(p.lbl)-1]
+}
+
 // Split nodes by divider node.
 func splitNodes(nodes []node) [][]node {
 	if len(nodes) == 0 {
@@ -657,25 +971,28 @@ func (p *parser) targetSnapshot() *target {
 }
 
 // Split expression to variable and mods list.
-func extractMods(p []byte) ([]byte, []mod) {
-	hasVline := bytes.Contains(p, vline)
-	p = reReplAppend.ReplaceAll(p, replAppend)
-	hasSet := reSet.Match(p)
-	modNoVar := reFunction.Match(p) && !hasVline
+func (p *parser) extractMods(expr []byte, offset int) ([]byte, []mod, error) {
+	hasVline := bytes.Contains(expr, vline)
+	expr = reReplAppend.ReplaceAll(expr, replAppend)
+	hasSet := reSet.Match(expr)
+	modNoVar := reFunction.Match(expr) && !hasVline
 	if (hasVline && !hasSet) || modNoVar {
 		mods := make([]mod, 0)
-		chunks := bytes.Split(p, vline)
+		chunks := bytes.Split(expr, vline)
 		var idx = 1
 		if modNoVar {
 			idx = 0
 		}
 		for i := idx; i < len(chunks); i++ {
 			if m := reMod.FindSubmatch(chunks[i]); m != nil {
-				fn := GetModFn(byteconv.B2S(m[1]))
+				args := extractArgs(m[2])
+				fn, err := p.bindMod(m[1], args, offset)
+				if err != nil {
+					return expr, nil, err
+				}
 				if fn == nil {
 					continue
 				}
-				args := extractArgs(m[2])
 				mods = append(mods, mod{
 					id:  m[1],
 					fn:  fn,
@@ -684,12 +1001,27 @@ func extractMods(p []byte) ([]byte, []mod) {
 			}
 		}
 		if modNoVar {
-			return nil, mods
+			return nil, mods, nil
 		}
-		return chunks[0], mods
+		return chunks[0], mods, nil
 	} else {
-		return p, nil
+		return expr, nil, nil
+	}
+}
+
+// Bind modifier by name and check its arguments.
+//
+// Returns nil function without error if unknown modifier should be skipped (see ModeLax).
+func (p *parser) bindMod(name []byte, args []*arg, offset int) (ModFn, error) {
+	fn := GetModFn(byteconv.B2S(name))
+	if f := p.getUDF(name); fn == nil && f != nil && !f.macro {
+		// User-defined function.
+		fn = f.modifier()
 	}
+	if fn == nil {
+		return p.unknownMod(name, offset)
+	}
+	return fn, p.checkSig(getModSig(name), "modifier", name, args, offset)
 }
 
 // Get list of arguments of modifier or callback, ex:
@@ -714,19 +1046,21 @@ func extractArgs2(l []byte, forceReplQB bool) []*arg {
 		var set [][]byte
 		a = bytealg.Trim(a, space)
 		static := isStatic(a)
+		kind := argKindOf(a)
 		if !static || forceReplQB {
 			if forceReplQB {
 				a = bytealg.Trim(a, quotes)
 			}
 			a, set = extractSet(a)
 		} else {
-			a = bytealg.Trim(a, quotes)
+			a = unquote(a)
 		}
 		arg_ := &arg{
 			val:    a,
 			subset: set,
 			static: static,
 			global: GetGlobal(byteconv.B2S(a)) != nil,
+			kind:   kind,
 		}
 		r = append(r, arg_)
 	}
@@ -741,6 +1075,13 @@ func extractSet(p []byte) ([]byte, [][]byte) {
 	return p, nil
 }
 
+// Build branch node of ternary operator.
+func ternaryBranch(typ rtype, dst, src []byte, subset [][]byte) node {
+	op := node{typ: typeOperator, dst: dst, src: src, subset: subset}
+	op.tokenizePaths()
+	return node{typ: typ, child: []node{op}}
+}
+
 func rollupSwitchNodes(nodes []node) []node {
 	if len(nodes) == 0 {
 		return nil
@@ -0,0 +1,116 @@
+package decoder
+
+import (
+	"fmt"
+
+	"github.com/koykov/byteconv"
+)
+
+// ParseOption customizes parsing of decoder rules, see ParseMode and Flags.
+type ParseOption interface {
+	apply(p *parser)
+}
+
+// ParseMode describes how parser handles unknown functions (modifiers, getters, callbacks and condition helpers).
+type ParseMode int
+
+const (
+	// ModeStrict reports unknown functions as parse errors. Default mode.
+	ModeStrict ParseMode = iota
+	// ModeLax skips unknown modifiers and resolves condition helpers at decode time. Backward compatible mode.
+	ModeLax
+	// ModeLazy resolves unknown functions at decode time, thus they may be registered after parsing (eg: by plugins).
+	// Decoding fails if function still isn't registered.
+	ModeLazy
+)
+
+func (m ParseMode) apply(p *parser) {
+	p.mode = m
+}
+
+func (f Flags) apply(p *parser) {
+	if p.flags == nil {
+		p.flags = make(Flags, len(f))
+	}
+	for k, v := range f {
+		p.flags[k] = p.flags[k] || v
+	}
+}
+
+// Get modifier to use with unknown name.
+//
+// Returns nil in lax mode and function that resolves the modifier on every call in lazy mode.
+func (p *parser) unknownMod(name []byte, offset int) (ModFn, error) {
+	switch p.mode {
+	case ModeLax:
+		return nil, nil
+	case ModeLazy:
+		key := string(name)
+		return func(ctx *Ctx, buf *any, val any, args []any) error {
+			fn := GetModFn(key)
+			if fn == nil {
+				return fmt.Errorf("%w: '%s'", ErrModNotFound, key)
+			}
+			return fn(ctx, buf, val, args)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: '%s' at offset %d", ErrModNotFound, name, offset)
+	}
+}
+
+// Get getter to use with unknown name, see unknownMod().
+func (p *parser) unknownGetter(name []byte, offset int) (GetterFn, error) {
+	switch p.mode {
+	case ModeLax:
+		return nil, nil
+	case ModeLazy:
+		key := string(name)
+		return func(ctx *Ctx, buf *any, args []any) error {
+			fn := GetGetterFn(key)
+			if fn == nil {
+				return fmt.Errorf("%w: '%s'", ErrGetterNotFound, key)
+			}
+			return fn(ctx, buf, args)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: '%s' at offset %d", ErrGetterNotFound, name, offset)
+	}
+}
+
+// Get callback to use with unknown name, see unknownMod().
+func (p *parser) unknownCallback(name []byte, offset int) (CallbackFn, error) {
+	switch p.mode {
+	case ModeLax:
+		return nil, nil
+	case ModeLazy:
+		key := string(name)
+		return func(ctx *Ctx, args []any) error {
+			fn := GetCallbackFn(key)
+			if fn == nil {
+				return fmt.Errorf("%w: '%s'", ErrCallbackNotFound, key)
+			}
+			return fn(ctx, args)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: '%s' at offset %d", ErrCallbackNotFound, name, offset)
+	}
+}
+
+// Bind condition (or condition-OK) helper to the node.
+//
+// Unknown helpers resolves at decode time in lax and lazy modes.
+func (p *parser) bindCondHlp(r *node, name []byte, args []*arg, offset int) error {
+	key := byteconv.B2S(name)
+	if r.typ == typeCondOK {
+		r.condOKFn = GetCondOKFn(key)
+	} else {
+		r.condFn = GetCondFn(key)
+	}
+	if r.condFn != nil || r.condOKFn != nil {
+		return p.checkSig(getCondSig(name, r.typ == typeCondOK), "condition helper", name, args, offset)
+	}
+	if p.mode != ModeStrict {
+		return nil
+	}
+	return fmt.Errorf("%w: '%s' at offset %d", ErrCondHlpNotFound, name, offset)
+}
@@ -2,20 +2,26 @@ package decoder
 
 // Target is a storage of depths needed to provide proper out from conditions, loops and switches control structures.
 type target struct {
-	// Counters (depths) of conditions, loops and switches.
-	cc, cl, cs int
+	// Counters (depths) of conditions, loops, switches, with blocks, user-defined functions and preprocessor blocks.
+	cc, cl, cs, cw, cf, cp int
 }
 
 // Check if parser reached the target.
 func (t *target) reached(p *parser) bool {
 	return t.cc == p.cc &&
 		t.cl == p.cl &&
-		t.cs == p.cs
+		t.cs == p.cs &&
+		t.cw == p.cw &&
+		t.cf == p.cf &&
+		t.cp == p.cp
 }
 
 // Check if target is a root.
 func (t *target) eqZero() bool {
 	return t.cc == 0 &&
 		t.cl == 0 &&
-		t.cs == 0
+		t.cs == 0 &&
+		t.cw == 0 &&
+		t.cf == 0 &&
+		t.cp == 0
 }
@@ -2,6 +2,8 @@ package decoder
 
 import (
 	"bytes"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -11,6 +13,7 @@ func TestParser(t *testing.T) {
 	t.Run("v2v2", testParser)
 	t.Run("f2v0", testParser)
 	t.Run("v2c0", testParser)
+	t.Run("quotes", testParser)
 	t.Run("v2ci0", testParser)
 	t.Run("v2new", testParser)
 	t.Run("v2append", testParser)
@@ -22,22 +25,196 @@ func TestParser(t *testing.T) {
 	t.Run("loop_break", testParser)
 	t.Run("loop_lazybreak", testParser)
 	t.Run("loop_continue", testParser)
+	t.Run("loop_label", testParser)
+	t.Run("path_dynamic", testParser)
+	t.Run("push", testParser)
+	t.Run("map", testParser)
+	t.Run("automap", testParser)
+	t.Run("decode", testParser)
+	t.Run("extends", testParser)
+	t.Run("udf", testParser)
+	t.Run("const", testParser)
+	t.Run("preproc", testParser)
 
 	t.Run("cond", testParser)
 	t.Run("cond_else", testParser)
-	t.Run("cond_helper", testParser)
-	t.Run("condOK", testParser)
-	t.Run("condNotOK", testParser)
+	t.Run("cond_helper", testParserLax)
+	t.Run("condOK", testParserLax)
+	t.Run("condNotOK", testParserLax)
 
 	t.Run("switch", testParser)
 	t.Run("switch_no_cond", testParser)
-	t.Run("switch_no_cond_helper", testParser)
+	t.Run("switch_no_cond_helper", testParserLax)
 
 	t.Run("ternary", testParser)
-	t.Run("ternary_helper", testParser)
+	t.Run("ternary_helper", testParserLax)
+
+	t.Run("return", testParser)
+	t.Run("return_if", testParser)
 }
 
-func testParser(t *testing.T) {
+func TestParserError(t *testing.T) {
+	t.Run("break_outside_loop", func(t *testing.T) {
+		_, err := Parse([]byte("obj.Id = jso.identifier\nbreak"))
+		if !errors.Is(err, ErrLoopCtlNoLoop) {
+			t.Errorf("expected error %s, got %v", ErrLoopCtlNoLoop, err)
+		}
+	})
+	t.Run("unknown_label", func(t *testing.T) {
+		_, err := Parse([]byte("outer: for i:=0; i<10; i++ {\n  break inner\n}"))
+		if !errors.Is(err, ErrUnknownLoopLabel) {
+			t.Errorf("expected error %s, got %v", ErrUnknownLoopLabel, err)
+		}
+	})
+	t.Run("duplicate_label", func(t *testing.T) {
+		_, err := Parse([]byte("outer: for i:=0; i<10; i++ {\n  outer: for j:=0; j<10; j++ {\n    break outer\n  }\n}"))
+		if !errors.Is(err, ErrDupLoopLabel) {
+			t.Errorf("expected error %s, got %v", ErrDupLoopLabel, err)
+		}
+	})
+	t.Run("continue_outside_loop", func(t *testing.T) {
+		_, err := Parse([]byte("if jso.id == 1 {\n  continue if jso.id == 2\n}"))
+		if !errors.Is(err, ErrLoopCtlNoLoop) {
+			t.Errorf("expected error %s, got %v", ErrLoopCtlNoLoop, err)
+		}
+	})
+	t.Run("extends_not_top", func(t *testing.T) {
+		_, err := Parse([]byte("dst.Id = src.id\nextends \"base\""))
+		if !errors.Is(err, ErrExtendsNotTop) {
+			t.Errorf("expected error %s, got %v", ErrExtendsNotTop, err)
+		}
+	})
+	t.Run("return_value_outside_func", func(t *testing.T) {
+		_, err := Parse([]byte("macro m(x) {\n  return x\n}"))
+		if !errors.Is(err, ErrReturnValue) {
+			t.Errorf("expected error %s, got %v", ErrReturnValue, err)
+		}
+	})
+	t.Run("func_not_top", func(t *testing.T) {
+		_, err := Parse([]byte("if jso.id == 1 {\n  func f(x) {\n    return x\n  }\n}"))
+		if !errors.Is(err, ErrFuncNotTop) {
+			t.Errorf("expected error %s, got %v", ErrFuncNotTop, err)
+		}
+	})
+	t.Run("func_redeclared", func(t *testing.T) {
+		_, err := Parse([]byte("func f(x) {\n  return x\n}\nmacro f(x) {\n  x.Id = 1\n}"))
+		if !errors.Is(err, ErrFuncRedeclared) {
+			t.Errorf("expected error %s, got %v", ErrFuncRedeclared, err)
+		}
+	})
+	t.Run("const_no_static", func(t *testing.T) {
+		_, err := Parse([]byte("const (\n  Status = jso.status\n)"))
+		if !errors.Is(err, ErrConstNoStatic) {
+			t.Errorf("expected error %s, got %v", ErrConstNoStatic, err)
+		}
+	})
+	t.Run("const_redeclared", func(t *testing.T) {
+		_, err := Parse([]byte("const (\n  Status = 1\n  Status = 2\n)"))
+		if !errors.Is(err, ErrConstRedeclared) {
+			t.Errorf("expected error %s, got %v", ErrConstRedeclared, err)
+		}
+	})
+	t.Run("const_unclosed", func(t *testing.T) {
+		_, err := Parse([]byte("const (\n  Sep = \")\"\n"))
+		if !errors.Is(err, ErrConstMalformed) {
+			t.Errorf("expected error %s, got %v", ErrConstMalformed, err)
+		}
+	})
+	t.Run("unexpected_dir", func(t *testing.T) {
+		_, err := Parse([]byte("#if flag(\"x\")\nif jso.id == 1 {\n#endif\n  obj.Id = jso.id\n}"))
+		if !errors.Is(err, ErrUnexpectedDir) {
+			t.Errorf("expected error %s, got %v", ErrUnexpectedDir, err)
+		}
+	})
+	t.Run("unknown_func", func(t *testing.T) {
+		for _, c := range []struct {
+			body string
+			err  error
+		}{
+			{"obj.Id = jso.id\nobj.Name = jso.name|defualt(\"N/D\")", ErrModNotFound},
+			{"obj.Name = nameOf(jso.person)", ErrGetterNotFound},
+			{"notifyUser(jso.id)", ErrCallbackNotFound},
+			{"if isVIP(jso.person) {\n  obj.Status = 1\n}", ErrCondHlpNotFound},
+			{"if x, ok := vipStatus(jso.person); ok {\n  obj.Status = x\n}", ErrCondHlpNotFound},
+			{"switch {\ncase isVIP(jso.person):\n  obj.Status = 1\n}", ErrCondHlpNotFound},
+		} {
+			if _, err := Parse([]byte(c.body)); !errors.Is(err, c.err) {
+				t.Errorf("expected error %s, got %v", c.err, err)
+			}
+		}
+		if _, err := Parse([]byte("obj.Id = jso.id\nobj.Name = jso.name|defualt(\"N/D\")")); err == nil || !strings.Contains(err.Error(), "at offset 16") {
+			t.Errorf("expected error with position, got %v", err)
+		}
+		// Lax mode skips unknown modifiers.
+		tree, err := Parse([]byte("obj.Name = jso.name|defualt(\"N/D\")"), ModeLax)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tree.nodes) != 1 || len(tree.nodes[0].mod) != 0 {
+			t.Error("unknown modifier must be skipped in lax mode")
+		}
+	})
+	t.Run("signature", func(t *testing.T) {
+		RegisterModFnNS("testns", "sigMod", "", func(_ *Ctx, _ *any, _ any, _ []any) error { return nil }).
+			WithSignature(Signature{Min: 1, Variadic: true, Args: []ArgKind{ArgString, ArgNumber}})
+		for _, body := range []string{
+			"obj.Name = jso.person.full_name|default()",
+			"obj.Status = strToInt(jso.person.status, 10)",
+			"reset(obj.Name, obj.Id)",
+			"obj.Id = jso.identifier|testns::sigMod(jso.person.status)",
+			"obj.Id = jso.identifier|testns::sigMod(\"x\", 1, \"2\")",
+			"obj.Id = jso.identifier|new(15)",
+		} {
+			if _, err := Parse([]byte(body)); !errors.Is(err, ErrSignature) {
+				t.Errorf("expected error %s, got %v", ErrSignature, err)
+			}
+		}
+		for _, body := range []string{
+			"obj.Id = jso.identifier|testns::sigMod(\"x\")",
+			"obj.Id = jso.identifier|testns::sigMod('x', -1, 3.1415)",
+			"obj.Id = jso.identifier|testns::sigMod(time::Layout)",
+		} {
+			if _, err := Parse([]byte(body)); err != nil {
+				t.Error(err)
+			}
+		}
+		// Lax mode doesn't check signatures.
+		if _, err := Parse([]byte("obj.Name = jso.person.full_name|default()"), ModeLax); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("switch_no_cond_helper_strict", func(t *testing.T) {
+		st := getStage("parser/switch_no_cond_helper_strict")
+		if st == nil {
+			t.Fatal("stage not found")
+		}
+		if _, err := Parse(st.origin); !errors.Is(err, ErrCondHlpNotFound) {
+			t.Errorf("expected error %s, got %v", ErrCondHlpNotFound, err)
+		}
+		if _, err := Parse(st.origin, ModeLax); err != nil {
+			t.Errorf("unexpected error in lax mode: %v", err)
+		}
+	})
+	t.Run("automap_strategy", func(t *testing.T) {
+		_, err := Parse([]byte("automap(obj, jso.person) strategy(kebab)"))
+		if !errors.Is(err, ErrAutomapOpt) {
+			t.Errorf("expected error %s, got %v", ErrAutomapOpt, err)
+		}
+	})
+	t.Run("automap_option", func(t *testing.T) {
+		_, err := Parse([]byte("automap(obj, jso.person) only(Id)"))
+		if !errors.Is(err, ErrAutomapOpt) {
+			t.Errorf("expected error %s, got %v", ErrAutomapOpt, err)
+		}
+	})
+}
+
+func testParser(t *testing.T) { testParserWith(t) }
+
+// Test fixtures with unknown functions, they must still parse in lax mode.
+func testParserLax(t *testing.T) { testParserWith(t, ModeLax) }
+
+func testParserWith(t *testing.T, opts ...ParseOption) {
 	key := getTBName(t)
 	st := getStage("parser/" + key)
 	if st == nil {
@@ -45,7 +222,7 @@ func testParser(t *testing.T) {
 		return
 	}
 	if len(st.expect) > 0 {
-		rs, err := Parse(st.origin)
+		rs, err := Parse(st.origin, opts...)
 		if err != nil {
 			t.Error(err)
 		}
@@ -0,0 +1,207 @@
+package decoder
+
+import (
+	"bytes"
+
+	"github.com/koykov/byteconv"
+	"github.com/koykov/x2bytes"
+)
+
+// Split path to tokens during parsing.
+//
+// Returns nil tokens and true if path contains dynamic indexes/keys (eg: items[i], attrs[src.key]), such paths must be
+// resolved in runtime using Ctx.splitPath().
+func tokenizePath(dst []string, path []byte) ([]string, bool) {
+	if isDynPath(path) {
+		return dst[:0], true
+	}
+	return tokenize(dst, byteconv.B2S(path)), false
+}
+
+// Split destination and source paths of the node.
+//
+// Destination path with empty square brackets at the end (eg: "dst.Items[]") marks the node as push node.
+func (r *node) tokenizePaths() {
+	if bytes.HasSuffix(r.dst, qbE) {
+		r.dst, r.push = r.dst[:len(r.dst)-len(qbE)], true
+	}
+	r.dsta, r.dstDyn = tokenizePath(r.dsta, r.dst)
+	if !r.static {
+		r.srca, r.srcDyn = tokenizePath(r.srca, r.src)
+	} else {
+		r.srca = tokenize(r.srca, byteconv.B2S(r.src))
+	}
+}
+
+// Check if path contains square brackets with non-numeric content.
+func isDynPath(path []byte) bool {
+	for {
+		i := bytes.IndexByte(path, '[')
+		if i == -1 {
+			return false
+		}
+		j := indexQB(path, i)
+		if j == -1 {
+			return false
+		}
+		if !isDigits(path[i+1 : j]) {
+			return true
+		}
+		path = path[j+1:]
+	}
+}
+
+// Get position of close square bracket corresponding to open bracket at position i.
+func indexQB(path []byte, i int) int {
+	var d int
+	for j := i; j < len(path); j++ {
+		switch path[j] {
+		case '[':
+			d++
+		case ']':
+			if d--; d == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// Check if p contains only digits.
+func isDigits(p []byte) bool {
+	if len(p) == 0 {
+		return false
+	}
+	for i := 0; i < len(p); i++ {
+		if p[i] < '0' || p[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Split path to tokens and resolve dynamic indexes and keys.
+//
+// Square brackets in path may contain an expression that evaluates in runtime, eg:
+// * items[i]
+// * attrs[src.key]
+// * list[src.map[src.key]].title
+// If expression can't be resolved (context doesn't contain such variable or inspector fails to get it) it uses as
+// a literal key to keep compatibility with paths like "obj.Flags[read]". Quoted keys (eg: attrs["x-internal"]) are
+// always literal.
+// Flag lv indicates that path is an lvalue. In that case resolved non-numeric keys are copied to make them safe to store
+// in destination map.
+func (ctx *Ctx) splitPath(dst []string, path []byte, lv bool) []string {
+	if bytes.IndexByte(path, '[') == -1 {
+		return tokenize(dst, byteconv.B2S(path))
+	}
+	if ctx.pd == 0 {
+		ctx.bufKB = ctx.bufKB[:0]
+	}
+	var o int
+	n := len(path)
+	for i := 0; i < n; i++ {
+		switch path[i] {
+		case '.', '@':
+			if i > o {
+				dst = append(dst, byteconv.B2S(path[o:i]))
+			}
+			o = i + 1
+		case '[':
+			if i > o {
+				dst = append(dst, byteconv.B2S(path[o:i]))
+			}
+			j := indexQB(path, i)
+			if j == -1 {
+				// Unbalanced brackets, use tail as is.
+				return tokenize(dst, byteconv.B2S(path[i+1:]))
+			}
+			dst = append(dst, ctx.evalPathIdx(path[i+1:j], lv))
+			i, o = j, j+1
+		}
+	}
+	if o < n {
+		dst = append(dst, byteconv.B2S(path[o:]))
+	}
+	return dst
+}
+
+// Evaluate expression inside square brackets to string key.
+func (ctx *Ctx) evalPathIdx(expr []byte, lv bool) string {
+	expr = bytes.TrimSpace(expr)
+	if len(expr) == 0 {
+		return ""
+	}
+	if isDigits(expr) {
+		return byteconv.B2S(expr)
+	}
+	if c := expr[0]; (c == '"' || c == '\'' || c == '`') && len(expr) > 1 && expr[len(expr)-1] == c {
+		return byteconv.B2S(expr[1 : len(expr)-1])
+	}
+
+	// Use separate tokens buffer on each nesting level.
+	ctx.pd++
+	for len(ctx.bufPS) < ctx.pd {
+		ctx.bufPS = append(ctx.bufPS, nil)
+	}
+	path := ctx.splitPath(ctx.bufPS[ctx.pd-1][:0], expr, false)
+	ctx.bufPS[ctx.pd-1] = path
+	var (
+		val any
+		ok  bool
+	)
+	if len(path) > 0 && ctx.hasVar(path[0]) {
+		err := ctx.Err
+		val, _ = ctx.get2(path, nil)
+		if ok = ctx.Err == nil; !ok {
+			// Expression falls back to literal key, so its error mustn't affect the path.
+			ctx.Err = err
+		}
+	}
+	ctx.pd--
+	if !ok || val == nil {
+		// Unknown variable - use expression as literal key.
+		return byteconv.B2S(expr)
+	}
+
+	off := len(ctx.bufKB)
+	var err error
+	if ctx.bufKB, err = x2bytes.ToBytes(ctx.bufKB, val); err != nil {
+		ctx.bufKB = ctx.bufKB[:off]
+		return byteconv.B2S(expr)
+	}
+	key := ctx.bufKB[off:]
+	if lv && !isDigits(key) {
+		// Key may be stored in destination map, so make a copy.
+		return string(key)
+	}
+	return byteconv.B2S(key)
+}
+
+// Check if context contains variable with given key.
+func (ctx *Ctx) hasVar(key string) bool {
+	for i := 0; i < ctx.ln; i++ {
+		if ctx.vars[i].key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Get destination path of the node.
+func (ctx *Ctx) dstPath(r *node) []string {
+	if !r.dstDyn {
+		return r.dsta
+	}
+	ctx.bufDP = ctx.splitPath(ctx.bufDP[:0], r.dst, true)
+	return ctx.bufDP
+}
+
+// Get source path of the node.
+func (ctx *Ctx) srcPath(r *node) []string {
+	if !r.srcDyn {
+		return r.srca
+	}
+	ctx.bufSP = ctx.splitPath(ctx.bufSP[:0], r.src, false)
+	return ctx.bufSP
+}
@@ -0,0 +1,77 @@
+package decoder
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/koykov/byteconv"
+)
+
+// Flags represents set of feature flags to resolve preprocessor directives at parse time.
+type Flags map[string]bool
+
+var (
+	reDir      = regexp.MustCompile(`^#(if|else|endif)\b`)
+	reDirIf    = regexp.MustCompile(`^#if\s+flag\(\s*["']([^"']+)["']\s*\)\s*$`)
+	reDirElse  = regexp.MustCompile(`^#else\s*$`)
+	reDirEndif = regexp.MustCompile(`^#endif\s*$`)
+)
+
+// Process preprocessor directive, eg: "#if flag("new_pricing")", "#else" or "#endif".
+//
+// Rules between "#if" and "#endif" directives must be complete, thus directives can't break control structures.
+func (p *parser) processDir(dst []node, root *node, ctl []byte, offset int) ([]node, int, bool, error) {
+	var err error
+	if m := reDirIf.FindSubmatch(ctl); m != nil {
+		t := p.targetSnapshot()
+		p.cp++
+
+		var subNodes []node
+		offset += len(ctl)
+		if subNodes, offset, err = p.parse(subNodes, &node{typ: typeFlag}, offset, t); err != nil {
+			return dst, offset, false, err
+		}
+		var branch [2][]node
+		for i, split := range splitNodes(subNodes) {
+			branch[i] = split
+		}
+		if p.flags != nil {
+			// Flags are known, so keep only the rules of the actual branch.
+			i := 1
+			if p.flags[byteconv.B2S(m[1])] {
+				i = 0
+			}
+			dst = append(dst, branch[i]...)
+			return dst, offset, false, nil
+		}
+		r := node{
+			typ:  typeFlag,
+			flag: m[1],
+			child: []node{
+				{typ: typeCondTrue, child: branch[0]},
+				{typ: typeCondFalse, child: branch[1]},
+			},
+		}
+		dst = append(dst, r)
+		return dst, offset, false, nil
+	}
+	if root == nil || root.typ != typeFlag {
+		return dst, offset, false, fmt.Errorf("%w '%s' at offset %d", ErrUnexpectedDir, ctl, offset)
+	}
+	if reDirElse.Match(ctl) {
+		for i := 0; i < len(dst); i++ {
+			if dst[i].typ == typeDiv {
+				return dst, offset, false, fmt.Errorf("%w '%s' at offset %d", ErrUnexpectedDir, ctl, offset)
+			}
+		}
+		dst = append(dst, node{typ: typeDiv})
+		offset += len(ctl)
+		return dst, offset, false, nil
+	}
+	if reDirEndif.Match(ctl) {
+		p.cp--
+		offset += len(ctl)
+		return dst, offset, true, nil
+	}
+	return dst, offset, false, fmt.Errorf("%w '%s' at offset %d", ErrUnexpectedDir, ctl, offset)
+}
@@ -0,0 +1,217 @@
+package decoder
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+
+	"github.com/koykov/byteconv"
+)
+
+var (
+	// Lock of functions and globals registries.
+	regMux sync.RWMutex
+	// Generation of registries, increases on every registration of new function or global.
+	// Accessed atomically, so checking of generation doesn't take the lock of registries.
+	regGen uint64
+)
+
+// Get current generation of registries.
+func regGeneration() uint64 {
+	return atomic.LoadUint64(&regGen)
+}
+
+// Increase generation of registries.
+func nextRegGen() {
+	atomic.AddUint64(&regGen, 1)
+}
+
+// Rebind re-resolves functions (modifiers, getters, callbacks, condition helpers) and globals in all registered
+// decoders.
+//
+// There is no need to call it manually since decoders rebinds on the first use after new function or global
+// registration. Decoders running at the moment of rebinding continue to use old bindings.
+func Rebind() {
+	decDB.rebind(regGeneration())
+}
+
+// Check generation of registries and rebind decoders if needed.
+func (db *db) checkGen() {
+	if gen := regGeneration(); gen != atomic.LoadUint64(&db.gen) {
+		db.rebind(gen)
+	}
+}
+
+// Rebind all decoders to given generation of registries.
+func (db *db) rebind(gen uint64) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	if atomic.LoadUint64(&db.gen) == gen {
+		return
+	}
+	var dirty bool
+	for i := 0; i < len(db.buf); i++ {
+		dirty = db.rebindLF(i, gen, 0) || dirty
+	}
+	if dirty {
+		for i := 0; i < len(db.buf); i++ {
+			dec := db.buf[i]
+			if len(dec.orig.parent) == 0 {
+				continue
+			}
+			cpy := Decoder{
+				ID:   dec.ID,
+				Key:  dec.Key,
+				orig: dec.orig,
+			}
+			cpy.tree, cpy.err = db.inheritLF(dec.Key, dec.orig)
+			db.buf[i] = &cpy
+		}
+		// Apply rebound parents to the whole inheritance chains.
+		for i := 0; i < len(db.buf); i++ {
+			if len(db.buf[i].orig.parent) == 0 {
+				db.rebuildLF(db.buf[i].Key, 0)
+			}
+		}
+	}
+	atomic.StoreUint64(&db.gen, gen)
+}
+
+// Rebind decoder with given index to given generation of registries.
+//
+// Imported libraries and parent are rebound first, thus functions of the decoder bind to actual functions of them.
+func (db *db) rebindLF(i int, gen uint64, depth int) bool {
+	dec := db.buf[i]
+	if dec.orig.gen == gen || depth > len(db.buf) {
+		return false
+	}
+	var imp []*udf
+	for _, key := range dec.orig.imports {
+		if j, ok := db.idxKey[key]; ok && j >= 0 && j < len(db.buf) {
+			db.rebindLF(j, gen, depth+1)
+			imp = append(imp, db.buf[j].orig.udfs...)
+		}
+	}
+	if j, ok := db.idxKey[dec.orig.parent]; ok && j >= 0 && j < len(db.buf) && j != i {
+		// Functions of parent are available after imported ones, see parser.getUDF().
+		db.rebindLF(j, gen, depth+1)
+		imp = append(imp, db.buf[j].orig.udfs...)
+	}
+	// Decoder objects are immutable since they may be in use, so make a new one.
+	cpy := Decoder{
+		ID:   dec.ID,
+		Key:  dec.Key,
+		orig: rebindTree(dec.orig, gen, imp),
+	}
+	cpy.tree = cpy.orig
+	db.buf[i] = &cpy
+	return true
+}
+
+// Make a copy of the tree with re-resolved functions and globals.
+//
+// imp contains functions of imported libraries.
+func rebindTree(tree *Tree, gen uint64, imp []*udf) *Tree {
+	cpy := *tree
+	cpy.gen = gen
+	if len(tree.udfs) > 0 {
+		// Own functions may call each other, so bind them in declaration order.
+		cpy.udfs = make([]*udf, 0, len(tree.udfs))
+		for i := 0; i < len(tree.udfs); i++ {
+			f := *tree.udfs[i]
+			f.body = rebindNodes(f.body, cpy.udfs, imp)
+			cpy.udfs = append(cpy.udfs, &f)
+		}
+	}
+	cpy.nodes = rebindNodes(tree.nodes, cpy.udfs, imp)
+	return &cpy
+}
+
+// Make a copy of nodes with re-resolved functions and globals.
+//
+// Functions of the registries have priority over own user-defined functions udfs and functions of imported libraries
+// imp, the same as at parse time.
+func rebindNodes(nodes Ruleset, udfs, imp []*udf) Ruleset {
+	if nodes == nil {
+		return nil
+	}
+	r := make(Ruleset, len(nodes))
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		if len(n.mod) > 0 {
+			n.mod = append([]mod(nil), n.mod...)
+			for j := 0; j < len(n.mod); j++ {
+				m := &n.mod[j]
+				if fn := GetModFn(byteconv.B2S(m.id)); fn != nil {
+					m.fn = fn
+				} else if f := findUDF(udfs, imp, m.id); f != nil && !f.macro {
+					m.fn = f.modifier()
+				}
+				m.arg = rebindArgs(m.arg)
+			}
+		}
+		if n.getter != nil {
+			if fn := GetGetterFn(byteconv.B2S(n.src)); fn != nil {
+				n.getter = fn
+			} else if f := findUDF(udfs, imp, n.src); f != nil && !f.macro {
+				n.getter = f.getter()
+			}
+		}
+		if n.callback != nil {
+			if fn := GetCallbackFn(byteconv.B2S(n.src)); fn != nil {
+				n.callback = fn
+			} else if f := findUDF(udfs, imp, n.src); f != nil && f.macro {
+				n.callback = f.callback()
+			}
+		}
+		switch {
+		case n.typ == typeCondOK && len(n.condHlp) > 0:
+			if fn := GetCondOKFn(byteconv.B2S(n.condHlp)); fn != nil {
+				n.condOKFn = fn
+			}
+		case n.typ == typeCond && len(n.condHlp) > 0 && n.condLC == lcNone:
+			if fn := GetCondFn(byteconv.B2S(n.condHlp)); fn != nil {
+				n.condFn = fn
+			}
+		case n.typ == typeCase && len(n.caseHlp) > 0:
+			if fn := GetCondFn(byteconv.B2S(n.caseHlp)); fn != nil {
+				n.condFn = fn
+			}
+		}
+		n.arg = rebindArgs(n.arg)
+		n.condHlpArg = rebindArgs(n.condHlpArg)
+		n.caseHlpArg = rebindArgs(n.caseHlpArg)
+		n.child = rebindNodes(n.child, udfs, imp)
+		r[i] = n
+	}
+	return r
+}
+
+// Make a copy of arguments with actual global flags.
+func rebindArgs(args []*arg) []*arg {
+	if args == nil {
+		return nil
+	}
+	r := make([]*arg, len(args))
+	for i := 0; i < len(args); i++ {
+		a := *args[i]
+		a.global = GetGlobal(byteconv.B2S(a.val)) != nil
+		r[i] = &a
+	}
+	return r
+}
+
+// Find user-defined function by name in own functions and then in imported ones.
+func findUDF(udfs, imp []*udf, name []byte) *udf {
+	for i := 0; i < len(udfs); i++ {
+		if bytes.Equal(udfs[i].name, name) {
+			return udfs[i]
+		}
+	}
+	for i := 0; i < len(imp); i++ {
+		if bytes.Equal(imp[i].name, name) {
+			return imp[i]
+		}
+	}
+	return nil
+}
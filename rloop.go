@@ -48,32 +48,32 @@ func (rl *RangeLoop) SetVal(val any, ins inspector.Inspector) {
 
 // Iterate performs the iteration.
 func (rl *RangeLoop) Iterate() inspector.LoopCtl {
-	if rl.ctx.brkD > 0 {
+	if rl.ctx.ret {
 		return inspector.LoopCtlBrk
 	}
 
 	rl.cntr++
-	var err, lerr error
+	var lerr error
 	for i := 0; i < len(rl.n.child); i++ {
 		ch := &rl.n.child[i]
-		err = followRule(ch, rl.ctx)
-		if err == ErrLBreakLoop {
+		switch err := followRule(ch, rl.ctx); err {
+		case ErrLBreakLoop:
 			lerr = err
-		}
-		if err == ErrBreakLoop {
-			if rl.ctx.brkD > 0 {
-				rl.ctx.brkD--
-			}
+		case ErrBreakLoop:
+			rl.ctx.loopSignalOuter(err)
 			return inspector.LoopCtlBrk
-		}
-		if err == ErrContLoop {
+		case ErrContLoop:
+			if rl.ctx.loopSignalOuter(err) {
+				return inspector.LoopCtlBrk
+			}
 			return inspector.LoopCtlCnt
+		case ErrReturn:
+			rl.ctx.ret = true
+			return inspector.LoopCtlBrk
 		}
 	}
-	if err == ErrBreakLoop || lerr == ErrLBreakLoop {
-		if rl.ctx.brkD > 0 {
-			rl.ctx.brkD--
-		}
+	if lerr != nil {
+		rl.ctx.loopSignalOuter(lerr)
 		return inspector.LoopCtlBrk
 	}
 	return inspector.LoopCtlNone
@@ -0,0 +1,86 @@
+package decoder
+
+import (
+	"reflect"
+
+	"github.com/koykov/inspector"
+	"github.com/koykov/vector"
+	"github.com/koykov/vector_inspector"
+)
+
+// Saved state of context variable overridden by local variable.
+type ctxVarSave struct {
+	idx int
+	v   ctxVar
+}
+
+// Open scope of local variables (sub-decoders, user-defined functions, ...).
+//
+// Returns state that should be passed to closeScope().
+func (ctx *Ctx) openScope() (ln, mark int) {
+	return ctx.ln, len(ctx.bufSV)
+}
+
+// Register local variable. Variable of the caller with the same name is restored on scope close.
+func (ctx *Ctx) bindLocal(key string, val any, ins inspector.Inspector) {
+	for i := 0; i < ctx.ln; i++ {
+		if ctx.vars[i].key == key {
+			ctx.bufSV = append(ctx.bufSV, ctxVarSave{idx: i, v: ctx.vars[i]})
+			ctx.vars[i].val, ctx.vars[i].ins = val, ins
+			return
+		}
+	}
+	ctx.Set(key, val, ins)
+}
+
+// Close scope of local variables: restore overridden variables and drop variables registered inside the scope.
+func (ctx *Ctx) closeScope(ln, mark int) {
+	for i := len(ctx.bufSV) - 1; i >= mark; i-- {
+		s := &ctx.bufSV[i]
+		ctx.vars[s.idx] = s.v
+		s.v.val, s.v.ins = nil, nil
+	}
+	ctx.bufSV = ctx.bufSV[:mark]
+	for i := ln; i < ctx.ln; i++ {
+		ctx.vars[i].val, ctx.vars[i].ins = nil, nil
+	}
+	ctx.ln = ln
+}
+
+// Prepare arbitrary value to register it as context variable.
+//
+// Returns value and suitable inspector. Pointers to pointers dereferences, nil nested objects allocates if alloc flag
+// is set.
+func insOf(x any, alloc bool) (any, inspector.Inspector, error) {
+	if x == nil {
+		return nil, nil, nil
+	}
+	if node, ok := x.(*vector.Node); ok {
+		if node == nil || node.Type() == vector.TypeNull {
+			return nil, nil, nil
+		}
+		return node, vector_inspector.VectorInspector{}, nil
+	}
+	v := reflect.ValueOf(x)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return x, inspector.StaticInspector{}, nil
+	}
+	for v.Elem().Kind() == reflect.Ptr {
+		if v.Elem().IsNil() {
+			if !alloc {
+				return nil, nil, nil
+			}
+			// Nested object isn't allocated yet, eg: dst.Finance of type *Finance.
+			v.Elem().Set(reflect.New(v.Elem().Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Elem().Kind() != reflect.Struct {
+		return v.Interface(), inspector.StaticInspector{}, nil
+	}
+	ins, err := inspector.GetInspector(v.Type().Elem().Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.Interface(), ins, nil
+}
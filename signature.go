@@ -0,0 +1,183 @@
+package decoder
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/koykov/byteconv"
+)
+
+// ArgKind describes kind of function argument in the signature.
+type ArgKind int
+
+const (
+	// ArgAny allows argument of any kind.
+	ArgAny ArgKind = iota
+	// ArgPath requires path to variable, eg: resp.user.name.
+	ArgPath
+	// ArgString requires static string, eg: "N/D".
+	ArgString
+	// ArgNumber requires number, eg: 15 or -3.1415.
+	ArgNumber
+	// ArgBool requires true or false.
+	ArgBool
+	// ArgType requires type literal, eg: TestObject.
+	ArgType
+)
+
+// Signature describes arguments of modifier, getter, callback or condition helper.
+//
+// Modifier's signature doesn't include preceding value, eg: signature of "x|default(1)" has the only argument.
+type Signature struct {
+	// Min and Max limits count of arguments.
+	Min, Max int
+	// Variadic allows unlimited count of arguments, Max is ignored.
+	Variadic bool
+	// Kinds of arguments. Kind of the last one applies to the rest arguments of variadic function.
+	Args []ArgKind
+}
+
+var (
+	reArgNumber = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+	reArgType   = regexp.MustCompile(`^\*?(\[])?[\w.:]+$`)
+)
+
+// WithSignature declares signature of the function to check calls at parse time.
+func (t *docgen) WithSignature(sig Signature) *docgen {
+	if sig.Max < sig.Min {
+		sig.Max = sig.Min
+	}
+	t.sig = &sig
+	return t
+}
+
+// Check arguments list of the call.
+func (s *Signature) check(args []*arg) error {
+	if len(args) < s.Min {
+		return fmt.Errorf("expects at least %d arguments, got %d", s.Min, len(args))
+	}
+	if !s.Variadic && len(args) > s.Max {
+		return fmt.Errorf("expects at most %d arguments, got %d", s.Max, len(args))
+	}
+	if len(s.Args) == 0 {
+		return nil
+	}
+	for i := 0; i < len(args); i++ {
+		k := ArgAny
+		if i < len(s.Args) {
+			k = s.Args[i]
+		} else if s.Variadic {
+			k = s.Args[len(s.Args)-1]
+		}
+		if !k.match(args[i]) {
+			return fmt.Errorf("argument %d must be %s, got %s '%s'", i, k, args[i].kind, args[i].val)
+		}
+	}
+	return nil
+}
+
+// Check if argument matches the kind.
+//
+// Globals matches any kind of static value.
+func (k ArgKind) match(a *arg) bool {
+	switch k {
+	case ArgAny:
+		return true
+	case ArgType:
+		return (a.kind == ArgString || a.kind == ArgPath) && reArgType.Match(a.val)
+	case ArgString, ArgNumber, ArgBool:
+		return a.kind == k || a.global
+	default:
+		return a.kind == k
+	}
+}
+
+// Get kind of argument by its raw value.
+func argKindOf(raw []byte) ArgKind {
+	switch {
+	case len(raw) > 1 && (raw[0] == '"' || raw[0] == '\''):
+		return ArgString
+	case reArgNumber.Match(raw):
+		return ArgNumber
+	case byteconv.B2S(raw) == "true" || byteconv.B2S(raw) == "false":
+		return ArgBool
+	case byteconv.B2S(raw) == "nil":
+		return ArgAny
+	default:
+		return ArgPath
+	}
+}
+
+func (k ArgKind) String() string {
+	switch k {
+	case ArgPath:
+		return "path"
+	case ArgString:
+		return "string"
+	case ArgNumber:
+		return "number"
+	case ArgBool:
+		return "bool"
+	case ArgType:
+		return "type"
+	default:
+		return "any"
+	}
+}
+
+// Check arguments of the call of registered function with the signature.
+func (p *parser) checkSig(sig *Signature, typ string, name []byte, args []*arg, offset int) error {
+	if sig == nil || p.mode == ModeLax {
+		return nil
+	}
+	if err := sig.check(args); err != nil {
+		return fmt.Errorf("%w: %s '%s' %s at offset %d", ErrSignature, typ, name, err, offset)
+	}
+	return nil
+}
+
+// Get signature of modifier.
+func getModSig(name []byte) *Signature {
+	regMux.RLock()
+	defer regMux.RUnlock()
+	if idx, ok := modRegistry[byteconv.B2S(name)]; ok && idx >= 0 && idx < len(modBuf) {
+		return modBuf[idx].sig
+	}
+	return nil
+}
+
+// Get signature of getter.
+func getGetterSig(name []byte) *Signature {
+	regMux.RLock()
+	defer regMux.RUnlock()
+	if idx, ok := getterRegistry[byteconv.B2S(name)]; ok && idx >= 0 && idx < len(getterBuf) {
+		return getterBuf[idx].sig
+	}
+	return nil
+}
+
+// Get signature of callback.
+func getCallbackSig(name []byte) *Signature {
+	regMux.RLock()
+	defer regMux.RUnlock()
+	if idx, ok := callbackRegistry[byteconv.B2S(name)]; ok && idx >= 0 && idx < len(callbackBuf) {
+		return callbackBuf[idx].sig
+	}
+	return nil
+}
+
+// Get signature of condition (or condition-OK) helper.
+func getCondSig(name []byte, condOK bool) *Signature {
+	regMux.RLock()
+	defer regMux.RUnlock()
+	if condOK {
+		if idx, ok := condOKRegistry[byteconv.B2S(name)]; ok && idx >= 0 && idx < len(condOkBuf) {
+			return condOkBuf[idx].sig
+		}
+		return nil
+	}
+	if idx, ok := condRegistry[byteconv.B2S(name)]; ok && idx >= 0 && idx < len(condBuf) {
+		return condBuf[idx].sig
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package decoder
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/koykov/bytebuf"
+	"github.com/koykov/inspector"
+	"github.com/koykov/jsonvector"
+	"github.com/koykov/vector"
+)
+
+// Skeleton generates starter decoder source from sample JSON response and destination type.
+//
+// Destination type is taken from inspector insName. Scalar fields of destination (numbers, bools, strings and bytes,
+// the same as automap uses) are matched to the keys of sample by names normalized to lower case without
+// non-alphanumeric chars, eg: field "FullName" matches keys "full_name", "full-name", "fullName" and "FULLNAME". Keys
+// on the same level of nesting have priority, then nested objects are checked. Fields of nested structs are matched
+// inside of objects with matching keys. Matched fields produce assignments like "obj.Id = resp.identifier", slices,
+// maps and unmatched fields produce TODO comments, as well as keys of sample that weren't used. Keys that aren't
+// identifiers are quoted, eg: resp["full name"], keys that can't be written in the source (eg: with semicolons or
+// braces) produce TODO comments.
+//
+// dstVar and srcVar are names of destination and source variables in the result.
+func Skeleton(sample []byte, insName, dstVar, srcVar string) ([]byte, error) {
+	ins, err := inspector.GetInspector(insName)
+	if err != nil {
+		return nil, err
+	}
+	typ := indirectType(reflect.TypeOf(ins.Instance(false)))
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: inspector '%s' doesn't describe a struct", ErrTypeMismatch, insName)
+	}
+
+	vec := jsonvector.Acquire()
+	defer jsonvector.Release(vec)
+	if err = vec.ParseCopy(sample); err != nil {
+		return nil, err
+	}
+	root := vec.Root()
+	if root.Type() != vector.TypeObject {
+		return nil, fmt.Errorf("%w: sample must be an object", ErrTypeMismatch)
+	}
+	sk := skeleton{src: skelKeys(root, srcVar, true)}
+	sk.fields(typ, dstVar, sk.src, 0)
+
+	var buf bytebuf.Chain
+	buf.WriteString("// Generated from sample of ").WriteString(insName).WriteString(", review before use.\n")
+	for _, s := range sk.lines {
+		buf.WriteString(s).WriteByte('\n')
+	}
+	if len(sk.todo) > 0 {
+		buf.WriteString("\n// TODO: unmatched destination fields.\n")
+		for _, s := range sk.todo {
+			buf.WriteString("// TODO: ").WriteString(s).WriteByte('\n')
+		}
+	}
+	var unused []string
+	skelUnused(sk.src, &unused)
+	if len(unused) > 0 {
+		buf.WriteString("\n// TODO: unused source keys.\n")
+		for _, s := range unused {
+			buf.WriteString("// TODO: ").WriteString(s).WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Skeleton builder.
+type skeleton struct {
+	src   []*skelKey
+	lines []string
+	todo  []string
+}
+
+// Key of sample.
+type skelKey struct {
+	name, path    string
+	object, array bool
+	child         []*skelKey
+	used          bool
+	// Path may be written in the source.
+	ok bool
+}
+
+// Match fields of struct typ to the keys.
+func (sk *skeleton) fields(typ reflect.Type, prefix string, keys []*skelKey, depth int) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		path := prefix + "." + f.Name
+		ft := indirectType(f.Type)
+		switch {
+		case isAutomapKind(ft):
+			if k := skelFind(keys, f.Name, false); k != nil {
+				k.used = true
+				if k.ok {
+					sk.lines = append(sk.lines, path+" = "+k.path)
+				} else {
+					sk.todo = append(sk.todo, path+" ("+skelType(f.Type)+") may be filled from "+k.path)
+				}
+				continue
+			}
+			sk.todo = append(sk.todo, path+" ("+skelType(f.Type)+")")
+		case ft.Kind() == reflect.Struct && depth < skelMaxDepth:
+			// Nested struct may be described by nested object or by keys of the same level.
+			if k := skelFindObject(keys, f.Name); k != nil {
+				keys1 := append(append([]*skelKey(nil), k.child...), keys...)
+				sk.fields(ft, path, keys1, depth+1)
+				continue
+			}
+			sk.fields(ft, path, keys, depth+1)
+		default:
+			if k := skelFind(keys, f.Name, true); k != nil {
+				skelUse(k)
+				sk.todo = append(sk.todo, path+" ("+skelType(f.Type)+") may be filled from "+k.path)
+				continue
+			}
+			sk.todo = append(sk.todo, path+" ("+skelType(f.Type)+")")
+		}
+	}
+}
+
+// Limit of nesting of structs, protects from recursive types.
+const skelMaxDepth = 8
+
+// Find unused key by name, on the same level first and then in nested objects.
+//
+// Scalar fields match only scalar keys, complex fields (slices, maps) match any.
+func skelFind(keys []*skelKey, name string, complex bool) *skelKey {
+	name = skelNorm(name)
+	for len(keys) > 0 {
+		var next []*skelKey
+		for _, k := range keys {
+			if !k.used && skelNorm(k.name) == name && (complex || (!k.object && !k.array)) {
+				return k
+			}
+			if k.object {
+				next = append(next, k.child...)
+			}
+		}
+		keys = next
+	}
+	return nil
+}
+
+// Find object on the same level by name.
+func skelFindObject(keys []*skelKey, name string) *skelKey {
+	name = skelNorm(name)
+	for _, k := range keys {
+		if k.object && skelNorm(k.name) == name {
+			return k
+		}
+	}
+	return nil
+}
+
+// Mark key and all nested keys as used.
+func skelUse(k *skelKey) {
+	k.used = true
+	for _, c := range k.child {
+		skelUse(c)
+	}
+}
+
+// Collect paths of unused keys, objects are checked by nested keys.
+func skelUnused(keys []*skelKey, dst *[]string) {
+	for _, k := range keys {
+		switch {
+		case k.object && len(k.child) > 0:
+			skelUnused(k.child, dst)
+		case !k.used:
+			*dst = append(*dst, k.path)
+		}
+	}
+}
+
+// Build keys of sample object. Arrays are considered as values.
+//
+// ok shows that prefix may be written in the source.
+func skelKeys(node *vector.Node, prefix string, ok bool) []*skelKey {
+	var keys []*skelKey
+	node.Each(func(_ int, child *vector.Node) {
+		k := &skelKey{
+			name:   child.KeyString(),
+			object: child.Type() == vector.TypeObject,
+			array:  child.Type() == vector.TypeArray,
+		}
+		k.path, k.ok = skelPath(prefix, k.name)
+		k.ok = k.ok && ok
+		if k.object {
+			k.child = skelKeys(child, k.path, k.ok)
+		}
+		keys = append(keys, k)
+	})
+	return keys
+}
+
+// Chars that break parsing of the source even inside quotes.
+const skelUnsafe = ";{}[]|\\"
+
+// Make path of the key, keys that aren't identifiers are quoted, eg: resp["full name"].
+//
+// Returns false if key can't be written in the source, path is suitable for comments only then.
+func skelPath(prefix, name string) (string, bool) {
+	if len(name) > 0 && strings.IndexFunc(name, func(r rune) bool { return r > 0x7f || !isIdentChar(byte(r)) }) == -1 {
+		return prefix + "." + name, true
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return r < ' ' || strings.ContainsRune(skelUnsafe, r) }) == -1 {
+		for _, q := range []string{`"`, `'`, "`"} {
+			if !strings.Contains(name, q) {
+				return prefix + "[" + q + name + q + "]", true
+			}
+		}
+	}
+	// Comments are parsed too, so escape chars that break them.
+	return prefix + "[" + skelEscaper.Replace(strconv.Quote(name)) + "]", false
+}
+
+var skelEscaper = strings.NewReplacer(";", `\x3b`, "{", `\x7b`, "}", `\x7d`)
+
+// Get name of type as written in Go.
+func skelType(t reflect.Type) string {
+	return strings.ReplaceAll(t.String(), "[]uint8", "[]byte")
+}
+
+// Normalize name to compare, eg: "Full_Name" -> "fullname".
+func skelNorm(s string) string {
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
@@ -0,0 +1,62 @@
+package decoder
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/inspector/testobj_ins"
+	"github.com/koykov/jsonvector"
+)
+
+func TestSkeleton(t *testing.T) {
+	files, _ := filepath.Glob("testdata/skeleton/*.json")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		t.Run(name, func(t *testing.T) {
+			sample, _ := os.ReadFile(file)
+			expect, _ := os.ReadFile(strings.Replace(file, ".json", ".dec", 1))
+			r, err := Skeleton(sample, name, "obj", "resp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(r, expect) {
+				t.Errorf("skeleton mismatch, need:\n%s\ngot:\n%s", expect, r)
+			}
+			if _, err = Parse(r); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	t.Run("quoted keys", func(t *testing.T) {
+		// Quoted keys must be resolved on decode.
+		sample, _ := os.ReadFile("testdata/skeleton/TestFinance.json")
+		r, _ := Skeleton(sample, "TestFinance", "obj", "resp")
+		tree, err := Parse(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		RegisterDecoderKey("skeleton/quoted", tree)
+		ctx := NewCtx()
+		obj := &testobj.TestFinance{}
+		vec := jsonvector.NewVector()
+		_ = vec.Parse(sample)
+		ctx.Set("obj", obj, testobj_ins.TestFinanceInspector{})
+		ctx.SetVector("resp", vec)
+		if err = Decode("skeleton/quoted", ctx); err != nil || ctx.Err != nil {
+			t.Error(err, ctx.Err)
+		}
+		assertF64(t, "MoneyIn", obj.MoneyIn, 164.5962)
+		assertF64(t, "MoneyOut", obj.MoneyOut, 15.3)
+		assertF64(t, "Balance", obj.Balance, 200)
+	})
+	t.Run("not struct", func(t *testing.T) {
+		if _, err := Skeleton([]byte(`{}`), "strings", "obj", "resp"); !errors.Is(err, ErrTypeMismatch) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,388 @@
+package decoder
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/koykov/bytebuf"
+)
+
+// Source renders the tree back to decoder source code.
+//
+// Parsing of the result gives a tree equivalent to the original one, thus trees may be edited programmatically and
+// saved as regular decoders. Comments and original formatting aren't the part of tree and so they are lost, the result
+// is formatted canonically (see Format). Constants are already substituted in the rules, but their declarations are
+// kept.
+func (t *Tree) Source() []byte {
+	return t.AST().source()
+}
+
+// Render AST to decoder source code, see Tree.Source().
+func (a *AST) source() []byte {
+	var buf bytebuf.Chain
+	if len(a.Extends) > 0 {
+		buf.WriteString(`extends "`).WriteString(a.Extends).WriteString("\"\n")
+	}
+	for _, lib := range a.Imports {
+		buf.WriteString(`import "`).WriteString(lib).WriteString("\"\n")
+	}
+	if len(a.Consts) > 0 {
+		keys := make([]string, 0, len(a.Consts))
+		for k := range a.Consts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteString("const (\n")
+		for _, k := range keys {
+			buf.WriteString(fmtIndent).WriteString(k).WriteString(" = ").WriteString(a.Consts[k]).WriteByte('\n')
+		}
+		buf.WriteString(")\n")
+	}
+	if buf.Len() > 0 && (len(a.Funcs) > 0 || len(a.Stmts) > 0) {
+		buf.WriteByte('\n')
+	}
+	for i := 0; i < len(a.Funcs); i++ {
+		f := &a.Funcs[i]
+		if f.Macro {
+			buf.WriteString("macro ")
+		} else {
+			buf.WriteString("func ")
+		}
+		buf.WriteString(f.Name).WriteByte('(').WriteString(strings.Join(f.Params, ", ")).WriteString(") {\n")
+		srcStmts(&buf, f.Body, 1)
+		buf.WriteString("}\n\n")
+	}
+	srcStmts(&buf, a.Stmts, 0)
+	return buf.Bytes()
+}
+
+// Internal source helper.
+func srcStmts(buf *bytebuf.Chain, list []Stmt, depth int) {
+	for i := 0; i < len(list); i++ {
+		s := &list[i]
+		switch {
+		case s.Kind == StmtFlag:
+			// Directives don't affect indentation.
+			srcIndent(buf, depth)
+			buf.WriteString(`#if flag("`).WriteString(s.Flag).WriteString("\")\n")
+			srcStmts(buf, s.Then, depth)
+			if len(s.Else) > 0 {
+				srcIndent(buf, depth)
+				buf.WriteString("#else\n")
+				srcStmts(buf, s.Else, depth)
+			}
+			srcIndent(buf, depth)
+			buf.WriteString("#endif\n")
+		case srcBlock(s):
+			srcIndent(buf, depth)
+			srcHead(buf, s)
+			buf.WriteString(" {\n")
+			switch s.Kind {
+			case StmtIf:
+				srcStmts(buf, s.Then, depth+1)
+				if len(s.Else) > 0 {
+					srcIndent(buf, depth)
+					buf.WriteString("} else {\n")
+					srcStmts(buf, s.Else, depth+1)
+				}
+			case StmtSwitch:
+				for j := 0; j < len(s.Body); j++ {
+					c := &s.Body[j]
+					// Cases have the same indentation as switch.
+					srcIndent(buf, depth)
+					srcHead(buf, c)
+					buf.WriteString(":\n")
+					srcStmts(buf, c.Body, depth+1)
+				}
+			default:
+				srcStmts(buf, s.Body, depth+1)
+			}
+			srcIndent(buf, depth)
+			buf.WriteString("}\n")
+		default:
+			srcIndent(buf, depth)
+			srcLine(buf, s)
+			buf.WriteByte('\n')
+		}
+	}
+}
+
+// Check if statement is a block with curly brackets.
+func srcBlock(s *Stmt) bool {
+	switch s.Kind {
+	case StmtIf:
+		return !s.Ternary
+	case StmtSwitch, StmtLoopRange, StmtLoopCount, StmtWith:
+		return true
+	}
+	return false
+}
+
+// Write header of block statement without curly bracket, eg: "for k, v := range src.items" or "case 1".
+func srcHead(buf *bytebuf.Chain, s *Stmt) {
+	switch s.Kind {
+	case StmtIf:
+		buf.WriteString("if ")
+		if c := s.Cond; c != nil && len(c.Var) > 0 {
+			// Condition-OK, eg: "if v, ok := helper(x); ok".
+			buf.WriteString(c.Var)
+			if len(c.VarOK) > 0 {
+				buf.WriteString(", ").WriteString(c.VarOK)
+			}
+			buf.WriteString(" := ")
+			if c.Helper != nil {
+				srcCall(buf, c.Helper)
+			}
+			if len(c.Type) > 0 {
+				buf.WriteString(" as ").WriteString(c.Type)
+			}
+			buf.WriteString("; ")
+			if c.Op == opNq.String() && c.Right.Value == "true" {
+				buf.WriteByte('!')
+			}
+			buf.WriteString(c.Left.Value)
+			return
+		}
+		srcCond(buf, s.Cond)
+	case StmtSwitch:
+		buf.WriteString("switch")
+		if len(s.Src.Path.Raw) > 0 {
+			buf.WriteByte(' ').WriteString(s.Src.Path.Raw)
+		}
+	case StmtCase:
+		buf.WriteString("case ")
+		srcCond(buf, s.Cond)
+	case StmtDefault:
+		buf.WriteString("default")
+	case StmtLoopRange, StmtLoopCount:
+		l := s.Loop
+		if l == nil {
+			l = &Loop{}
+		}
+		if len(l.Label) > 0 {
+			buf.WriteString(l.Label).WriteString(": ")
+		}
+		buf.WriteString("for ")
+		if s.Kind == StmtLoopRange {
+			if len(l.Key) > 0 {
+				buf.WriteString(l.Key)
+			} else {
+				buf.Write(uscore)
+			}
+			if len(l.Val) > 0 {
+				buf.WriteString(", ").WriteString(l.Val)
+			}
+			buf.WriteString(" := range ").WriteString(s.Src.Path.Raw)
+		} else {
+			buf.WriteString(l.Counter).WriteString(" := ").WriteString(l.Init).WriteString("; ").
+				WriteString(l.Counter).WriteByte(' ').WriteString(l.CondOp).WriteByte(' ').WriteString(l.Limit).
+				WriteString("; ").WriteString(l.Counter).WriteString(l.CountOp)
+		}
+	case StmtWith:
+		buf.WriteString("with ").WriteString(s.Dst.Raw).WriteString("[+] as ").WriteString(s.Var)
+	}
+}
+
+// Write single-line statement, eg: "dst.Name = src.name|default("N/D")".
+func srcLine(buf *bytebuf.Chain, s *Stmt) {
+	switch s.Kind {
+	case StmtAssign:
+		buf.WriteString(s.Dst.Raw)
+		if s.Push {
+			buf.Write(qbE)
+		}
+		buf.WriteString(" = ")
+		if s.Call != nil {
+			// Getter.
+			srcCall(buf, s.Call)
+			return
+		}
+		srcExpr(buf, &s.Src)
+	case StmtCall:
+		if s.Call != nil {
+			srcCall(buf, s.Call)
+		}
+	case StmtIf:
+		// Ternary operator, eg: "dst.Status = src.status == 1 ? src.RealState : false".
+		if len(s.Then) == 0 || len(s.Else) == 0 {
+			return
+		}
+		a, b := &s.Then[0], &s.Else[0]
+		buf.WriteString(a.Dst.Raw).WriteString(" = ")
+		srcCond(buf, s.Cond)
+		buf.WriteString(" ? ")
+		srcPath(buf, a.Src.Path.Raw, a.Src.Subset)
+		buf.WriteString(" : ")
+		srcPath(buf, b.Src.Path.Raw, b.Src.Subset)
+	case StmtBreak, StmtLazyBreak, StmtContinue:
+		switch s.Kind {
+		case StmtBreak:
+			buf.Write(loopBrk)
+		case StmtLazyBreak:
+			buf.Write(loopLBrk)
+		default:
+			buf.WriteString("continue")
+		}
+		if len(s.Label) > 0 {
+			buf.WriteByte(' ').WriteString(s.Label)
+		} else if s.Depth > 0 {
+			buf.WriteByte(' ').WriteInt(int64(s.Depth))
+		}
+	case StmtReturn:
+		buf.Write(ret)
+		if e := &s.Src; e.Static || len(e.Path.Raw) > 0 || len(e.Mods) > 0 {
+			buf.WriteByte(' ')
+			srcExpr(buf, e)
+		}
+	case StmtAutomap:
+		buf.WriteString("automap(").WriteString(s.Dst.Raw).WriteString(", ").WriteString(s.Src.Path.Raw).WriteByte(')')
+		if am := s.Automap; am != nil {
+			if len(am.Except) > 0 {
+				buf.WriteString(" except(").WriteString(strings.Join(am.Except, ", ")).WriteByte(')')
+			}
+			if len(am.Rename) > 0 {
+				buf.WriteString(" rename(")
+				for j, r := range am.Rename {
+					if j > 0 {
+						buf.WriteString(", ")
+					}
+					buf.WriteString(r[0]).WriteString(": ").WriteString(r[1])
+				}
+				buf.WriteByte(')')
+			}
+			if len(am.Strategy) > 0 && am.Strategy != amFold.String() {
+				buf.WriteString(" strategy(").WriteString(am.Strategy).WriteByte(')')
+			}
+		}
+	case StmtDecode:
+		buf.WriteString(`decode("`).WriteString(s.Decoder).WriteString(`", `).WriteString(s.Dst.Raw).WriteString(", ").
+			WriteString(s.Src.Path.Raw).WriteByte(')')
+	case StmtUse:
+		buf.WriteString(`use "`).WriteString(s.Decoder).WriteByte('"')
+	}
+}
+
+// Write right side of assignment or returning value.
+func srcExpr(buf *bytebuf.Chain, e *Expr) {
+	if e.Static {
+		srcStatic(buf, e.Value)
+		return
+	}
+	if len(e.Path.Raw) == 0 && len(e.Mods) == 1 {
+		// Modifier without variable, eg: "new(TestObject)" or "append(dst.List, x)".
+		m := &e.Mods[0]
+		if len(e.Type) > 0 && (m.Name == "new" || m.Name == "bufferize") {
+			buf.WriteString(m.Name).WriteByte('(').WriteString(e.Type).WriteByte(')')
+			return
+		}
+		srcCall(buf, m)
+	} else {
+		srcPath(buf, e.Path.Raw, e.Subset)
+		for i := 0; i < len(e.Mods); i++ {
+			buf.WriteByte('|')
+			srcCall(buf, &e.Mods[i])
+		}
+	}
+	if len(e.Type) > 0 {
+		buf.WriteString(" as ").WriteString(e.Type)
+	}
+}
+
+// Write comparison or helper call.
+func srcCond(buf *bytebuf.Chain, c *Cond) {
+	if c == nil {
+		return
+	}
+	if c.Helper != nil {
+		srcCall(buf, c.Helper)
+		return
+	}
+	srcArg(buf, &c.Left)
+	if len(c.Op) > 0 {
+		buf.WriteByte(' ').WriteString(c.Op).WriteByte(' ')
+		srcArg(buf, &c.Right)
+	}
+}
+
+// Write call of function (callback, getter, modifier or helper) with arguments.
+func srcCall(buf *bytebuf.Chain, c *Call) {
+	buf.WriteString(c.Name).WriteByte('(')
+	for i := 0; i < len(c.Args); i++ {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		srcArg(buf, &c.Args[i])
+	}
+	buf.WriteByte(')')
+}
+
+func srcArg(buf *bytebuf.Chain, a *Arg) {
+	switch {
+	case a.Static && a.Kind == ArgString:
+		srcQuote(buf, a.Value)
+	case a.Static:
+		srcStatic(buf, a.Value)
+	default:
+		srcPath(buf, a.Value, a.Subset)
+	}
+}
+
+// Write path with optional subset, eg: "src.{id|title}".
+func srcPath(buf *bytebuf.Chain, v string, set []string) {
+	buf.WriteString(v)
+	if len(set) > 0 {
+		buf.WriteString(".{").WriteString(strings.Join(set, "|")).WriteByte('}')
+	}
+}
+
+// Write static value, strings are quoted but numbers, booleans and nil keep as is.
+func srcStatic(buf *bytebuf.Chain, v string) {
+	if len(v) > 0 && isStaticRE.MatchString(v) {
+		buf.WriteString(v)
+		return
+	}
+	srcQuote(buf, v)
+}
+
+// Write static string with quotes of kind that value doesn't contain.
+func srcQuote(buf *bytebuf.Chain, v string) {
+	q := byte('"')
+	switch {
+	case strings.IndexByte(v, '"') == -1:
+	case strings.IndexByte(v, '\'') == -1:
+		q = '\''
+	default:
+		q = '`'
+	}
+	buf.WriteByte(q).WriteString(v).WriteByte(q)
+}
+
+func srcIndent(buf *bytebuf.Chain, depth int) {
+	for i := 0; i < depth; i++ {
+		buf.WriteString(fmtIndent)
+	}
+}
+
+// Get branches of condition made by ternary operator.
+//
+// Ternary operator creates condition with non-static assignments to the same destination in both branches. Such
+// condition can't be produced by regular if-else block, so it must be written back as ternary.
+func ternary(n *node) (a, b *node, ok bool) {
+	if n.typ != typeCond || len(n.child) != 2 {
+		return
+	}
+	var br [2]*node
+	for i := 0; i < 2; i++ {
+		c := &n.child[i]
+		if len(c.child) != 1 {
+			return
+		}
+		br[i] = &c.child[0]
+		if x := br[i]; x.typ != typeOperator || x.static || x.push || len(x.src) == 0 || len(x.mod) > 0 ||
+			len(x.ins) > 0 || x.callback != nil || x.getter != nil || !bytes.Equal(x.dst, br[0].dst) {
+			return
+		}
+	}
+	return br[0], br[1], true
+}
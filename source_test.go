@@ -0,0 +1,70 @@
+package decoder
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	files, _ := filepath.Glob("testdata/source/*.dec")
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".dec")
+		t.Run(name, func(t *testing.T) {
+			src, _ := os.ReadFile(file)
+			expect, _ := os.ReadFile(strings.Replace(file, ".dec", ".txt", 1))
+			tree, err := Parse(src, ModeLax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r := tree.Source(); !bytes.Equal(r, expect) {
+				t.Errorf("source mismatch, need:\n%s\ngot:\n%s", expect, r)
+			}
+		})
+	}
+	t.Run("roundtrip", func(t *testing.T) {
+		// Source must be parsed to the same tree.
+		var files []string
+		for _, dir := range []string{"parser", "decoder", "mod", "getter", "fmt", "datetime"} {
+			list, _ := filepath.Glob("testdata/" + dir + "/*.dec")
+			files = append(files, list...)
+		}
+		for _, file := range files {
+			src, _ := os.ReadFile(file)
+			tree, err := Parse(src, ModeLax)
+			if err != nil {
+				t.Fatalf("%s: %s", file, err)
+			}
+			r := tree.Source()
+			tree1, err := Parse(r, ModeLax)
+			if err != nil {
+				t.Errorf("%s: %s\n%s", file, err, r)
+				continue
+			}
+			if !bytes.Equal(tree.HumanReadable(), tree1.HumanReadable()) {
+				t.Errorf("%s: tree mismatch, source:\n%s\nneed:\n%s\ngot:\n%s", file, r, tree.HumanReadable(), tree1.HumanReadable())
+				continue
+			}
+			if r1 := tree1.Source(); !bytes.Equal(r, r1) {
+				t.Errorf("%s: repeated source mismatch:\n%s", file, r1)
+			}
+		}
+	})
+	t.Run("quotes", func(t *testing.T) {
+		// String with quotes of both kinds must be kept as static value.
+		tree, err := NewBuilder().Assign("obj.Name", Lit(`it's "x"`)).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := tree.Source()
+		tree1, err := Parse(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.HumanReadable(), tree1.HumanReadable()) {
+			t.Errorf("tree mismatch, source:\n%s\nneed:\n%s\ngot:\n%s", r, tree.HumanReadable(), tree1.HumanReadable())
+		}
+	})
+}
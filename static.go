@@ -4,10 +4,18 @@ import "regexp"
 
 var (
 	// Regexp to check is argument is static value.
-	isStaticRE = regexp.MustCompile(`^(\d+\.*\d*|true|false|nil|"[^"]*"|'[^']*')$`)
+	isStaticRE = regexp.MustCompile(`^(\d+\.*\d*|true|false|nil|"[^"]*"|'[^']*'|` + "`[^`]*`" + `)$`)
 )
 
 // Check if arg is static value.
 func isStatic(arg []byte) bool {
 	return isStaticRE.Match(arg)
 }
+
+// Remove quotes around static value, quotes of other kinds inside the value keep as is.
+func unquote(v []byte) []byte {
+	if n := len(v); n > 1 && (v[0] == '"' || v[0] == '\'' || v[0] == '`') && v[n-1] == v[0] {
+		return v[1 : n-1]
+	}
+	return v
+}
@@ -0,0 +1,97 @@
+package decoder
+
+import (
+	"fmt"
+
+	"github.com/koykov/byteconv"
+	"github.com/koykov/inspector"
+)
+
+const (
+	// Names of variables under which sub-decoder gets destination and source objects.
+	subDst = "dst"
+	subSrc = "src"
+)
+
+// Apply decode/use node.
+//
+// Decode node runs sub-decoder with own variables bindings "dst" and "src", use node runs sub-decoder within current
+// variables.
+func (ctx *Ctx) subDecode(r *node) error {
+	key := byteconv.B2S(r.subKey)
+	dec := decDB.getKey(key)
+	if dec == nil {
+		return fmt.Errorf("%w: '%s'", ErrDecoderNotFound, key)
+	}
+	if r.typ == typeUse {
+		return ctx.call(dec)
+	}
+
+	dv, dins := ctx.subVar(ctx.dstPath(r), true)
+	if ctx.Err != nil {
+		return ctx.Err
+	}
+	sv, sins := ctx.subVar(ctx.srcPath(r), false)
+	if ctx.Err != nil {
+		return ctx.Err
+	}
+	if dv == nil || sv == nil {
+		return nil
+	}
+
+	// Bind variables of sub-decoder. Caller's variables with the same names restores after the call.
+	ln, mark := ctx.openScope()
+	ctx.bindLocal(subDst, dv, dins)
+	ctx.bindLocal(subSrc, sv, sins)
+	err := ctx.call(dec)
+	ctx.closeScope(ln, mark)
+	return err
+}
+
+// Run decoder's ruleset and check recursive calls.
+func (ctx *Ctx) call(dec *Decoder) (err error) {
+	if dec.err != nil {
+		return dec.err
+	}
+	for i := 0; i < len(ctx.stk); i++ {
+		if ctx.stk[i] == dec.Key {
+			return fmt.Errorf("%w: '%s'", ErrDecoderRecursion, dec.Key)
+		}
+	}
+	ctx.stk = append(ctx.stk, dec.Key)
+	if err = decodeRuleset(dec.tree.nodes, ctx); err == ErrReturn {
+		// Early return stops only the called decoder.
+		ctx.ret = false
+		err = nil
+	}
+	ctx.stk = ctx.stk[:len(ctx.stk)-1]
+	return
+}
+
+// Get object by path together with its inspector to bind it in sub-decoder.
+//
+// Nil nested objects allocates if alloc flag is set.
+func (ctx *Ctx) subVar(path []string, alloc bool) (any, inspector.Inspector) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if len(path) == 1 {
+		for i := 0; i < ctx.ln; i++ {
+			if v := &ctx.vars[i]; v.key == path[0] {
+				return v.val, v.ins
+			}
+		}
+		return nil, nil
+	}
+	ctx.bufX = nil
+	x, _ := ctx.get2(path, nil)
+	if ctx.Err != nil || x == nil {
+		return nil, nil
+	}
+	val, ins, err := insOf(x, alloc)
+	if err != nil {
+		ctx.Err = err
+		return nil, nil
+	}
+	return val, ins
+}
@@ -0,0 +1,215 @@
+package decoder
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/koykov/byteconv"
+	"github.com/koykov/x2bytes"
+)
+
+// Template represents decoder body with placeholders, eg: "obj.Partner = {{partner}}".
+//
+// Template produces separate trees for every set of placeholders values. Root nodes with identical source are parsed
+// once and shared between trees of the same template.
+type Template struct {
+	body []byte
+
+	mux sync.Mutex
+	// Cache of parsed root nodes by their source, limited by TemplateCacheSize entries.
+	cache map[string]node
+}
+
+// TemplateCacheSize limits count of shared root nodes kept by every template.
+//
+// Nodes that don't fit the cache are parsed in every instance and aren't shared.
+var TemplateCacheSize = 1024
+
+var (
+	rePlaceholder     = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+	rePlaceholderPath = regexp.MustCompile(`^\w+(?:\.\w+)*$`)
+	placeholderO      = []byte("{{")
+	dash              = []byte("-")
+	// Symbols that string literals can't contain.
+	tplNoStr = "\n\r;()"
+)
+
+// ParseTemplate prepares template to instantiate.
+func ParseTemplate(body []byte) (*Template, error) {
+	// Check all placeholders are well-formed.
+	ms := rePlaceholder.FindAllIndex(body, -1)
+	for off := 0; ; {
+		i := bytes.Index(body[off:], placeholderO)
+		if i == -1 {
+			break
+		}
+		i += off
+		var ok bool
+		for j := 0; j < len(ms) && !ok; j++ {
+			ok = ms[j][0] == i
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w at offset %d", ErrTplMalformed, i)
+		}
+		off = i + len(placeholderO)
+	}
+	t := Template{body: append([]byte(nil), body...)}
+	return &t, nil
+}
+
+// Instantiate fills placeholders using vars and parses the result.
+func (t *Template) Instantiate(vars map[string]any) (*Tree, error) {
+	body, err := t.fill(vars)
+	if err != nil {
+		return nil, err
+	}
+	var spans [][2]int
+	p := &parser{body: body, spans: &spans}
+	tree, err := p.tree()
+	if err != nil {
+		return nil, err
+	}
+	if len(p.udfs) > 0 || len(p.imp) > 0 || len(spans) != len(tree.nodes) {
+		// User-defined functions binds to the tree, so nodes can't be shared.
+		return tree, nil
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.cache == nil {
+		t.cache = make(map[string]node)
+	}
+	for i := 0; i < len(tree.nodes); i++ {
+		src := p.body[spans[i][0]:spans[i][1]]
+		if n, ok := t.cache[byteconv.B2S(src)]; ok {
+			tree.nodes[i] = n
+			continue
+		}
+		if len(t.cache) < TemplateCacheSize {
+			t.cache[string(src)] = tree.nodes[i]
+		}
+	}
+	return tree, nil
+}
+
+// Reset drops cache of shared nodes.
+//
+// Trees instantiated before keep using their nodes.
+func (t *Template) Reset() {
+	t.mux.Lock()
+	t.cache = nil
+	t.mux.Unlock()
+}
+
+// Register instantiates the template and registers the tree under derived key.
+//
+// Derived key consists of key and vars values ordered by names, eg: "decUser" and {"partner": 15} produce key
+// "decUser-15", that may be used together with DecodeFallback().
+func (t *Template) Register(key string, vars map[string]any) (string, error) {
+	tree, err := t.Instantiate(vars)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf := []byte(key)
+	for _, name := range names {
+		buf = append(buf, dash...)
+		if buf, err = x2bytes.ToBytes(buf, vars[name]); err != nil {
+			return "", err
+		}
+	}
+	dkey := string(buf)
+	RegisterDecoderKey(dkey, tree)
+	return dkey, nil
+}
+
+// Replace placeholders with values.
+//
+// Numbers and booleans are inserted as is. Strings inserts according position of the placeholder:
+// * after or before dot (eg: "jso.{{field}}") value must be a path, eg: "balance" or "finance.balance";
+// * inside quotes (eg: "\"{{name}}\"") value inserts as is;
+// * otherwise value inserts as quoted string.
+// Decoder syntax has no escape sequences, so strings that can't be represented (contain both quotes, line breaks,
+// semicolons or parentheses) aren't allowed.
+func (t *Template) fill(vars map[string]any) (body []byte, err error) {
+	body = make([]byte, 0, len(t.body))
+	var (
+		off int
+		q   byte
+	)
+	for _, m := range rePlaceholder.FindAllSubmatchIndex(t.body, -1) {
+		q = tplQuote(t.body[off:m[0]], q)
+		body = append(body, t.body[off:m[0]]...)
+		name := byteconv.B2S(t.body[m[2]:m[3]])
+		val, ok := vars[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: '%s'", ErrTplVarNotFound, name)
+		}
+		var s string
+		switch x := val.(type) {
+		case string:
+			s = x
+		case []byte:
+			s = byteconv.B2S(x)
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			if body, err = x2bytes.ToBytes(body, val); err != nil {
+				return nil, err
+			}
+			off = m[1]
+			continue
+		default:
+			return nil, fmt.Errorf("%w: '%s' of type %T", ErrTplVarValue, name, val)
+		}
+		path := (m[0] > 0 && t.body[m[0]-1] == '.') || (m[1] < len(t.body) && t.body[m[1]] == '.')
+		switch {
+		case q != 0:
+			if strings.IndexByte(s, q) != -1 || strings.ContainsAny(s, tplNoStr) {
+				return nil, fmt.Errorf("%w: '%s'", ErrTplVarValue, name)
+			}
+			body = append(body, s...)
+		case path:
+			if !rePlaceholderPath.MatchString(s) {
+				return nil, fmt.Errorf("%w: '%s' must be a path", ErrTplVarValue, name)
+			}
+			body = append(body, s...)
+		default:
+			qc := byte('"')
+			if strings.IndexByte(s, qc) != -1 {
+				qc = '\''
+			}
+			if strings.IndexByte(s, qc) != -1 || strings.ContainsAny(s, tplNoStr) {
+				return nil, fmt.Errorf("%w: '%s'", ErrTplVarValue, name)
+			}
+			body = append(append(append(body, qc), s...), qc)
+		}
+		off = m[1]
+	}
+	body = append(body, t.body[off:]...)
+	return
+}
+
+// Get quote opened at the end of p, q is the quote opened before p.
+func tplQuote(p []byte, q byte) byte {
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; {
+		case c == '\n':
+			// Strings can't be multiline.
+			q = 0
+		case q != 0:
+			if c == q {
+				q = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			q = c
+		}
+	}
+	return q
+}
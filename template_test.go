@@ -0,0 +1,120 @@
+package decoder
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/koykov/inspector/testobj"
+	"github.com/koykov/inspector/testobj_ins"
+	"github.com/koykov/jsonvector"
+)
+
+func TestTemplate(t *testing.T) {
+	body, _ := os.ReadFile("testdata/template/user.dec")
+	tpl, err := ParseTemplate(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("instantiate", func(t *testing.T) {
+		t0, err := tpl.Instantiate(map[string]any{"status": 15, "field": "balance"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t1, err := tpl.Instantiate(map[string]any{"status": 20, "field": "balance_total"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(t0.nodes) != 4 || len(t1.nodes) != 4 {
+			t.Fatalf("unexpected nodes count %d/%d", len(t0.nodes), len(t1.nodes))
+		}
+		if &t0.nodes[2].child[0] != &t1.nodes[2].child[0] {
+			t.Error("identical subtrees aren't shared")
+		}
+		if string(t0.nodes[1].src) == string(t1.nodes[1].src) {
+			t.Error("placeholders aren't filled")
+		}
+	})
+	t.Run("register", func(t *testing.T) {
+		key, err := tpl.Register("tpl/user", map[string]any{"status": 15, "field": "balance_total"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "tpl/user-balance_total-15" {
+			t.Fatalf("unexpected derived key %s", key)
+		}
+		vec := jsonvector.NewVector()
+		if err = vec.Parse(jsonSrc["src"]); err != nil {
+			t.Fatal(err)
+		}
+		ctx := NewCtx()
+		obj := &testobj.TestObject{}
+		ctx.Set("obj", obj, testobj_ins.TestObjectInspector{})
+		ctx.SetVector("jso", vec)
+		if err = DecodeFallback(key, "tpl/user", ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertS(t, "Id", obj.Id, "xf44e")
+		assertI32(t, "Status", obj.Status, 15)
+		assertB(t, "Name", obj.Name, []byte("Marquis Warren"))
+		assertF64(t, "Finance.Balance", obj.Finance.Balance, 200)
+	})
+	t.Run("var_not_found", func(t *testing.T) {
+		if _, err := tpl.Instantiate(map[string]any{"status": 15}); !errors.Is(err, ErrTplVarNotFound) {
+			t.Errorf("expected error %s, got %v", ErrTplVarNotFound, err)
+		}
+	})
+	t.Run("values", func(t *testing.T) {
+		tpl, err := ParseTemplate([]byte("obj.Id = {{id}}\nobj.Name = \"{{name}}\"\nobj.Finance.Balance = jso.{{field}}\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := tpl.Instantiate(map[string]any{"id": `x"y`, "name": "Foo Bar", "field": "finance.balance"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tree.nodes) != 3 {
+			t.Fatalf("unexpected nodes count %d", len(tree.nodes))
+		}
+		for i, exp := range []string{`x"y`, "Foo Bar", "jso.finance.balance"} {
+			if n := &tree.nodes[i]; string(n.src) != exp || n.static != (i < 2) {
+				t.Errorf("node #%d: expected src %s, got %s", i, exp, n.src)
+			}
+		}
+
+		for _, vars := range []map[string]any{
+			{"id": "1\nobj.Status = 1", "name": "foo", "field": "balance"},
+			{"id": "1", "name": `foo"bar`, "field": "balance"},
+			{"id": "1", "name": "foo", "field": "balance|default(1)"},
+			{"id": struct{}{}, "name": "foo", "field": "balance"},
+		} {
+			if _, err = tpl.Instantiate(vars); !errors.Is(err, ErrTplVarValue) {
+				t.Errorf("expected error %s, got %v", ErrTplVarValue, err)
+			}
+		}
+	})
+	t.Run("cache", func(t *testing.T) {
+		tpl, err := ParseTemplate(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := TemplateCacheSize
+		defer func() { TemplateCacheSize = size }()
+		TemplateCacheSize = 1
+		vars := map[string]any{"status": 15, "field": "balance"}
+		t0, _ := tpl.Instantiate(vars)
+		t1, _ := tpl.Instantiate(vars)
+		if &t0.nodes[2].child[0] == &t1.nodes[2].child[0] || len(tpl.cache) != 1 {
+			t.Errorf("cache must be limited, got %d entries", len(tpl.cache))
+		}
+		tpl.Reset()
+		if len(tpl.cache) != 0 {
+			t.Error("cache must be empty after reset")
+		}
+	})
+	t.Run("malformed", func(t *testing.T) {
+		if _, err := ParseTemplate([]byte("obj.Status = {{status")); !errors.Is(err, ErrTplMalformed) {
+			t.Errorf("expected error %s, got %v", ErrTplMalformed, err)
+		}
+	})
+}
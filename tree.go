@@ -2,6 +2,7 @@ package decoder
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/koykov/bytebuf"
 	"github.com/koykov/byteconv"
@@ -13,6 +14,16 @@ type Ruleset []node
 type Tree struct {
 	nodes Ruleset
 	hsum  uint64
+	// Key of parent decoder (see extends statement).
+	parent string
+	// Keys of imported libraries (see import statement).
+	imports []string
+	// Declared user-defined functions and macros.
+	udfs []*udf
+	// Declared constants.
+	consts map[string][]byte
+	// Generation of registries the tree bound to.
+	gen uint64
 }
 
 // Argument for getter/callback/modifier.
@@ -25,6 +36,8 @@ type arg struct {
 	static bool
 	// Flag that indicates if value is a global variable.
 	global bool
+	// Kind of the value (see Signature).
+	kind ArgKind
 }
 
 var (
@@ -40,7 +53,7 @@ func (t *Tree) Ruleset() Ruleset {
 
 // HumanReadable builds human-readable view of the nodes list.
 func (t *Tree) HumanReadable() []byte {
-	if len(t.nodes) == 0 {
+	if len(t.nodes) == 0 && len(t.udfs) == 0 {
 		return nil
 	}
 	var buf bytebuf.Chain
@@ -54,7 +67,11 @@ func (t *Tree) hrHelper(buf *bytebuf.Chain, nodes []node, depth int) {
 		buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
 	}
 	buf.WriteByteN('\t', depth).
-		WriteString("<nodes>\n")
+		WriteString("<nodes")
+	if depth == 0 {
+		t.attrS(buf, "extends", t.parent)
+	}
+	buf.WriteString(">\n")
 	for _, n := range nodes {
 		buf.WriteByteN('\t', depth+1)
 		buf.WriteString(`<node`)
@@ -81,6 +98,15 @@ func (t *Tree) hrHelper(buf *bytebuf.Chain, nodes []node, depth int) {
 			if len(n.ins) > 0 {
 				t.attrB(buf, "ins", n.ins)
 			}
+			if n.dstDyn {
+				t.attrI(buf, "dstDyn", 1)
+			}
+			if n.srcDyn {
+				t.attrI(buf, "srcDyn", 1)
+			}
+			if n.push {
+				t.attrI(buf, "push", 1)
+			}
 		}
 
 		if n.typ == typeCond {
@@ -180,6 +206,26 @@ func (t *Tree) hrHelper(buf *bytebuf.Chain, nodes []node, depth int) {
 			t.attrB(buf, "limit", n.loopLim)
 			t.attrS(buf, "op", n.loopCntOp.String())
 		}
+		t.attrB(buf, "label", n.loopLbl)
+		t.attrB(buf, "as", n.withVar)
+		t.attrB(buf, "decoder", n.subKey)
+		t.attrB(buf, "flag", n.flag)
+		if n.am != nil {
+			t.attrS(buf, "strategy", n.am.strategy.String())
+			if len(n.am.except) > 0 {
+				t.attrS(buf, "except", strings.Join(n.am.except, ","))
+			}
+			if len(n.am.rename) > 0 {
+				buf.WriteString(` rename="`)
+				for i := 0; i < len(n.am.rename); i++ {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					buf.WriteString(n.am.rename[i][0]).WriteByte(':').WriteString(n.am.rename[i][1])
+				}
+				buf.WriteByte('"')
+			}
+		}
 		t.attrI(buf, "brkD", n.loopBrkD)
 
 		if len(n.mod) > 0 || len(n.child) > 0 {
@@ -211,6 +257,20 @@ func (t *Tree) hrHelper(buf *bytebuf.Chain, nodes []node, depth int) {
 		}
 
 	}
+	if depth == 0 {
+		for _, f := range t.udfs {
+			typ := "func"
+			if f.macro {
+				typ = "macro"
+			}
+			buf.WriteByte('\t').WriteByte('<').WriteString(typ)
+			t.attrB(buf, "name", f.name)
+			t.attrB(buf, "params", bytes.Join(f.params, comma))
+			buf.WriteString(">\n")
+			t.hrHelper(buf, f.body, 2)
+			buf.WriteString("\t</").WriteString(typ).WriteString(">\n")
+		}
+	}
 	buf.WriteByteN('\t', depth).WriteString("</nodes>\n")
 }
 
@@ -3,9 +3,15 @@ package decoder
 // node object that describes one operator in decoder's body.
 type node struct {
 	typ rtype
+	// Position of the rule in the source, starting from 1.
+	line, col int
 	// Destination/source pair.
 	dst, src, ins []byte
 	dsta, srca    []string
+	// Flags that indicates if destination/source path contains dynamic indexes/keys (eg: items[i]).
+	dstDyn, srcDyn bool
+	// Flag that indicates if source should be appended to destination slice (eg: "dst.Items[] = src").
+	push bool
 	// List of keys, that need to check sequentially in the source object.
 	subset [][]byte
 	// Getter callback, for sources like "dst = getFoo(var0, ...)"
@@ -33,6 +39,19 @@ type node struct {
 	loopLim       []byte
 	loopLimStatic bool
 	loopBrkD      int
+	loopLbl       []byte
+
+	// Name of variable to access the new slice item in with block (eg: "with dst.Items[+] as it {...}").
+	withVar []byte
+
+	// Key of sub-decoder to call (see decode/use statements).
+	subKey []byte
+
+	// Name of feature flag to check in preprocessor block (see "#if flag(...)" directive).
+	flag []byte
+
+	// Automap options.
+	am *automap
 
 	// Condition stuff.
 	condL, condOKL []byte
@@ -44,6 +63,9 @@ type node struct {
 	condHlpArg     []*arg
 	condIns        []byte
 	condLC         lc
+	// Bound condition (or case) helper and condition-OK helper.
+	condFn   CondFn
+	condOKFn CondOKFn
 
 	switchArg []byte
 
@@ -19,6 +19,13 @@ const (
 	typeSwitch
 	typeCase
 	typeDefault
+	typeReturn
+	typeWith
+	typeAutomap
+	typeDecode
+	typeUse
+	typeFunc
+	typeFlag
 )
 
 // op represents a type of the operation in conditions and loops.
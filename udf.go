@@ -0,0 +1,102 @@
+package decoder
+
+import (
+	"bytes"
+
+	"github.com/koykov/byteconv"
+	"github.com/koykov/inspector"
+)
+
+// User-defined function or macro declared in decoder's body.
+//
+// Function returns a value (see "return <expr>") and may be called as getter or modifier, macro doesn't return a value
+// and may be called as callback.
+type udf struct {
+	name   []byte
+	macro  bool
+	params [][]byte
+	body   Ruleset
+}
+
+// Get user-defined function (or macro) by name from own declarations, imported libraries or parent decoder.
+func (p *parser) getUDF(name []byte) *udf {
+	if f := findUDF(p.udfs, p.imp, name); f != nil {
+		return f
+	}
+	return findUDF(nil, p.inh, name)
+}
+
+// Get function to use as getter.
+func (f *udf) getter() GetterFn {
+	return func(ctx *Ctx, buf *any, args []any) error {
+		return ctx.callUDF(f, buf, nil, false, args)
+	}
+}
+
+// Get function to use as modifier. Modifying value passes as the first parameter.
+func (f *udf) modifier() ModFn {
+	return func(ctx *Ctx, buf *any, val any, args []any) error {
+		return ctx.callUDF(f, buf, val, true, args)
+	}
+}
+
+// Get macro to use as callback.
+func (f *udf) callback() CallbackFn {
+	return func(ctx *Ctx, args []any) error {
+		return ctx.callUDF(f, nil, nil, false, args)
+	}
+}
+
+// Call user-defined function or macro.
+//
+// Parameters registers as local variables, result of the function writes to buf.
+func (ctx *Ctx) callUDF(f *udf, buf *any, val any, hasVal bool, args []any) error {
+	ln, mark := ctx.openScope()
+	var i int
+	if hasVal && len(f.params) > 0 {
+		ctx.bindParam(f.params[0], val, false)
+		i++
+	}
+	for j := 0; i < len(f.params); i, j = i+1, j+1 {
+		var a any
+		if j < len(args) {
+			a = args[j]
+		}
+		// Macro may write to its params, so allocate nested objects.
+		ctx.bindParam(f.params[i], a, f.macro)
+	}
+
+	ctx.fret = nil
+	err := decodeRuleset(f.body, ctx)
+	if err == ErrReturn {
+		ctx.ret = false
+		err = nil
+	}
+	if buf != nil {
+		*buf = ctx.fret
+	}
+	ctx.fret = nil
+	ctx.closeScope(ln, mark)
+	return err
+}
+
+// Register parameter of user-defined function as local variable.
+func (ctx *Ctx) bindParam(name []byte, x any, alloc bool) {
+	val, ins, err := insOf(x, alloc)
+	if err != nil {
+		// Object isn't covered by inspector, so pass it as is.
+		val, ins = x, inspector.StaticInspector{}
+	}
+	ctx.bindLocal(byteconv.B2S(name), val, ins)
+}
+
+// Extract parameters names from declaration, eg: "func setMoney(dst, src) {" -> ["dst", "src"].
+func extractParams(p []byte) [][]byte {
+	var r [][]byte
+	for _, x := range bytes.Split(p, comma) {
+		if x = bytes.TrimSpace(x); len(x) > 0 {
+			r = append(r, x)
+		}
+	}
+	return r
+}
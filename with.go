@@ -0,0 +1,204 @@
+package decoder
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/koykov/byteconv"
+	"github.com/koykov/inspector"
+)
+
+// Internal setter for nodes.
+//
+// Assigns val to the destination of node or appends it to the destination slice (see push flag).
+func (ctx *Ctx) assign(r *node, val any) error {
+	path := ctx.dstPath(r)
+	if !r.push {
+		return ctx.set2(path, val, r.ins)
+	}
+	return ctx.push2(path, val)
+}
+
+// Append val to the end of slice by given path.
+//
+// Appending works through inspector, thus existing capacity of the slice reuses.
+func (ctx *Ctx) push2(path []string, val any) error {
+	if len(path) == 0 {
+		return nil
+	}
+	for i := 0; i < ctx.ln; i++ {
+		v := &ctx.vars[i]
+		if v.key == path[0] {
+			if v.ins == nil {
+				return nil
+			}
+			var x any
+			if x, ctx.Err = v.ins.Append(v.val, val, path[1:]...); ctx.Err != nil {
+				return ctx.Err
+			}
+			if len(path) == 1 {
+				// Variable itself is a slice, so update it.
+				v.val = x
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Grow slice by path of node and register new item in context under name of with variable.
+//
+// Item appends through inspector of the destination variable, and with variable is bound to the same variable and
+// inspector with path of the item as prefix (see withIns). Thus, items of any type are supported without registering
+// separate inspectors.
+func (ctx *Ctx) with(r *node) error {
+	path := ctx.dstPath(r)
+	if len(path) == 0 {
+		return nil
+	}
+	for i := 0; i < ctx.ln; i++ {
+		v := &ctx.vars[i]
+		if v.key == path[0] {
+			if v.ins == nil {
+				return ErrWithNoSlice
+			}
+			if ctx.Err = v.ins.GetTo(v.val, &ctx.bufX, path[1:]...); ctx.Err != nil {
+				return ctx.Err
+			}
+			item, ok := withItem(ctx.bufX)
+			if !ok {
+				return ErrWithNoSlice
+			}
+			if ctx.Err = v.ins.Length(v.val, &ctx.bufI_, path[1:]...); ctx.Err != nil {
+				return ctx.Err
+			}
+			// Index of the new item is the current length, zero item overwrites data left in capacity of the slice.
+			if _, ctx.Err = v.ins.Append(v.val, item, path[1:]...); ctx.Err != nil {
+				return ctx.Err
+			}
+			if ctx.wd == len(ctx.withs) {
+				ctx.withs = append(ctx.withs, &withIns{})
+			}
+			w := ctx.withs[ctx.wd]
+			ctx.wd++
+			w.bind(v.ins, path[1:], ctx.bufI_)
+			ctx.Set(byteconv.B2S(r.withVar), v.val, w)
+			return nil
+		}
+	}
+	return nil
+}
+
+// Zero items of slices by types of pointers to slices.
+var withZero sync.Map
+
+// Get zero item for slice given as pointer to slice.
+//
+// Items of value types are cached per type. Items of pointer types reuse pointers left in capacity of the slice, they
+// are allocating only if capacity is exhausted.
+func withItem(p any) (any, bool) {
+	t := reflect.TypeOf(p)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice {
+		return nil, false
+	}
+	e := t.Elem().Elem()
+	if e.Kind() == reflect.Ptr {
+		s := reflect.ValueOf(p).Elem()
+		if n := s.Len(); n < s.Cap() {
+			// Look at the item in capacity without reslicing, since Slice() allocates.
+			s.SetLen(n + 1)
+			x := s.Index(n)
+			s.SetLen(n)
+			if !x.IsNil() {
+				// Pointer keeps data of previous decoding, so reset it.
+				x.Elem().Set(reflect.Zero(e.Elem()))
+				return x.Interface(), true
+			}
+		}
+		return reflect.New(e.Elem()).Interface(), true
+	}
+	if z, ok := withZero.Load(t); ok {
+		return z, true
+	}
+	z := reflect.Zero(e).Interface()
+	withZero.Store(t, z)
+	return z, true
+}
+
+// Inspector of with variable.
+//
+// Wraps inspector of the destination variable and prepends path of the item to all paths, so "it.Name" inside of
+// "with dst.Items[+] as it" block works as "dst.Items[N].Name".
+type withIns struct {
+	inspector.Inspector
+	// Path of the item and its storage.
+	prefix []string
+	buf    []byte
+	// Buffers of full paths, loops have own buffer since iterator may call other methods.
+	bufP, bufL []string
+}
+
+// Set wrapped inspector and path of the item.
+func (w *withIns) bind(ins inspector.Inspector, path []string, idx int) {
+	w.Inspector = ins
+	// Path may refer to context buffers, so copy it.
+	w.buf = w.buf[:0]
+	for i := 0; i < len(path); i++ {
+		w.buf = append(w.buf, path[i]...)
+	}
+	w.buf = strconv.AppendInt(w.buf, int64(idx), 10)
+	w.prefix = w.prefix[:0]
+	var off int
+	for i := 0; i < len(path); i++ {
+		w.prefix = append(w.prefix, byteconv.B2S(w.buf[off:off+len(path[i])]))
+		off += len(path[i])
+	}
+	w.prefix = append(w.prefix, byteconv.B2S(w.buf[off:]))
+}
+
+func (w *withIns) path(path []string) []string {
+	w.bufP = append(append(w.bufP[:0], w.prefix...), path...)
+	return w.bufP
+}
+
+func (w *withIns) Get(src any, path ...string) (any, error) {
+	return w.Inspector.Get(src, w.path(path)...)
+}
+
+func (w *withIns) GetTo(src any, buf *any, path ...string) error {
+	return w.Inspector.GetTo(src, buf, w.path(path)...)
+}
+
+func (w *withIns) Set(dst, value any, path ...string) error {
+	return w.Inspector.Set(dst, value, w.path(path)...)
+}
+
+func (w *withIns) SetWithBuffer(dst, value any, buf inspector.AccumulativeBuffer, path ...string) error {
+	return w.Inspector.SetWithBuffer(dst, value, buf, w.path(path)...)
+}
+
+func (w *withIns) Compare(src any, cond inspector.Op, right string, result *bool, path ...string) error {
+	return w.Inspector.Compare(src, cond, right, result, w.path(path)...)
+}
+
+func (w *withIns) Loop(src any, iter inspector.Iterator, buf *[]byte, path ...string) error {
+	w.bufL = append(append(w.bufL[:0], w.prefix...), path...)
+	return w.Inspector.Loop(src, iter, buf, w.bufL...)
+}
+
+func (w *withIns) Append(src, value any, path ...string) (any, error) {
+	return w.Inspector.Append(src, value, w.path(path)...)
+}
+
+func (w *withIns) Length(src any, result *int, path ...string) error {
+	return w.Inspector.Length(src, result, w.path(path)...)
+}
+
+func (w *withIns) Capacity(src any, result *int, path ...string) error {
+	return w.Inspector.Capacity(src, result, w.path(path)...)
+}
+
+func (w *withIns) Reset(x any, path ...string) error {
+	return w.Inspector.Reset(x, w.path(path)...)
+}